@@ -0,0 +1,56 @@
+package influxdb
+
+// The minimal interfaces of metrics that don't implement the go-metrics interfaces, such as
+// adapters bridging go-kit metrics into a registry that only provide a getter. They are checked
+// after the go-metrics interfaces, which take precedence. The StandardRegistry of go-metrics doesn't
+// keep such metrics, they are reported from custom Registry implementations.
+type (
+	int64Valuer interface {
+		Value() int64
+	}
+	float64Valuer interface {
+		Value() float64
+	}
+	counter interface {
+		Count() int64
+	}
+)
+
+// adaptedFields returns the type and fields of a metric implementing one of the minimal interfaces,
+// or nil fields if it implements none or WithEmitIf skips it. Such gauges are written like go-metrics gauges and
+// counters like go-metrics counters, except that they are never reset by WithResetAfterRead.
+func (r *Reporter) adaptedFields(id, name string, i interface{}) (MetricType, map[string]interface{}) {
+	switch metric := i.(type) {
+	case int64Valuer:
+		if !r.emits(name, float64(metric.Value())) {
+			return "", nil
+		}
+		return TypeGauge, map[string]interface{}{
+			"value": r.gaugeValue(name, metric.Value()),
+		}
+	case float64Valuer:
+		if !r.emits(name, metric.Value()) {
+			return "", nil
+		}
+		return TypeGaugeFloat64, map[string]interface{}{
+			"value": metric.Value(),
+		}
+	case counter:
+		count := metric.Count()
+		if !r.emits(name, float64(count)) {
+			return "", nil
+		}
+		var value interface{} = count
+		if r.unsignedCounters {
+			value = r.unsigned(name, count)
+		}
+		fields := map[string]interface{}{
+			"value": value,
+		}
+		r.addDelta(fields, TypeCounter, id, count)
+		r.addCounterRate(fields, id, count, false, r.now())
+		return TypeCounter, fields
+	}
+
+	return "", nil
+}
@@ -0,0 +1,110 @@
+package influxdb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+// The metrics of a bridge that only provide getters.
+type (
+	kitGauge   struct{ v int64 }
+	kitFloat   struct{ v float64 }
+	kitCounter struct{ n int64 }
+)
+
+func (g kitGauge) Value() int64    { return g.v }
+func (g kitFloat) Value() float64  { return g.v }
+func (c *kitCounter) Count() int64 { return c.n }
+func (c *kitCounter) add(n int64)  { c.n += n }
+
+// wrappedCounter implements the go-metrics interface of counters with another type.
+type wrappedCounter struct {
+	metrics.Counter
+}
+
+// bridgeRegistry is a registry of a bridge, keeping any metric unlike the go-metrics registry.
+type bridgeRegistry struct {
+	metrics.Registry
+	metrics map[string]interface{}
+}
+
+func (r *bridgeRegistry) Each(fn func(string, interface{})) {
+	for name, i := range r.metrics {
+		fn(name, i)
+	}
+}
+
+func (r *bridgeRegistry) Get(name string) interface{} {
+	return r.metrics[name]
+}
+
+func TestAdapters(t *testing.T) {
+	c := &kitCounter{n: 3}
+	wrapped := wrappedCounter{metrics.NewCounter()}
+	wrapped.Inc(4)
+	reg := &bridgeRegistry{metrics.NewRegistry(), map[string]interface{}{
+		"goroutines":  kitGauge{12},
+		"load":        kitFloat{0.5},
+		"requests":    c,
+		"wrapped":     wrapped,
+		"unsupported": struct{}{},
+	}}
+
+	w := &testWriter{}
+	r := newTestReporter(t, reg, w, WithDeltas(TypeCounter))
+	flush(t, r)
+	c.add(2)
+	w.batches = nil
+	flush(t, r)
+
+	want := map[string]map[string]interface{}{
+		"goroutines.gauge": {"value": int64(12)},
+		"load.gauge":       {"value": 0.5},
+		"requests.count":   {"value": int64(5), "delta": int64(2)},
+		"wrapped.count":    {"value": int64(4), "delta": int64(0)},
+	}
+	pts := w.points()
+	if len(pts) != len(want) {
+		t.Fatalf("got points %v, want %d", pts, len(want))
+	}
+	for _, p := range pts {
+		fields, ok := want[p.Measurement]
+		if !ok {
+			t.Errorf("got point of %s", p.Measurement)
+			continue
+		}
+		if len(p.Fields) != len(fields) {
+			t.Errorf("got fields %v of %s, want %v", p.Fields, p.Measurement, fields)
+		}
+		for k, v := range fields {
+			if p.Fields[k] != v {
+				t.Errorf("got fields %v of %s, want %v", p.Fields, p.Measurement, fields)
+				break
+			}
+		}
+	}
+
+	// adapted counters get a rate, computed from their count since they are never cleared
+	cleared := &bridgeRegistry{metrics.NewRegistry(), map[string]interface{}{"requests": c}}
+	r = newTestReporter(t, cleared, w, WithCounterRate(), WithResetAfterRead())
+	now := fixedClock(r)
+	flush(t, r)
+	c.add(4)
+	*now = now.Add(2 * time.Second)
+	w.batches = nil
+	flush(t, r)
+	if pts := w.find("requests.count"); len(pts) != 1 || pts[0].Fields["rate"] != 2.0 {
+		t.Errorf("got points %v, want a rate of 2", pts)
+	}
+
+	// the types of the adapted metrics are those of their go-metrics counterparts
+	pts, err := BuildPoints(reg, nil, time.Now(), WithEnabledTypes(TypeCounter))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pts) != 2 {
+		t.Errorf("got points %v, want the counters only", pts)
+	}
+}
@@ -0,0 +1,86 @@
+package influxdb
+
+import (
+	"sort"
+	"time"
+
+	"github.com/influxdata/influxdb/client"
+)
+
+// DefaultAnnotationMeasurement is the measurement of the annotation points when WithAnnotations
+// is given no name.
+const DefaultAnnotationMeasurement = "metric_events"
+
+// maxAnnotations bounds the number of annotation points of a flush, so that registering many
+// metrics at once, such as at startup of a fresh registry, doesn't create as many series.
+const maxAnnotations = 100
+
+// annotationPoints returns the annotation points of a flush at time now: one per metric registered
+// or unregistered since the previous flush, tagged with its name under MetricTag and holding
+// an "event" field. The first flush only records the registered metrics. The allowed tag keys
+// of WithAllowedTagKeys apply to the other tags.
+func (r *Reporter) annotationPoints(now time.Time) []client.Point {
+	names := make(map[string]bool)
+	for _, nr := range r.registries() {
+		var prefix string
+		if nr.name != defaultRegistryName {
+			prefix = nr.name + "/"
+		}
+		nr.reg.Each(func(name string, _ interface{}) {
+			names[prefix+name] = true
+		})
+	}
+
+	prev := r.registered
+	if !r.peeking {
+		r.registered = names
+	}
+	if prev == nil {
+		return nil
+	}
+
+	base := []client.Point{{Measurement: r.annotations, Tags: r.flushTags()}}
+	r.filterTags(base)
+	tags := base[0].Tags
+	var events []client.Point
+	event := func(name, e string) {
+		merged := make(map[string]string, len(tags)+1)
+		for k, v := range tags {
+			merged[k] = v
+		}
+		merged[MetricTag] = name
+		events = append(events, client.Point{
+			Measurement: r.replaceDots(r.annotations),
+			Tags:        merged,
+			Fields: map[string]interface{}{
+				"event": e,
+			},
+			Time:      now,
+			Precision: r.precision,
+		})
+	}
+	for _, name := range sortedNames(names, prev) {
+		event(name, "registered")
+	}
+	for _, name := range sortedNames(prev, names) {
+		event(name, "unregistered")
+	}
+
+	if len(events) > maxAnnotations {
+		r.warnf("%d metrics were registered or unregistered since the previous flush, writing the annotations of the first %d", len(events), maxAnnotations)
+		events = events[:maxAnnotations]
+	}
+	return events
+}
+
+// sortedNames returns the sorted names of a that aren't in b.
+func sortedNames(a, b map[string]bool) []string {
+	var res []string
+	for name := range a {
+		if !b[name] {
+			res = append(res, name)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
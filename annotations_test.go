@@ -0,0 +1,95 @@
+package influxdb
+
+import (
+	"testing"
+
+	"github.com/influxdata/influxdb/client"
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestAnnotations(t *testing.T) {
+	reg := newRegistryWithCounter()
+	w := &testWriter{}
+	r := newTestReporter(t, reg, w, WithAnnotations(""))
+
+	flush(t, r)
+	if pts := w.find(DefaultAnnotationMeasurement); len(pts) != 0 {
+		t.Fatalf("got annotations %v from the first flush", pts)
+	}
+
+	metrics.GetOrRegisterGauge("queue.size", reg).Update(1)
+	reg.Unregister("requests")
+	flush(t, r)
+	want := map[string]string{"queue.size": "registered", "requests": "unregistered"}
+	pts := w.find(DefaultAnnotationMeasurement)
+	if len(pts) != len(want) {
+		t.Fatalf("got annotations %v, want %v", pts, want)
+	}
+	for _, p := range pts {
+		if e := want[p.Tags[MetricTag]]; p.Fields["event"] != e {
+			t.Errorf("got annotation %v, want event %q", p, e)
+		}
+	}
+
+	flush(t, r)
+	if n := len(w.find(DefaultAnnotationMeasurement)); n != len(want) {
+		t.Errorf("got %d annotations after a flush without changes, want none", n-len(want))
+	}
+}
+
+func TestAnnotationsAllowedTags(t *testing.T) {
+	reg := newRegistryWithCounter()
+	w := &testWriter{}
+	r := newTestReporter(t, reg, w,
+		WithAnnotations("events"),
+		WithAllowedTagKeys("host"),
+		WithTags(map[string]string{"host": "a", "pod": "b"}),
+		WithMetricTags("requests", map[string]string{MetricTag: "unbounded"}),
+	)
+	flush(t, r)
+	metrics.GetOrRegisterGauge("queue.size", reg).Update(1)
+	flush(t, r)
+
+	events := w.find("events")
+	if len(events) != 1 {
+		t.Fatalf("got annotations %v, want 1", events)
+	}
+	if want := map[string]string{"host": "a", MetricTag: "queue.size"}; !sameTags(events[0], want) {
+		t.Errorf("got annotation tags %v, want %v", events[0].Tags, want)
+	}
+	// the tags of the metrics named like the one of the annotations are dropped as any other
+	for _, p := range w.find("requests.count") {
+		if want := map[string]string{"host": "a"}; !sameTags(p, want) {
+			t.Errorf("got counter tags %v, want %v", p.Tags, want)
+		}
+	}
+}
+
+func TestAnnotationsWithSkipUnchangedFlush(t *testing.T) {
+	reg := newRegistryWithCounter()
+	w := &testWriter{}
+	r := newTestReporter(t, reg, w, WithAnnotations(""), WithSkipUnchangedFlush(), WithEnabledTypes(TypeCounter))
+	flush(t, r)
+
+	// the points of the metrics didn't change, the annotation of the gauge is still written
+	metrics.GetOrRegisterGauge("queue.size", reg).Update(1)
+	flush(t, r)
+	if pts := w.find(DefaultAnnotationMeasurement); len(pts) != 1 || pts[0].Tags[MetricTag] != "queue.size" {
+		t.Errorf("got annotations %v, want the registered gauge", pts)
+	}
+	if n := len(w.find("requests.count")); n != 1 {
+		t.Errorf("got %d counter points, want the unchanged one skipped", n)
+	}
+}
+
+func sameTags(p client.Point, tags map[string]string) bool {
+	if len(p.Tags) != len(tags) {
+		return false
+	}
+	for k, v := range tags {
+		if p.Tags[k] != v {
+			return false
+		}
+	}
+	return true
+}
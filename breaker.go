@@ -0,0 +1,114 @@
+package influxdb
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrCircuitOpen is returned for the flushes not written because the circuit breaker set by
+// WithCircuitBreaker is open. Their batches stay queued, unless written to the writer of WithFallback.
+var ErrCircuitOpen = errors.New("circuit breaker open after consecutive write failures, not writing")
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	// breakerHalfOpen lets a single write through to probe whether InfluxDB is back
+	breakerHalfOpen
+)
+
+// breaker stops the writes after consecutive failures for a cooldown, see WithCircuitBreaker.
+// It is only used by the goroutine draining the queue.
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	state     breakerState
+	failures  int
+	openUntil time.Time
+}
+
+// allow reports whether a write may be attempted at time now.
+func (b *breaker) allow(now time.Time) bool {
+	switch b.state {
+	case breakerOpen:
+		if now.Before(b.openUntil) {
+			return false
+		}
+		b.state = breakerHalfOpen
+		return true
+	case breakerHalfOpen:
+		// the probe is in progress
+		return false
+	default:
+		return true
+	}
+}
+
+// record records the result of a write attempted at time now,
+// and returns the new state when it changed.
+func (b *breaker) record(err error, now time.Time) (breakerState, bool) {
+	prev := b.state
+	if err == nil {
+		b.state, b.failures = breakerClosed, 0
+	} else {
+		b.failures++
+		if b.state == breakerHalfOpen || b.failures >= b.threshold {
+			b.state, b.openUntil = breakerOpen, now.Add(b.cooldown)
+		}
+	}
+	return b.state, b.state != prev
+}
+
+// rateLimited records that the write attempted at time now was rate limited, the writes being paused for d.
+// InfluxDB is up then, so it isn't a failure, but a probe reopens the breaker until both the cooldown and
+// the pause are over, since it would only skip the writes before. It returns whether the breaker reopened.
+func (b *breaker) rateLimited(d time.Duration, now time.Time) bool {
+	if b.state != breakerHalfOpen {
+		return false
+	}
+	if d < b.cooldown {
+		d = b.cooldown
+	}
+	b.state, b.openUntil = breakerOpen, now.Add(d)
+	return true
+}
+
+// allowWrite reports whether the circuit breaker lets a write through.
+func (r *Reporter) allowWrite() bool {
+	return r.breaker == nil || r.breaker.allow(r.now())
+}
+
+// recordWrite records the result of a write in the circuit breaker and logs its transitions.
+func (r *Reporter) recordWrite(err error) {
+	if r.breaker == nil {
+		return
+	}
+
+	state, changed := r.breaker.record(err, r.now())
+	if !changed {
+		return
+	}
+	switch state {
+	case breakerOpen:
+		r.selfGauge("circuit_open").Update(1)
+		r.logf("unable to write to InfluxDB repeatedly, not writing for %v. err=%v", r.breaker.cooldown, err)
+	case breakerClosed:
+		r.selfGauge("circuit_open").Update(0)
+		r.logf("InfluxDB is writable again, resuming writes")
+	}
+}
+
+// recordRateLimit records in the circuit breaker a write rejected or skipped because InfluxDB rate limits
+// the writes, which are paused for d.
+func (r *Reporter) recordRateLimit(d time.Duration) {
+	if r.breaker == nil {
+		return
+	}
+
+	now := r.now()
+	if r.breaker.rateLimited(d, now) {
+		r.logf("rate limited by InfluxDB while probing it, not writing for %v", r.breaker.openUntil.Sub(now))
+	}
+}
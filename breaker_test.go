@@ -0,0 +1,172 @@
+package influxdb
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBreakerTransitions(t *testing.T) {
+	b := &breaker{threshold: 2, cooldown: time.Minute}
+	now := time.Unix(0, 0)
+	errDown := errors.New("down")
+
+	if !b.allow(now) {
+		t.Fatal("closed breaker doesn't allow writes")
+	}
+	if _, changed := b.record(errDown, now); changed {
+		t.Fatal("breaker changed state before the threshold")
+	}
+	if state, changed := b.record(errDown, now); !changed || state != breakerOpen {
+		t.Fatalf("got state %v after %d failures, want open", state, b.threshold)
+	}
+	if b.allow(now.Add(time.Second)) {
+		t.Fatal("open breaker allows writes during its cooldown")
+	}
+
+	// the first write after the cooldown probes InfluxDB, the others wait for its result
+	now = now.Add(time.Minute)
+	if !b.allow(now) || b.state != breakerHalfOpen {
+		t.Fatal("breaker doesn't let a probe through after its cooldown")
+	}
+	if b.allow(now) {
+		t.Fatal("half-open breaker allows a second write")
+	}
+	if state, changed := b.record(errDown, now); !changed || state != breakerOpen {
+		t.Fatalf("got state %v after a failed probe, want open", state)
+	}
+	if b.allow(now.Add(time.Second)) {
+		t.Fatal("breaker reopened by a failed probe allows writes")
+	}
+
+	now = now.Add(time.Minute)
+	if !b.allow(now) {
+		t.Fatal("breaker doesn't let a probe through after its cooldown")
+	}
+	if state, changed := b.record(nil, now); !changed || state != breakerClosed {
+		t.Fatalf("got state %v after a successful probe, want closed", state)
+	}
+	if b.failures != 0 || !b.allow(now) {
+		t.Fatal("closed breaker doesn't allow writes")
+	}
+}
+
+func TestReporterCircuitBreaker(t *testing.T) {
+	w := &testWriter{}
+	w.failWith(errors.New("down"))
+	r := newTestReporter(t, newRegistryWithCounter(), w, WithCircuitBreaker(1, time.Minute))
+	now := time.Unix(0, 0)
+	r.now = func() time.Time { return now }
+
+	if err := r.send(nil); err == nil || err == ErrCircuitOpen {
+		t.Fatalf("got error %v from the first failed write", err)
+	}
+	if err := r.send(nil); err != ErrCircuitOpen {
+		t.Fatalf("got error %v from a write with the breaker open, want ErrCircuitOpen", err)
+	}
+
+	now = now.Add(time.Minute)
+	w.failWith(nil)
+	if err := r.send(nil); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(w.written()); n != 1 {
+		t.Errorf("got %d batches written after the cooldown, want 1", n)
+	}
+}
+
+func TestBreakerRateLimitedProbe(t *testing.T) {
+	var status, requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		switch s := int(atomic.LoadInt32(&status)); s {
+		case http.StatusTooManyRequests:
+			w.Header().Set("Retry-After", "120")
+			w.WriteHeader(s)
+			w.Write([]byte(`{"code":"too many requests","message":"write limit exceeded"}`))
+		case http.StatusInternalServerError:
+			w.WriteHeader(s)
+			w.Write([]byte(`{"error":"down"}`))
+		default:
+			w.WriteHeader(s)
+		}
+	}))
+	defer srv.Close()
+
+	r, err := New(newRegistryWithCounter(), time.Minute, srv.URL, "db", "", "", WithCircuitBreaker(1, time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Stop()
+	now := fixedClock(r)
+	start := *now
+	flushAt := func(d time.Duration, wantRequests int32) error {
+		t.Helper()
+
+		*now = start.Add(d)
+		err := r.Flush(context.Background())
+		if n := atomic.LoadInt32(&requests); n != wantRequests {
+			t.Fatalf("got %d requests at %v, want %d", n, d, wantRequests)
+		}
+		return err
+	}
+
+	atomic.StoreInt32(&status, http.StatusInternalServerError)
+	if err := flushAt(0, 1); err == nil {
+		t.Fatal("got no error from a failing server")
+	}
+	if err := flushAt(30*time.Second, 1); err != ErrCircuitOpen {
+		t.Fatalf("got error %v during the cooldown, want ErrCircuitOpen", err)
+	}
+
+	// the probe after the cooldown is rate limited, the breaker reopens until the end of the pause
+	atomic.StoreInt32(&status, http.StatusTooManyRequests)
+	if err := flushAt(time.Minute, 2); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("got error %v from the probe, want a rate limited write", err)
+	}
+	if r.breaker.state != breakerOpen {
+		t.Fatalf("got breaker state %v after a rate limited probe, want open", r.breaker.state)
+	}
+	atomic.StoreInt32(&status, http.StatusNoContent)
+	if err := flushAt(2*time.Minute, 2); err != ErrRateLimited {
+		t.Fatalf("got error %v during the pause, want ErrRateLimited", err)
+	}
+
+	if err := flushAt(3*time.Minute, 3); err != nil {
+		t.Fatal(err)
+	}
+	if r.breaker.state != breakerClosed {
+		t.Errorf("got breaker state %v after a successful write, want closed", r.breaker.state)
+	}
+}
+
+func TestBreakerRateLimited(t *testing.T) {
+	b := &breaker{threshold: 1, cooldown: time.Minute}
+	now := time.Unix(0, 0)
+	if b.rateLimited(time.Hour, now) {
+		t.Error("closed breaker reopened by a rate limited write")
+	}
+	b.record(errors.New("down"), now)
+
+	for _, tt := range []struct {
+		pause, open time.Duration
+	}{
+		{time.Second, time.Minute},
+		{2 * time.Minute, 2 * time.Minute},
+	} {
+		now = b.openUntil
+		if !b.allow(now) {
+			t.Fatal("breaker doesn't let a probe through after its cooldown")
+		}
+		if !b.rateLimited(tt.pause, now) || b.state != breakerOpen || !b.openUntil.Equal(now.Add(tt.open)) {
+			t.Errorf("got state %v until %v after a probe paused for %v, want open for %v", b.state, b.openUntil.Sub(now), tt.pause, tt.open)
+		}
+		if b.failures != 1 {
+			t.Errorf("got %d failures, want the rate limited probe not to count", b.failures)
+		}
+	}
+}
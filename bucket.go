@@ -0,0 +1,157 @@
+package influxdb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	uurl "net/url"
+	"path"
+	"time"
+)
+
+// EnsureBucket creates the bucket of an InfluxDB 2 server at url in the organization org, with the given
+// retention period, 0 keeping the points forever, unless it already exists. The token must be allowed
+// to read the buckets and organizations of org, and to write its buckets when the bucket is missing.
+// The retention of an existing bucket isn't changed.
+//
+// It smooths the first run against a fresh server: InfluxDB 2 maps every bucket to the database
+// of its name in its 1.x compatibility API, to which the reporter then writes, with the token given
+// as password. There is no reporter using the InfluxDB 2 write API.
+func EnsureBucket(ctx context.Context, url, org, token, bucket string, retention time.Duration) error {
+	switch {
+	case org == "":
+		return errors.New("an organization is required to ensure a bucket")
+	case token == "":
+		return errors.New("a token is required to ensure a bucket")
+	case bucket == "":
+		return errors.New("a bucket name is required")
+	case retention < 0:
+		return fmt.Errorf("negative retention %v for bucket %s", retention, bucket)
+	}
+	u, err := uurl.Parse(url)
+	if err != nil {
+		return fmt.Errorf("unable to parse InfluxDB url %s: %v", url, err)
+	}
+	c := &bucketClient{url: *u, token: token}
+
+	var buckets struct {
+		Buckets []struct {
+			Name string `json:"name"`
+		} `json:"buckets"`
+	}
+	// depending on their version, servers answer a missing bucket with an empty list or a 404
+	if err := c.do(ctx, "GET", "buckets", uurl.Values{"org": {org}, "name": {bucket}}, nil, &buckets); err != nil && !notFound(err) {
+		return fmt.Errorf("unable to look up bucket %s: %v", bucket, err)
+	}
+	for _, b := range buckets.Buckets {
+		if b.Name == bucket {
+			return nil
+		}
+	}
+
+	var orgs struct {
+		Orgs []struct {
+			ID string `json:"id"`
+		} `json:"orgs"`
+	}
+	if err := c.do(ctx, "GET", "orgs", uurl.Values{"org": {org}}, nil, &orgs); err != nil && !notFound(err) {
+		return fmt.Errorf("unable to look up organization %s: %v", org, err)
+	}
+	if len(orgs.Orgs) == 0 {
+		return fmt.Errorf("organization %s not found", org)
+	}
+
+	type retentionRule struct {
+		Type         string `json:"type"`
+		EverySeconds int64  `json:"everySeconds"`
+	}
+	req := struct {
+		OrgID          string          `json:"orgID"`
+		Name           string          `json:"name"`
+		RetentionRules []retentionRule `json:"retentionRules"`
+	}{
+		OrgID:          orgs.Orgs[0].ID,
+		Name:           bucket,
+		RetentionRules: []retentionRule{},
+	}
+	if retention > 0 {
+		req.RetentionRules = append(req.RetentionRules, retentionRule{Type: "expire", EverySeconds: int64(retention / time.Second)})
+	}
+	err = c.do(ctx, "POST", "buckets", nil, req, nil)
+	if ae, ok := err.(*apiError); ok && ae.status == http.StatusUnprocessableEntity {
+		// created concurrently since it was looked up
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("unable to create bucket %s: %v", bucket, err)
+	}
+	return nil
+}
+
+// bucketClient calls the API of an InfluxDB 2 server.
+type bucketClient struct {
+	url   uurl.URL
+	token string
+}
+
+// do sends a request to the endpoint of the API, with body encoded as JSON when not nil, and decodes
+// the response into res when not nil. A response with a status other than 2xx is reported as an *apiError,
+// explained when the token isn't allowed.
+func (c *bucketClient) do(ctx context.Context, method, endpoint string, params uurl.Values, body, res interface{}) error {
+	u := c.url
+	u.Path = path.Join("/", u.Path, "api/v2", endpoint)
+	u.RawQuery = params.Encode()
+
+	var b []byte
+	if body != nil {
+		var err error
+		if b, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", "Token "+c.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, _ := ioutil.ReadAll(resp.Body)
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return fmt.Errorf("the token isn't allowed to %s %s: %v", method, u.Path, &apiError{resp.StatusCode, string(bytes.TrimSpace(data))})
+	case resp.StatusCode/100 != 2:
+		return &apiError{resp.StatusCode, string(bytes.TrimSpace(data))}
+	case res == nil:
+		return nil
+	}
+	return json.Unmarshal(data, res)
+}
+
+// apiError is a response of the InfluxDB 2 API with a status other than 2xx.
+type apiError struct {
+	status int
+	body   string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("status %d: %s", e.status, e.body)
+}
+
+func notFound(err error) bool {
+	ae, ok := err.(*apiError)
+	return ok && ae.status == http.StatusNotFound
+}
@@ -0,0 +1,50 @@
+//go:build integration
+// +build integration
+
+package influxdb
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+)
+
+// TestEnsureBucketServer runs EnsureBucket against an InfluxDB 2 server, such as a container started with
+//
+//	docker run -p 8086:8086 -e DOCKER_INFLUXDB_INIT_MODE=setup -e DOCKER_INFLUXDB_INIT_USERNAME=admin \
+//		-e DOCKER_INFLUXDB_INIT_PASSWORD=password -e DOCKER_INFLUXDB_INIT_ORG=acme \
+//		-e DOCKER_INFLUXDB_INIT_BUCKET=default -e DOCKER_INFLUXDB_INIT_ADMIN_TOKEN=token influxdb:2
+//
+// and INFLUXDB2_URL, INFLUXDB2_ORG and INFLUXDB2_TOKEN set accordingly. It then writes a flush to the
+// bucket through the 1.x compatibility API of the server, which is the API the reporter writes to.
+func TestEnsureBucketServer(t *testing.T) {
+	url, org, token := os.Getenv("INFLUXDB2_URL"), os.Getenv("INFLUXDB2_ORG"), os.Getenv("INFLUXDB2_TOKEN")
+	if url == "" || org == "" || token == "" {
+		t.Skip("INFLUXDB2_URL, INFLUXDB2_ORG and INFLUXDB2_TOKEN are required")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	bucket := "go_metrics_influxdb_" + time.Now().Format("20060102150405")
+	if err := EnsureBucket(ctx, url, org, token, bucket, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	// ensuring it again finds it
+	if err := EnsureBucket(ctx, url, org, token, bucket, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := EnsureBucket(ctx, url, org, "invalid", bucket, time.Hour); err == nil {
+		t.Error("got no error with an invalid token")
+	}
+
+	// the server accepts the token as the password of any username
+	r, err := New(newRegistryWithCounter(), time.Minute, url, bucket, org, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Stop()
+	if err := r.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
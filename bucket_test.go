@@ -0,0 +1,104 @@
+package influxdb
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeBuckets serves the buckets and organizations endpoints of the InfluxDB 2 API used by EnsureBucket.
+type fakeBuckets struct {
+	mu      sync.Mutex
+	token   string
+	buckets map[string]int64
+}
+
+func (f *fakeBuckets) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if req.Header.Get("Authorization") != "Token "+f.token {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"code":"unauthorized"}`))
+		return
+	}
+	switch {
+	case req.Method == "GET" && req.URL.Path == "/api/v2/buckets":
+		var res struct {
+			Buckets []map[string]string `json:"buckets"`
+		}
+		res.Buckets = []map[string]string{}
+		if _, ok := f.buckets[req.URL.Query().Get("name")]; ok {
+			res.Buckets = append(res.Buckets, map[string]string{"name": req.URL.Query().Get("name")})
+		}
+		json.NewEncoder(w).Encode(res)
+	case req.Method == "GET" && req.URL.Path == "/api/v2/orgs":
+		if req.URL.Query().Get("org") != "acme" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"orgs":[{"id":"0001"}]}`))
+	case req.Method == "POST" && req.URL.Path == "/api/v2/buckets":
+		var body struct {
+			OrgID          string `json:"orgID"`
+			Name           string `json:"name"`
+			RetentionRules []struct {
+				EverySeconds int64 `json:"everySeconds"`
+			} `json:"retentionRules"`
+		}
+		json.NewDecoder(req.Body).Decode(&body)
+		if body.OrgID != "0001" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var every int64
+		if len(body.RetentionRules) > 0 {
+			every = body.RetentionRules[0].EverySeconds
+		}
+		f.buckets[body.Name] = every
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{}`))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestEnsureBucket(t *testing.T) {
+	f := &fakeBuckets{token: "secret", buckets: map[string]int64{"existing": 3600}}
+	srv := httptest.NewServer(f)
+	defer srv.Close()
+	ctx := context.Background()
+
+	if err := EnsureBucket(ctx, srv.URL, "acme", "secret", "metrics", 24*time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if every, ok := f.buckets["metrics"]; !ok || every != 86400 {
+		t.Errorf("got buckets %v, want metrics created with a retention of a day", f.buckets)
+	}
+
+	// the retention of an existing bucket is kept
+	if err := EnsureBucket(ctx, srv.URL, "acme", "secret", "existing", 0); err != nil {
+		t.Fatal(err)
+	}
+	if f.buckets["existing"] != 3600 {
+		t.Errorf("got retention %d of the existing bucket, want it unchanged", f.buckets["existing"])
+	}
+
+	err := EnsureBucket(ctx, srv.URL, "acme", "wrong", "metrics", 0)
+	if err == nil || !strings.Contains(err.Error(), "isn't allowed") {
+		t.Errorf("got error %v, want the token reported as not allowed", err)
+	}
+	if err := EnsureBucket(ctx, srv.URL, "other", "secret", "new", 0); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("got error %v, want the organization not found", err)
+	}
+	for _, args := range [][3]string{{"", "secret", "b"}, {"acme", "", "b"}, {"acme", "secret", ""}} {
+		if err := EnsureBucket(ctx, srv.URL, args[0], args[1], args[2], 0); err == nil {
+			t.Errorf("got no error for org %q, token %q and bucket %q", args[0], args[1], args[2])
+		}
+	}
+}
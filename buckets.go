@@ -0,0 +1,38 @@
+package influxdb
+
+import (
+	"strconv"
+	"time"
+)
+
+// Bucketer is an optional interface of histograms and timers keeping the number of samples per bucket,
+// as Prometheus histograms do. For a metric implementing it, a field is written per bucket named
+// "le_" followed by its upper bound, such as "le_0.25", holding the number of samples lower than
+// or equal to the bound, next to the usual statistics. counts[i] is the cumulative count of the bucket
+// upper bounded by bounds[i], the bounds being increasing, in nanoseconds for timers. The fields
+// of timers are named in the unit of WithDurationUnit, such as "le_250" for 250ms in milliseconds.
+// The go-metrics histograms don't keep buckets, they are reported with their percentiles only.
+type Bucketer interface {
+	Buckets() (bounds []float64, counts []int64)
+}
+
+// addBuckets adds to fields the bucket counts of i when it implements Bucketer, with bounds
+// expressed in unit when it is set.
+func addBuckets(fields map[string]interface{}, i interface{}, unit time.Duration) {
+	b, ok := i.(Bucketer)
+	if !ok {
+		return
+	}
+
+	bounds, counts := b.Buckets()
+	for j, bound := range bounds {
+		if j >= len(counts) {
+			break
+		}
+		if unit > 0 {
+			// not rounded, so that close bounds keep distinct fields
+			bound /= float64(unit)
+		}
+		fields["le_"+strconv.FormatFloat(bound, 'f', -1, 64)] = counts[j]
+	}
+}
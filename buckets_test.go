@@ -0,0 +1,71 @@
+package influxdb
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+// bucketedHistogram is a histogram keeping buckets.
+type bucketedHistogram struct {
+	metrics.Histogram
+}
+
+func (h bucketedHistogram) Buckets() ([]float64, []int64) {
+	return []float64{0.25, 1, 10}, []int64{1, 3, 4}
+}
+
+// bucketedTimer is a timer keeping buckets of 1ms and 250ms.
+type bucketedTimer struct {
+	metrics.Timer
+}
+
+func (t bucketedTimer) Buckets() ([]float64, []int64) {
+	return []float64{float64(time.Millisecond), float64(250 * time.Millisecond)}, []int64{2, 5}
+}
+
+func TestBuckets(t *testing.T) {
+	tests := []struct {
+		name   string
+		metric interface{}
+		opts   []Option
+		want   map[string]int64
+	}{
+		{"histogram", bucketedHistogram{metrics.NewHistogram(metrics.NewUniformSample(10))}, nil, map[string]int64{"le_0.25": 1, "le_1": 3, "le_10": 4}},
+		{"timer", bucketedTimer{metrics.NewTimer()}, nil, map[string]int64{"le_1000000": 2, "le_250000000": 5}},
+		{"timer in milliseconds", bucketedTimer{metrics.NewTimer()}, []Option{WithDurationUnit(time.Millisecond, 0)}, map[string]int64{"le_1": 2, "le_250": 5}},
+		{"timer in seconds", bucketedTimer{metrics.NewTimer()}, []Option{WithDurationUnit(time.Second, 0)}, map[string]int64{"le_0.001": 2, "le_0.25": 5}},
+		{"plain histogram", metrics.NewHistogram(metrics.NewUniformSample(10)), nil, nil},
+	}
+	for _, tt := range tests {
+		reg := metrics.NewRegistry()
+		if err := reg.Register("latency", tt.metric); err != nil {
+			t.Fatal(err)
+		}
+		pts, err := BuildPoints(reg, nil, time.Now(), tt.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pts) != 1 {
+			t.Fatalf("%s: got %d points, want 1", tt.name, len(pts))
+		}
+
+		got := make(map[string]int64)
+		for k, v := range pts[0].Fields {
+			if strings.HasPrefix(k, "le_") {
+				got[k] = v.(int64)
+			}
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got buckets %v, want %v", tt.name, got, tt.want)
+		}
+		for k, v := range tt.want {
+			if got[k] != v {
+				t.Errorf("%s: got buckets %v, want %v", tt.name, got, tt.want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package influxdb
+
+import (
+	"reflect"
+	"runtime"
+	"runtime/debug"
+	"strings"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+// BuildInfoMetric is the name of the gauge registered by WithBuildInfo.
+const BuildInfoMetric = "build_info"
+
+// maxBuildInfoTagLength bounds the length of the tag values of the build info gauge.
+const maxBuildInfoTagLength = 64
+
+// registerBuildInfo registers the build info gauge in the registry of the reporter,
+// and adds the tags describing the build to its points.
+func (r *Reporter) registerBuildInfo() {
+	metrics.GetOrRegisterGauge(BuildInfoMetric, r.reg).Update(1)
+	r.metricTags = append(r.metricTags, metricTags{pattern: BuildInfoMetric, tags: buildInfoTags()})
+}
+
+// buildInfoTags returns the version, commit and Go version of the running binary.
+// Values that are unknown, for instance when the binary isn't built with module support, are "unknown".
+func buildInfoTags() map[string]string {
+	tags := map[string]string{
+		"version":    "unknown",
+		"commit":     "unknown",
+		"go_version": runtime.Version(),
+	}
+
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return sanitizeBuildInfo(tags)
+	}
+	if info.Main.Version != "" {
+		tags["version"] = info.Main.Version
+	}
+	if info.GoVersion != "" {
+		tags["go_version"] = info.GoVersion
+	}
+	for _, s := range info.Settings {
+		if s.Key == "vcs.revision" && s.Value != "" {
+			tags["commit"] = s.Value
+		}
+	}
+
+	return sanitizeBuildInfo(tags)
+}
+
+// libraryName is the name of this library in the value of the tag of WithSourceTag.
+const libraryName = "go-metrics-influxdb"
+
+// sourceTagValue returns the value of the tag of WithSourceTag: the name of this library followed by
+// its version as recorded in the build info of the running binary, such as "go-metrics-influxdb/v1.2.0",
+// or "unknown" as version when it isn't recorded.
+func sourceTagValue() string {
+	version := "unknown"
+	if info, ok := debug.ReadBuildInfo(); ok {
+		pkg := reflect.TypeOf(Reporter{}).PkgPath()
+		mods := append([]*debug.Module{&info.Main}, info.Deps...)
+		for _, m := range mods {
+			if m == nil || m.Path == "" || pkg != m.Path && !strings.HasPrefix(pkg, m.Path+"/") {
+				continue
+			}
+			if m.Replace != nil && m.Replace.Version != "" {
+				m = m.Replace
+			}
+			if m.Version != "" && m.Version != "(devel)" {
+				version = m.Version
+			}
+			break
+		}
+	}
+
+	return sanitizeBuildInfo(map[string]string{"source": libraryName + "/" + version})["source"]
+}
+
+// sanitizeBuildInfo replaces in the tag values the characters other than letters, digits and ".-_+/()"
+// with "_" and truncates them, so that a build never creates unbounded or unreadable series.
+func sanitizeBuildInfo(tags map[string]string) map[string]string {
+	for k, v := range tags {
+		v = strings.Map(func(c rune) rune {
+			switch {
+			case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', strings.ContainsRune(".-_+/()", c):
+				return c
+			}
+			return '_'
+		}, v)
+		if len(v) > maxBuildInfoTagLength {
+			v = v[:maxBuildInfoTagLength]
+		}
+		tags[k] = v
+	}
+	return tags
+}
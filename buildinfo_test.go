@@ -0,0 +1,55 @@
+package influxdb
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestBuildInfo(t *testing.T) {
+	w := &testWriter{}
+	r := newTestReporter(t, metrics.NewRegistry(), w, WithBuildInfo())
+	flush(t, r)
+
+	pts := w.find(BuildInfoMetric + ".gauge")
+	if len(pts) != 1 {
+		t.Fatalf("got points %v, want one build info point", w.points())
+	}
+	p := pts[0]
+	if p.Fields["value"] != int64(1) {
+		t.Errorf("got fields %v, want a value of 1", p.Fields)
+	}
+	for _, k := range []string{"version", "commit", "go_version"} {
+		if p.Tags[k] == "" {
+			t.Errorf("build info point has no tag %s: %v", k, p.Tags)
+		}
+	}
+}
+
+func TestBuildInfoNotRegisteredByInvalidConfig(t *testing.T) {
+	reg := metrics.NewRegistry()
+	if _, err := New(reg, time.Minute, "", "", "", "", WithWriter(&testWriter{}), WithBuildInfo(), WithWriteTimer(""), WithPrecision("invalid")); err == nil {
+		t.Fatal("got no error for an invalid precision")
+	}
+	if _, err := BuildPoints(reg, nil, time.Now(), WithBuildInfo(), WithWriteTimer(""), WithPrecision("invalid")); err == nil {
+		t.Fatal("got no error for an invalid precision")
+	}
+	if names := registered(reg); len(names) != 0 {
+		t.Errorf("got metrics %v registered by failed constructors", names)
+	}
+}
+
+func TestSanitizeBuildInfo(t *testing.T) {
+	tags := sanitizeBuildInfo(map[string]string{
+		"version": "v1.2.3+dirty build",
+		"commit":  strings.Repeat("a", 100),
+	})
+	if tags["version"] != "v1.2.3+dirty_build" {
+		t.Errorf("got version %q", tags["version"])
+	}
+	if len(tags["commit"]) != maxBuildInfoTagLength {
+		t.Errorf("got commit of %d characters, want %d", len(tags["commit"]), maxBuildInfoTagLength)
+	}
+}
@@ -0,0 +1,50 @@
+package influxdb
+
+import "time"
+
+// Reset clears the state the reporter keeps about the metrics between flushes: the previous counts
+// of WithDeltas and WithSkipIdleHistograms, the fingerprints of WithSkipUnchanged and
+// WithSkipUnchangedFlush, the quantiles of WithPercentileCache, the last flushes of
+// WithTypeInterval, the values of WithLastUpdated and the metrics registered for WithAnnotations.
+// The next flush then behaves like the first one, for example after counters were deliberately
+// reset or metrics were replaced, when deltas computed from the previous counts would be
+// meaningless. It is safe to call while the reporter runs, the state is cleared by the next flush.
+// Queued batches are kept.
+func (r *Reporter) Reset() {
+	r.mu.Lock()
+	r.resetPending = true
+	r.mu.Unlock()
+}
+
+// clearCaches clears the state kept between flushes if Reset was called.
+// It runs on the goroutine flushing, which owns that state.
+func (r *Reporter) clearCaches() {
+	r.mu.Lock()
+	pending := r.resetPending
+	r.resetPending = false
+	r.mu.Unlock()
+	if !pending {
+		return
+	}
+
+	r.deltas.prev = make(map[string]int64)
+	r.prints = nil
+	r.batchPrint, r.batchPrintSet = 0, false
+	r.registered = nil
+	if r.counterRates != nil {
+		r.counterRates = make(map[string]counterSample)
+	}
+	if r.lastUpdates != nil {
+		r.lastUpdates = make(map[string]lastUpdate)
+	}
+	if r.idleCounts != nil {
+		r.idleCounts = make(map[string]int64)
+	}
+	if r.percentileCache != nil {
+		r.percentileCache = make(map[string]cachedPercentiles)
+		r.percentileNext = make(map[string]cachedPercentiles)
+	}
+	if r.typeFlushes != nil {
+		r.typeFlushes = make(map[MetricType]time.Time)
+	}
+}
@@ -0,0 +1,48 @@
+package influxdb
+
+import (
+	"hash/fnv"
+
+	"github.com/influxdata/influxdb/client"
+	"github.com/rcrowley/go-metrics"
+)
+
+// defaultSeriesWarning is the number of series per flush above which a warning is logged.
+const defaultSeriesWarning = 10000
+
+// countSeries returns the number of distinct series of pts. The series are told apart by a 64 bit
+// hash of their key, so the count may be underestimated by a collision, which is fine for a warning.
+func countSeries(pts []client.Point) int {
+	seen := make(map[uint64]struct{}, len(pts))
+	for _, p := range pts {
+		h := fnv.New64a()
+		h.Write([]byte(seriesKey(p)))
+		seen[h.Sum64()] = struct{}{}
+	}
+	return len(seen)
+}
+
+// checkCardinality counts the series of a flush, logging a warning when their number crosses the threshold.
+func (r *Reporter) checkCardinality(pts []client.Point) int {
+	n := countSeries(pts)
+	if r.peeking {
+		return n
+	}
+	r.selfGauge("series").Update(int64(n))
+
+	over := r.seriesWarning > 0 && n > r.seriesWarning
+	if over && !r.overSeries {
+		r.logf("a flush produced %d series, more than %d: check for tags with unbounded values, see WithAllowedTagKeys", n, r.seriesWarning)
+	}
+	r.overSeries = over
+
+	return n
+}
+
+// selfGauge returns the gauge about the reporter with the given name, see selfCounter.
+func (r *Reporter) selfGauge(name string) metrics.Gauge {
+	if !r.selfMetrics {
+		return metrics.NilGauge{}
+	}
+	return metrics.GetOrRegisterGauge(r.selfMetricName(name), r.reg)
+}
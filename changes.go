@@ -0,0 +1,58 @@
+package influxdb
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+
+	"github.com/influxdata/influxdb/client"
+)
+
+// fingerprint hashes the series and field values of a point, ignoring its timestamp.
+func fingerprint(p client.Point) uint64 {
+	keys := make([]string, 0, len(p.Fields))
+	for k := range p.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	h := fnv.New64a()
+	h.Write([]byte(seriesKey(p)))
+	for _, k := range keys {
+		fmt.Fprintf(h, "\x00%s=%v", k, p.Fields[k])
+	}
+	return h.Sum64()
+}
+
+// skipUnchangedPoints removes the points whose fields are the same as at the previous flush.
+func (r *Reporter) skipUnchangedPoints(pts []client.Point) []client.Point {
+	prints := make(map[string]uint64, len(pts))
+	res := pts[:0]
+	for _, p := range pts {
+		key := seriesKey(p)
+		fp := fingerprint(p)
+		prints[key] = fp
+		if prev, ok := r.prints[key]; ok && prev == fp {
+			continue
+		}
+		res = append(res, p)
+	}
+	r.prints = prints
+
+	return res
+}
+
+// batchChanged tells whether the fields of a batch differ from the ones of the previous flush.
+func (r *Reporter) batchChanged(pts []client.Point) bool {
+	// the sum doesn't depend on the order of the points, which comes from the registry iteration
+	var fp uint64
+	for _, p := range pts {
+		fp += fingerprint(p)
+	}
+
+	changed := !r.batchPrintSet || fp != r.batchPrint
+	r.batchPrint = fp
+	r.batchPrintSet = true
+
+	return changed
+}
@@ -0,0 +1,41 @@
+package influxdb
+
+import (
+	"reflect"
+	"testing"
+
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+func TestSkipUnchanged(t *testing.T) {
+	reg := metrics.NewRegistry()
+	a := metrics.GetOrRegisterCounter("a", reg)
+	a.Inc(1)
+	metrics.GetOrRegisterCounter("b", reg).Inc(1)
+
+	tests := []struct {
+		name string
+		opt  Option
+		// points written by each of the three flushes, a changing before the last one
+		want []int
+	}{
+		{"points", WithSkipUnchanged(), []int{2, 0, 1}},
+		{"flush", WithSkipUnchangedFlush(), []int{2, 0, 2}},
+	}
+	for _, tt := range tests {
+		w := &testWriter{}
+		r := newTestReporter(t, reg, w, tt.opt)
+		var got []int
+		for i := 0; i < 3; i++ {
+			if i == 2 {
+				a.Inc(1)
+			}
+			before := len(w.points())
+			flush(t, r)
+			got = append(got, len(w.points())-before)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v points written by the flushes, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package influxdb
+
+import "github.com/influxdata/influxdb/client"
+
+// chunks splits bp into batches whose estimated size doesn't exceed maxBatchBytes, see WithMaxBatchBytes.
+// The size of a point is the length of its line protocol, divided by the compression ratio when set.
+// A point larger than the limit on its own is written in its own batch.
+func (r *Reporter) chunks(bp client.BatchPoints) []client.BatchPoints {
+	if r.maxBatchBytes <= 0 {
+		return []client.BatchPoints{bp}
+	}
+
+	var (
+		res   []client.BatchPoints
+		start int
+		size  float64
+	)
+	for i, p := range bp.Points {
+		n := float64(pointSize(bp, p, r.floats))
+		if r.compressionRatio > 0 {
+			n /= r.compressionRatio
+		}
+		if i > start && size+n > float64(r.maxBatchBytes) {
+			chunk := bp
+			chunk.Points = bp.Points[start:i]
+			res = append(res, chunk)
+			start, size = i, 0
+		}
+		size += n
+	}
+	chunk := bp
+	chunk.Points = bp.Points[start:]
+	return append(res, chunk)
+}
+
+// skipUnwritable removes the points which can't be written: those the line protocol can't represent,
+// such as a point with a NaN field, which the writers would silently skip, and those whose line exceeds
+// maxPointBytes, see WithMaxPointBytes. It returns the numbers of invalid and oversized points removed.
+func (r *Reporter) skipUnwritable(pts []client.Point) (res []client.Point, invalid, oversized int) {
+	res = pts[:0]
+	for _, p := range pts {
+		if p.Raw != "" {
+			res = append(res, p)
+			continue
+		}
+		line, err := appendLine(nil, p, r.precision, r.floats)
+		if err != nil {
+			invalid++
+			r.selfCounter("invalid_points").Inc(1)
+			r.logf("unable to write point as line protocol, skipping it. err=%v", err)
+			continue
+		}
+		if n := len(line) + 1; r.maxPointBytes > 0 && n > r.maxPointBytes {
+			oversized++
+			r.selfCounter("oversized_points").Inc(1)
+			r.logf("point of measurement %s is %d bytes with %d fields, more than the limit of %d bytes, skipping it", p.Measurement, n, len(p.Fields), r.maxPointBytes)
+			continue
+		}
+		res = append(res, p)
+	}
+	return res, invalid, oversized
+}
+
+// pointSize returns the length of the line of p in batch bp, 0 if it can't be serialized.
+func pointSize(bp client.BatchPoints, p client.Point, floats FloatFormat) int {
+	bp.Points = []client.Point{p}
+	var n int
+	for _, line := range lines(bp, floats) {
+		n += len(line)
+	}
+	return n
+}
@@ -0,0 +1,42 @@
+package influxdb
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestOversizedPoints(t *testing.T) {
+	reg := newRegistryWithCounter()
+	h := metrics.GetOrRegisterHistogram("latency", reg, metrics.NewUniformSample(100))
+	h.Update(1)
+	// the point of the histogram holds 20 percentile fields, which makes it much longer than the line of the counter
+	ps := make([]float64, 20)
+	for i := range ps {
+		ps[i] = 0.5 + float64(i)/50
+	}
+	w := &testWriter{}
+	l := &testLogger{}
+	var stats FlushStats
+	r := newTestReporter(t, reg, w, WithLogger(l), WithPercentiles(ps...), WithMaxPointBytes(200), WithOnFlush(func(s FlushStats) { stats = s }))
+	flush(t, r)
+
+	if stats.Dropped.Oversized != 1 || stats.Points != 1 {
+		t.Errorf("got %d oversized points of %d, want 1 of 1", stats.Dropped.Oversized, stats.Points)
+	}
+	if len(w.find("latency.histogram")) != 0 || len(w.find("requests.count")) != 1 {
+		t.Errorf("got points %v, want the oversized histogram to be skipped", w.points())
+	}
+	if msgs := l.messages(); len(msgs) != 1 || !strings.Contains(msgs[0], "latency.histogram") || !strings.Contains(msgs[0], "200 bytes") {
+		t.Errorf("got log messages %q", msgs)
+	}
+
+	// without a limit, the point is written
+	r.Stop()
+	w = &testWriter{}
+	flush(t, newTestReporter(t, reg, w, WithPercentiles(ps...)))
+	if len(w.find("latency.histogram")) != 1 {
+		t.Errorf("got points %v, want the histogram written without a limit", w.points())
+	}
+}
@@ -0,0 +1,564 @@
+package influxdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+const (
+	// DefaultInterval is the interval used by NewFromConfig when none is configured.
+	DefaultInterval = 10 * time.Second
+
+	// DefaultURL is the InfluxDB url used by NewFromConfig when none is configured.
+	DefaultURL = "http://localhost:8086"
+)
+
+// Config holds the settings of a reporter as plain fields so that it can be unmarshaled
+// from a configuration file. The zero value of every field selects its default.
+type Config struct {
+	// Registry is the registry to report, metrics.DefaultRegistry if nil.
+	Registry metrics.Registry `json:"-" yaml:"-"`
+	// Interval between two flushes, DefaultInterval if zero.
+	// Note that encoding/json decodes a time.Duration from an integer number of nanoseconds.
+	Interval time.Duration `json:"interval" yaml:"interval"`
+
+	// Name enables WithName when set.
+	Name string `json:"name" yaml:"name"`
+	// Logger enables WithLogger when set.
+	Logger Logger `json:"-" yaml:"-"`
+
+	// URL of the InfluxDB server, DefaultURL if empty.
+	URL      string `json:"url" yaml:"url"`
+	Database string `json:"database" yaml:"database"`
+	Username string `json:"username" yaml:"username"`
+	Password string `json:"password" yaml:"password"`
+	// Credentials enables WithCredentials when set, Username and Password are then unused.
+	Credentials Credentials `json:"-" yaml:"-"`
+
+	// Tags added to every point.
+	Tags map[string]string `json:"tags" yaml:"tags"`
+	// AllowedTagKeys enables WithAllowedTagKeys when set.
+	AllowedTagKeys []string `json:"allowed_tag_keys" yaml:"allowed_tag_keys"`
+	// SeriesWarning enables WithSeriesWarning when set, a negative value disables the warning.
+	SeriesWarning int `json:"series_warning" yaml:"series_warning"`
+	// MetricTags enables WithMetricTags for every pattern and tags it holds, in pattern order.
+	MetricTags map[string]map[string]string `json:"metric_tags" yaml:"metric_tags"`
+	// Layout enables WithLayout when set.
+	Layout Layout `json:"layout" yaml:"layout"`
+	// ConfigFetcher enables WithConfigFetcher with ConfigFetchInterval when set.
+	ConfigFetcher       func(ctx context.Context) (RemoteConfig, error) `json:"-" yaml:"-"`
+	ConfigFetchInterval time.Duration                                   `json:"config_fetch_interval" yaml:"config_fetch_interval"`
+	// NormalizedNames enables WithNormalizedNames.
+	NormalizedNames bool `json:"normalized_names" yaml:"normalized_names"`
+	// CounterRate enables WithCounterRate.
+	CounterRate bool `json:"counter_rate" yaml:"counter_rate"`
+	// Uptime enables WithUptime with UptimeName.
+	Uptime     bool   `json:"uptime" yaml:"uptime"`
+	UptimeName string `json:"uptime_name" yaml:"uptime_name"`
+	// Annotations enables WithAnnotations with AnnotationsName.
+	Annotations     bool   `json:"annotations" yaml:"annotations"`
+	AnnotationsName string `json:"annotations_name" yaml:"annotations_name"`
+	// MeasurementTemplates enables WithMeasurementTemplate for every metric type and template it holds.
+	MeasurementTemplates map[MetricType]string `json:"measurement_templates" yaml:"measurement_templates"`
+	// Groups enables WithGroups when set.
+	Groups []string `json:"groups" yaml:"groups"`
+	// DatabaseFunc enables WithDatabaseFunc when set.
+	DatabaseFunc func() string `json:"-" yaml:"-"`
+	// Routes enables WithRoute for every route it holds, in order.
+	Routes []Route `json:"routes" yaml:"routes"`
+	// SuffixSeparator enables WithSuffixSeparator when set.
+	SuffixSeparator string `json:"suffix_separator" yaml:"suffix_separator"`
+	// DotReplacement enables WithDotReplacement when set.
+	DotReplacement string `json:"dot_replacement" yaml:"dot_replacement"`
+	// DedupSuffixes enables WithoutDuplicateSuffixes.
+	DedupSuffixes bool `json:"dedup_suffixes" yaml:"dedup_suffixes"`
+	// BuildInfo enables WithBuildInfo.
+	BuildInfo bool `json:"build_info" yaml:"build_info"`
+	// Heartbeat enables WithHeartbeat with HeartbeatName.
+	Heartbeat     bool   `json:"heartbeat" yaml:"heartbeat"`
+	HeartbeatName string `json:"heartbeat_name" yaml:"heartbeat_name"`
+	// WriteTimer enables WithWriteTimer with WriteTimerName.
+	WriteTimer     bool   `json:"write_timer" yaml:"write_timer"`
+	WriteTimerName string `json:"write_timer_name" yaml:"write_timer_name"`
+	// RuntimeInfo enables WithRuntimeInfo with RuntimeInfoName.
+	RuntimeInfo     bool   `json:"runtime_info" yaml:"runtime_info"`
+	RuntimeInfoName string `json:"runtime_info_name" yaml:"runtime_info_name"`
+	// RuntimeStatsInterval enables WithRuntimeStats when set.
+	RuntimeStatsInterval time.Duration `json:"runtime_stats_interval" yaml:"runtime_stats_interval"`
+	// RegistryTag enables WithRegistryTag when set.
+	RegistryTag string `json:"registry_tag" yaml:"registry_tag"`
+
+	// Precision enables WithPrecision when set.
+	Precision string `json:"precision" yaml:"precision"`
+	// EnabledTypes enables WithEnabledTypes when not empty.
+	EnabledTypes []MetricType `json:"enabled_types" yaml:"enabled_types"`
+	// IntervalTag enables WithIntervalTag with the key it holds when set.
+	IntervalTag string `json:"interval_tag" yaml:"interval_tag"`
+	// SourceTag enables WithSourceTag with the key it holds when set.
+	SourceTag string `json:"source_tag" yaml:"source_tag"`
+	// TypeTags enables WithTypeTags for every metric type and tags it holds.
+	TypeTags map[MetricType]map[string]string `json:"type_tags" yaml:"type_tags"`
+	// TypeIntervals enables WithTypeInterval for every metric type and interval it holds.
+	TypeIntervals map[MetricType]time.Duration `json:"type_intervals" yaml:"type_intervals"`
+	// TypePrecisions enables WithTypePrecision for every metric type and precision it holds.
+	TypePrecisions map[MetricType]string `json:"type_precisions" yaml:"type_precisions"`
+	// Timestamps enables WithTimestamps when set.
+	Timestamps func() time.Time `json:"-" yaml:"-"`
+	// TimestampTruncation enables WithTimestampTruncation when set.
+	TimestampTruncation time.Duration `json:"timestamp_truncation" yaml:"timestamp_truncation"`
+	// TimeOffset enables WithTimeOffset when set.
+	TimeOffset time.Duration `json:"time_offset" yaml:"time_offset"`
+	// HTTP2 enables WithHTTP2.
+	HTTP2 bool `json:"http2" yaml:"http2"`
+	// WriteTimeout enables WithWriteTimeout when set.
+	WriteTimeout time.Duration `json:"write_timeout" yaml:"write_timeout"`
+	// FloatFormat and FloatPrecision enable WithFloatFormat when FloatFormat is set, with a precision
+	// of -1 when FloatPrecision isn't.
+	FloatFormat    string `json:"float_format" yaml:"float_format"`
+	FloatPrecision *int   `json:"float_precision" yaml:"float_precision"`
+	// MaxPointBytes enables WithMaxPointBytes when set.
+	MaxPointBytes int `json:"max_point_bytes" yaml:"max_point_bytes"`
+	// MaxBatchBytes and CompressionRatio enable WithMaxBatchBytes and WithCompressionRatio when set.
+	MaxBatchBytes    int     `json:"max_batch_bytes" yaml:"max_batch_bytes"`
+	CompressionRatio float64 `json:"compression_ratio" yaml:"compression_ratio"`
+	// RetryAttempts enables WithRetry with RetryBackoff when set.
+	RetryAttempts int           `json:"retry_attempts" yaml:"retry_attempts"`
+	RetryBackoff  time.Duration `json:"retry_backoff" yaml:"retry_backoff"`
+	// RetryBudget enables WithRetryBudget when set.
+	RetryBudget time.Duration `json:"retry_budget" yaml:"retry_budget"`
+	// BreakerFailures enables WithCircuitBreaker with BreakerCooldown when set.
+	BreakerFailures int           `json:"breaker_failures" yaml:"breaker_failures"`
+	BreakerCooldown time.Duration `json:"breaker_cooldown" yaml:"breaker_cooldown"`
+	// Retryable enables WithRetryable when set.
+	Retryable func(error) bool `json:"-" yaml:"-"`
+	// QueueDepth enables WithQueue with QueuePolicy when set.
+	QueueDepth  int         `json:"queue_depth" yaml:"queue_depth"`
+	QueuePolicy QueuePolicy `json:"queue_policy" yaml:"queue_policy"`
+	// Async enables WithAsync.
+	Async bool `json:"async" yaml:"async"`
+	// OnFlush enables WithOnFlush when set.
+	OnFlush func(FlushStats) `json:"-" yaml:"-"`
+	// SelfMetrics enables WithSelfMetrics.
+	SelfMetrics bool `json:"self_metrics" yaml:"self_metrics"`
+	// WritesPerSecond and PointsPerSecond enable WithRateLimit with LimitPolicy when either is set.
+	WritesPerSecond float64     `json:"writes_per_second" yaml:"writes_per_second"`
+	PointsPerSecond float64     `json:"points_per_second" yaml:"points_per_second"`
+	LimitPolicy     LimitPolicy `json:"limit_policy" yaml:"limit_policy"`
+	// StartupCheck enables WithStartupCheck.
+	StartupCheck bool `json:"startup_check" yaml:"startup_check"`
+	// DisablePing enables WithoutPing.
+	DisablePing bool `json:"disable_ping" yaml:"disable_ping"`
+	// WALPath enables WithWAL when set, WALMaxSize is its size limit in bytes.
+	WALPath    string `json:"wal_path" yaml:"wal_path"`
+	WALMaxSize int64  `json:"wal_max_size" yaml:"wal_max_size"`
+
+	// RegistrySnapshot enables WithRegistrySnapshot.
+	RegistrySnapshot bool `json:"registry_snapshot" yaml:"registry_snapshot"`
+
+	// SkipUnchanged and SkipUnchangedFlush enable WithSkipUnchanged and WithSkipUnchangedFlush.
+	SkipUnchanged      bool `json:"skip_unchanged" yaml:"skip_unchanged"`
+	SkipUnchangedFlush bool `json:"skip_unchanged_flush" yaml:"skip_unchanged_flush"`
+	// SkipIdleHistograms enables WithSkipIdleHistograms.
+	SkipIdleHistograms bool `json:"skip_idle_histograms" yaml:"skip_idle_histograms"`
+
+	// MeterDelta enables WithMeterDelta.
+	MeterDelta bool `json:"meter_delta" yaml:"meter_delta"`
+	// Deltas enables WithDeltas for the listed metric types.
+	Deltas []MetricType `json:"deltas" yaml:"deltas"`
+	// ResetPolicy enables WithResetPolicy when set.
+	ResetPolicy ResetPolicy `json:"reset_policy" yaml:"reset_policy"`
+	// ResetAfterRead enables WithResetAfterRead.
+	ResetAfterRead bool `json:"reset_after_read" yaml:"reset_after_read"`
+	// UnsignedCounters enables WithUnsignedCounters.
+	UnsignedCounters bool `json:"unsigned_counters" yaml:"unsigned_counters"`
+	// SampleFraction enables WithSampling when set.
+	SampleFraction float64 `json:"sample_fraction" yaml:"sample_fraction"`
+	// TotalSeries enables WithTotalSeries.
+	TotalSeries bool `json:"total_series" yaml:"total_series"`
+	// LastUpdated enables WithLastUpdated.
+	LastUpdated bool `json:"last_updated" yaml:"last_updated"`
+	// FloatGauges enables WithFloatGauges.
+	FloatGauges bool `json:"float_gauges" yaml:"float_gauges"`
+	// EmitIf enables WithEmitIf when set.
+	EmitIf func(name string, value float64) bool `json:"-" yaml:"-"`
+	// Sums enables WithSums.
+	Sums bool `json:"sums" yaml:"sums"`
+	// BoolGauges enables WithBoolGauges for the listed patterns.
+	BoolGauges []string `json:"bool_gauges" yaml:"bool_gauges"`
+	// Percentiles and PercentileNames enable WithPercentiles and WithPercentileNames when not nil, so that
+	// an empty list such as "percentiles": [] in JSON writes no percentiles.
+	// The keys of PercentileNames are the quantiles formatted as decimal numbers such as "0.99".
+	Percentiles     []float64         `json:"percentiles" yaml:"percentiles"`
+	PercentileNames map[string]string `json:"percentile_names" yaml:"percentile_names"`
+	// QuantileSeries enables WithQuantileSeries.
+	QuantileSeries bool `json:"quantile_series" yaml:"quantile_series"`
+	// MinSamples enables WithMinSamples when set.
+	MinSamples int `json:"min_samples" yaml:"min_samples"`
+	// MaxPercentiles enables WithMaxPercentiles when not zero.
+	MaxPercentiles int `json:"max_percentiles" yaml:"max_percentiles"`
+	// PercentileCache enables WithPercentileCache.
+	PercentileCache bool `json:"percentile_cache" yaml:"percentile_cache"`
+	// MeterRates and TimerRates enable WithMeterRates and WithTimerRates when set.
+	MeterRates map[RateWindow]string `json:"meter_rates" yaml:"meter_rates"`
+	TimerRates map[RateWindow]string `json:"timer_rates" yaml:"timer_rates"`
+	// ConsistentMeanRate enables WithConsistentMeanRate.
+	ConsistentMeanRate bool `json:"consistent_mean_rate" yaml:"consistent_mean_rate"`
+	// PartialWrites enables WithPartialWritePolicy when set.
+	PartialWrites PartialWritePolicy `json:"partial_writes" yaml:"partial_writes"`
+	// Duplicates enables WithDuplicatePolicy when set.
+	Duplicates DuplicatePolicy `json:"duplicates" yaml:"duplicates"`
+	// FieldNames enables WithFieldName for every metric type, field and name it holds.
+	FieldNames map[MetricType]map[string]string `json:"field_names" yaml:"field_names"`
+	// RateDecimals enables WithRateDecimals when set.
+	RateDecimals *int `json:"rate_decimals" yaml:"rate_decimals"`
+	// RateUnit enables WithRateUnit when set.
+	RateUnit time.Duration `json:"rate_unit" yaml:"rate_unit"`
+	// DurationUnit and DurationDecimals enable WithDurationUnit when either is set.
+	DurationUnit     time.Duration `json:"duration_unit" yaml:"duration_unit"`
+	DurationDecimals *int          `json:"duration_decimals" yaml:"duration_decimals"`
+
+	// SocketNetwork and SocketAddress, when set, send the points to a socket with NewSocketWriter
+	// instead of the InfluxDB HTTP API. The network defaults to "unix".
+	SocketNetwork string `json:"socket_network" yaml:"socket_network"`
+	SocketAddress string `json:"socket_address" yaml:"socket_address"`
+	// HTTPEndpoint, when set, posts the points to a line protocol endpoint with NewHTTPWriter
+	// instead of the InfluxDB HTTP API. It can't be set with SocketAddress.
+	HTTPEndpoint string `json:"http_endpoint" yaml:"http_endpoint"`
+	// Fallback enables WithFallback when set.
+	Fallback Writer `json:"-" yaml:"-"`
+}
+
+// Route holds the settings of WithRoute.
+type Route struct {
+	Pattern         string `json:"pattern" yaml:"pattern"`
+	Database        string `json:"database" yaml:"database"`
+	RetentionPolicy string `json:"retention_policy" yaml:"retention_policy"`
+}
+
+// NewFromConfig validates c and creates the reporter it describes. Call Run to start reporting.
+// Errors are reported like New does.
+func NewFromConfig(c Config) (*Reporter, error) {
+	if c.Registry == nil {
+		c.Registry = metrics.DefaultRegistry
+	}
+	if c.Interval < 0 {
+		return nil, &ConfigError{errors.New("interval must not be negative")}
+	}
+	if c.Interval == 0 {
+		c.Interval = DefaultInterval
+	}
+	if c.URL == "" {
+		c.URL = DefaultURL
+	}
+	if c.Database == "" && c.SocketAddress == "" && c.HTTPEndpoint == "" {
+		return nil, &ConfigError{errors.New("database is required")}
+	}
+	if c.SocketAddress != "" && c.HTTPEndpoint != "" {
+		return nil, &ConfigError{errors.New("socket address and HTTP endpoint are exclusive")}
+	}
+
+	opts := []Option{WithTags(c.Tags)}
+	if c.Name != "" {
+		opts = append(opts, WithName(c.Name))
+	}
+	if c.Logger != nil {
+		opts = append(opts, WithLogger(c.Logger))
+	}
+	if c.Layout != "" {
+		opts = append(opts, WithLayout(c.Layout))
+	}
+	if c.Credentials != nil {
+		opts = append(opts, WithCredentials(c.Credentials))
+	}
+	if c.ConfigFetcher != nil {
+		opts = append(opts, WithConfigFetcher(c.ConfigFetcher, c.ConfigFetchInterval))
+	}
+	if c.NormalizedNames {
+		opts = append(opts, WithNormalizedNames())
+	}
+	if c.CounterRate {
+		opts = append(opts, WithCounterRate())
+	}
+	if c.Uptime {
+		opts = append(opts, WithUptime(c.UptimeName))
+	}
+	if c.Annotations {
+		opts = append(opts, WithAnnotations(c.AnnotationsName))
+	}
+	for t, tmpl := range c.MeasurementTemplates {
+		opts = append(opts, WithMeasurementTemplate(t, tmpl))
+	}
+	if len(c.Groups) > 0 {
+		opts = append(opts, WithGroups(c.Groups...))
+	}
+	if c.DatabaseFunc != nil {
+		opts = append(opts, WithDatabaseFunc(c.DatabaseFunc))
+	}
+	for _, rt := range c.Routes {
+		opts = append(opts, WithRoute(rt.Pattern, rt.Database, rt.RetentionPolicy))
+	}
+	if c.SuffixSeparator != "" {
+		opts = append(opts, WithSuffixSeparator(c.SuffixSeparator))
+	}
+	if c.DotReplacement != "" {
+		opts = append(opts, WithDotReplacement(c.DotReplacement))
+	}
+	if c.DedupSuffixes {
+		opts = append(opts, WithoutDuplicateSuffixes())
+	}
+	if c.BuildInfo {
+		opts = append(opts, WithBuildInfo())
+	}
+	if c.Heartbeat {
+		opts = append(opts, WithHeartbeat(c.HeartbeatName))
+	}
+	if c.WriteTimer {
+		opts = append(opts, WithWriteTimer(c.WriteTimerName))
+	}
+	if c.RuntimeInfo {
+		opts = append(opts, WithRuntimeInfo(c.RuntimeInfoName))
+	}
+	if c.RuntimeStatsInterval != 0 {
+		opts = append(opts, WithRuntimeStats(c.RuntimeStatsInterval))
+	}
+	if c.SeriesWarning != 0 {
+		opts = append(opts, WithSeriesWarning(c.SeriesWarning))
+	}
+	if len(c.AllowedTagKeys) > 0 {
+		opts = append(opts, WithAllowedTagKeys(c.AllowedTagKeys...))
+	}
+	patterns := make([]string, 0, len(c.MetricTags))
+	for pattern := range c.MetricTags {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+	for _, pattern := range patterns {
+		opts = append(opts, WithMetricTags(pattern, c.MetricTags[pattern]))
+	}
+	if c.RegistryTag != "" {
+		opts = append(opts, WithRegistryTag(c.RegistryTag))
+	}
+	if c.Precision != "" {
+		opts = append(opts, WithPrecision(c.Precision))
+	}
+	if len(c.EnabledTypes) > 0 {
+		opts = append(opts, WithEnabledTypes(c.EnabledTypes...))
+	}
+	if c.IntervalTag != "" {
+		opts = append(opts, WithIntervalTag(c.IntervalTag))
+	}
+	if c.SourceTag != "" {
+		opts = append(opts, WithSourceTag(c.SourceTag))
+	}
+	for t, tags := range c.TypeTags {
+		opts = append(opts, WithTypeTags(t, tags))
+	}
+	for t, d := range c.TypeIntervals {
+		opts = append(opts, WithTypeInterval(t, d))
+	}
+	for t, p := range c.TypePrecisions {
+		opts = append(opts, WithTypePrecision(t, p))
+	}
+	if c.Timestamps != nil {
+		opts = append(opts, WithTimestamps(c.Timestamps))
+	}
+	if c.TimestampTruncation != 0 {
+		opts = append(opts, WithTimestampTruncation(c.TimestampTruncation))
+	}
+	if c.TimeOffset != 0 {
+		opts = append(opts, WithTimeOffset(c.TimeOffset))
+	}
+	if c.HTTP2 {
+		opts = append(opts, WithHTTP2())
+	}
+	if c.WriteTimeout != 0 {
+		opts = append(opts, WithWriteTimeout(c.WriteTimeout))
+	}
+	if c.FloatFormat != "" {
+		if len(c.FloatFormat) != 1 {
+			return nil, &ConfigError{fmt.Errorf("unsupported float format %q", c.FloatFormat)}
+		}
+		f := FloatFormat{Format: c.FloatFormat[0], Precision: -1}
+		if c.FloatPrecision != nil {
+			f.Precision = *c.FloatPrecision
+		}
+		opts = append(opts, WithFloatFormat(f))
+	}
+	if c.MaxPointBytes != 0 {
+		opts = append(opts, WithMaxPointBytes(c.MaxPointBytes))
+	}
+	if c.MaxBatchBytes != 0 {
+		opts = append(opts, WithMaxBatchBytes(c.MaxBatchBytes))
+	}
+	if c.CompressionRatio != 0 {
+		opts = append(opts, WithCompressionRatio(c.CompressionRatio))
+	}
+	if c.RetryAttempts != 0 {
+		opts = append(opts, WithRetry(c.RetryAttempts, c.RetryBackoff))
+	}
+	if c.RetryBudget != 0 {
+		opts = append(opts, WithRetryBudget(c.RetryBudget))
+	}
+	if c.BreakerFailures != 0 {
+		opts = append(opts, WithCircuitBreaker(c.BreakerFailures, c.BreakerCooldown))
+	}
+	if c.Retryable != nil {
+		opts = append(opts, WithRetryable(c.Retryable))
+	}
+	if c.QueueDepth != 0 {
+		opts = append(opts, WithQueue(c.QueueDepth, c.QueuePolicy))
+	}
+	if c.Async {
+		opts = append(opts, WithAsync())
+	}
+	if c.OnFlush != nil {
+		opts = append(opts, WithOnFlush(c.OnFlush))
+	}
+	if c.SelfMetrics {
+		opts = append(opts, WithSelfMetrics())
+	}
+	if c.WritesPerSecond != 0 || c.PointsPerSecond != 0 {
+		opts = append(opts, WithRateLimit(c.WritesPerSecond, c.PointsPerSecond, c.LimitPolicy))
+	}
+	if c.StartupCheck {
+		opts = append(opts, WithStartupCheck())
+	}
+	if c.DisablePing {
+		opts = append(opts, WithoutPing())
+	}
+	if c.WALPath != "" {
+		opts = append(opts, WithWAL(c.WALPath, c.WALMaxSize))
+	}
+	if c.RegistrySnapshot {
+		opts = append(opts, WithRegistrySnapshot())
+	}
+	if c.SkipUnchanged {
+		opts = append(opts, WithSkipUnchanged())
+	}
+	if c.SkipUnchangedFlush {
+		opts = append(opts, WithSkipUnchangedFlush())
+	}
+	if c.SkipIdleHistograms {
+		opts = append(opts, WithSkipIdleHistograms())
+	}
+	if c.MeterDelta {
+		opts = append(opts, WithMeterDelta())
+	}
+	if len(c.Deltas) > 0 {
+		opts = append(opts, WithDeltas(c.Deltas...))
+	}
+	if c.ResetPolicy != "" {
+		opts = append(opts, WithResetPolicy(c.ResetPolicy))
+	}
+	if c.ResetAfterRead {
+		opts = append(opts, WithResetAfterRead())
+	}
+	if c.UnsignedCounters {
+		opts = append(opts, WithUnsignedCounters())
+	}
+	if c.SampleFraction != 0 {
+		opts = append(opts, WithSampling(c.SampleFraction))
+	}
+	if c.TotalSeries {
+		opts = append(opts, WithTotalSeries())
+	}
+	if c.LastUpdated {
+		opts = append(opts, WithLastUpdated())
+	}
+	if c.FloatGauges {
+		opts = append(opts, WithFloatGauges())
+	}
+	if c.EmitIf != nil {
+		opts = append(opts, WithEmitIf(c.EmitIf))
+	}
+	if c.Sums {
+		opts = append(opts, WithSums())
+	}
+	if len(c.BoolGauges) > 0 {
+		opts = append(opts, WithBoolGauges(c.BoolGauges...))
+	}
+	if c.Percentiles != nil {
+		opts = append(opts, WithPercentiles(c.Percentiles...))
+	}
+	if c.PercentileNames != nil {
+		names := make(map[float64]string, len(c.PercentileNames))
+		for k, name := range c.PercentileNames {
+			q, err := strconv.ParseFloat(k, 64)
+			if err != nil {
+				return nil, &ConfigError{fmt.Errorf("invalid percentile %q: %v", k, err)}
+			}
+			names[q] = name
+		}
+		opts = append(opts, WithPercentileNames(names))
+	}
+	if c.QuantileSeries {
+		opts = append(opts, WithQuantileSeries())
+	}
+	if c.MinSamples != 0 {
+		opts = append(opts, WithMinSamples(c.MinSamples))
+	}
+	if c.MaxPercentiles != 0 {
+		opts = append(opts, WithMaxPercentiles(c.MaxPercentiles))
+	}
+	if c.PercentileCache {
+		opts = append(opts, WithPercentileCache())
+	}
+	if c.MeterRates != nil {
+		opts = append(opts, WithMeterRates(c.MeterRates))
+	}
+	if c.TimerRates != nil {
+		opts = append(opts, WithTimerRates(c.TimerRates))
+	}
+	if c.ConsistentMeanRate {
+		opts = append(opts, WithConsistentMeanRate())
+	}
+	for t, names := range c.FieldNames {
+		for field, name := range names {
+			opts = append(opts, WithFieldName(t, field, name))
+		}
+	}
+	if c.PartialWrites != "" {
+		opts = append(opts, WithPartialWritePolicy(c.PartialWrites))
+	}
+	if c.Duplicates != "" {
+		opts = append(opts, WithDuplicatePolicy(c.Duplicates))
+	}
+	if c.RateDecimals != nil {
+		opts = append(opts, WithRateDecimals(*c.RateDecimals))
+	}
+	if c.RateUnit != 0 {
+		opts = append(opts, WithRateUnit(c.RateUnit))
+	}
+	if c.DurationUnit != 0 || c.DurationDecimals != nil {
+		n := -1
+		if c.DurationDecimals != nil {
+			n = *c.DurationDecimals
+		}
+		opts = append(opts, WithDurationUnit(c.DurationUnit, n))
+	}
+	if c.SocketAddress != "" {
+		network := c.SocketNetwork
+		if network == "" {
+			network = "unix"
+		}
+		opts = append(opts, WithWriter(NewSocketWriter(network, c.SocketAddress)))
+	}
+	if c.HTTPEndpoint != "" {
+		w, err := NewHTTPWriter(c.HTTPEndpoint, nil)
+		if err != nil {
+			return nil, &ConfigError{err}
+		}
+		opts = append(opts, WithWriter(w))
+	}
+	if c.Fallback != nil {
+		opts = append(opts, WithFallback(c.Fallback))
+	}
+
+	return New(c.Registry, c.Interval, c.URL, c.Database, c.Username, c.Password, opts...)
+}
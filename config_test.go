@@ -0,0 +1,103 @@
+package influxdb
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+func TestNewFromConfig(t *testing.T) {
+	r, err := NewFromConfig(Config{Database: "db"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Stop()
+	if r.reg != metrics.DefaultRegistry || r.interval != DefaultInterval || r.url.String() != DefaultURL || r.database != "db" {
+		t.Errorf("got registry %v, interval %v and url %v, want the defaults", r.reg, r.interval, r.url.String())
+	}
+
+	var c Config
+	if err := json.Unmarshal([]byte(`{"interval": 30000000000, "socket_address": "/tmp/influxdb.sock", "tags": {"host": "web1"}}`), &c); err != nil {
+		t.Fatal(err)
+	}
+	c.Registry = metrics.NewRegistry()
+	if r, err = NewFromConfig(c); err != nil {
+		t.Fatal(err)
+	}
+	r.Stop()
+	sw, ok := r.writer.(*socketWriter)
+	if !ok || sw.network != "unix" || sw.address != "/tmp/influxdb.sock" {
+		t.Errorf("got writer %#v, want a unix socket writer", r.writer)
+	}
+	if r.reg != c.Registry || r.interval != 30*time.Second || r.tags["host"] != "web1" {
+		t.Errorf("got registry %v, interval %v and tags %v, want the configured ones", r.reg, r.interval, r.tags)
+	}
+}
+
+func TestNewFromConfigErrors(t *testing.T) {
+	two := 2
+	for _, tt := range []struct {
+		name string
+		c    Config
+	}{
+		{"no database", Config{}},
+		{"negative interval", Config{Database: "db", Interval: -time.Second}},
+		{"both writers", Config{SocketAddress: "/tmp/influxdb.sock", HTTPEndpoint: "http://localhost:8080/write"}},
+		{"float format", Config{Database: "db", FloatFormat: "fg", FloatPrecision: &two}},
+		{"percentile name", Config{Database: "db", PercentileNames: map[string]string{"p99": "p99"}}},
+		{"option", Config{Database: "db", Precision: "invalid"}},
+	} {
+		tt.c.Registry = metrics.NewRegistry()
+		_, err := NewFromConfig(tt.c)
+		var ce *ConfigError
+		if !errors.As(err, &ce) {
+			t.Errorf("%s: got error %v, want a *ConfigError", tt.name, err)
+		}
+	}
+}
+
+func TestNewFromConfigPercentiles(t *testing.T) {
+	for _, tt := range []struct {
+		json string
+		want int
+	}{
+		{`{"database": "db"}`, len(defaultPercentiles)},
+		{`{"database": "db", "percentiles": [0.5]}`, 1},
+		// an empty list disables the percentiles rather than keeping the default
+		{`{"database": "db", "percentiles": []}`, 0},
+	} {
+		var c Config
+		if err := json.Unmarshal([]byte(tt.json), &c); err != nil {
+			t.Fatal(err)
+		}
+		c.Registry = metrics.NewRegistry()
+		r, err := NewFromConfig(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Stop()
+		if len(r.percentiles) != tt.want {
+			t.Errorf("%s: got percentiles %v, want %d", tt.json, r.percentiles, tt.want)
+		}
+	}
+}
+
+func TestNewFromConfigReporterPoints(t *testing.T) {
+	var c Config
+	if err := json.Unmarshal([]byte(`{"database": "db", "uptime": true, "heartbeat": true, "heartbeat_name": "alive", "annotations": true}`), &c); err != nil {
+		t.Fatal(err)
+	}
+	c.Registry = metrics.NewRegistry()
+	r, err := NewFromConfig(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Stop()
+	// the measurements default like those of the options
+	if r.uptime != DefaultUptimeMeasurement || r.heartbeat != "alive" || r.annotations != DefaultAnnotationMeasurement || r.runtimeInfo != "" {
+		t.Errorf("got uptime %q, heartbeat %q, annotations %q and runtime info %q measurements", r.uptime, r.heartbeat, r.annotations, r.runtimeInfo)
+	}
+}
@@ -0,0 +1,38 @@
+package influxdb
+
+import "time"
+
+// counterSample is the count of a counter at the time of a flush, as given by the clock of the reporter.
+type counterSample struct {
+	count int64
+	time  time.Time
+}
+
+// addCounterRate adds to fields the per second rate of the counter identified by id since
+// the previous flush, see WithCounterRate. Nothing is added on the first flush of the counter,
+// nor when its count decreased since the previous flush, since its value was then reset
+// at an unknown time. cleared tells whether the counter is cleared after each read, as
+// WithResetAfterRead does for go-metrics counters.
+func (r *Reporter) addCounterRate(fields map[string]interface{}, id string, count int64, cleared bool, now time.Time) {
+	if r.counterRates == nil {
+		return
+	}
+
+	prev, ok := r.counterRates[id]
+	if !r.peeking {
+		r.counterRates[id] = counterSample{count: count, time: now}
+	}
+	if !ok {
+		return
+	}
+
+	if cleared {
+		// the counter was cleared by the previous flush, its count is the delta
+		prev.count = 0
+	}
+	elapsed := now.Sub(prev.time).Seconds()
+	if elapsed <= 0 || count < prev.count {
+		return
+	}
+	fields["rate"] = r.format.rate(float64(count-prev.count) / elapsed)
+}
@@ -0,0 +1,62 @@
+package influxdb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestCounterRate(t *testing.T) {
+	reg := metrics.NewRegistry()
+	c := metrics.GetOrRegisterCounter("requests", reg)
+	w := &testWriter{}
+	r := newTestReporter(t, reg, w, WithCounterRate())
+	now := fixedClock(r)
+
+	steps := []struct {
+		inc     int64
+		elapsed time.Duration
+		rate    interface{}
+	}{
+		// no rate on the first flush
+		{10, 0, nil},
+		{20, 10 * time.Second, 2.0},
+		{0, 5 * time.Second, 0.0},
+		// the counter was reset, no rate
+		{-25, 10 * time.Second, nil},
+		{6, 2 * time.Second, 3.0},
+	}
+	for i, s := range steps {
+		c.Inc(s.inc)
+		*now = now.Add(s.elapsed)
+		w.batches = nil
+		flush(t, r)
+		pts := w.find("requests.count")
+		if len(pts) != 1 {
+			t.Fatalf("flush %d: got points %v", i, w.points())
+		}
+		if rate := pts[0].Fields["rate"]; rate != s.rate {
+			t.Errorf("flush %d: got rate %v, want %v", i, rate, s.rate)
+		}
+		if pts[0].Fields["value"] != c.Count() {
+			t.Errorf("flush %d: got value %v, want %d", i, pts[0].Fields["value"], c.Count())
+		}
+	}
+
+	// with reset after read, the count of a flush is its delta
+	r.Stop()
+	c.Clear()
+	w = &testWriter{}
+	r = newTestReporter(t, reg, w, WithCounterRate(), WithResetAfterRead())
+	now = fixedClock(r)
+	c.Inc(5)
+	flush(t, r)
+	c.Inc(8)
+	*now = now.Add(4 * time.Second)
+	w.batches = nil
+	flush(t, r)
+	if pts := w.find("requests.count"); len(pts) != 1 || pts[0].Fields["rate"] != 2.0 {
+		t.Errorf("got points %v, want a rate of 2", pts)
+	}
+}
@@ -0,0 +1,55 @@
+package influxdb
+
+import (
+	"fmt"
+	uurl "net/url"
+)
+
+// Credentials returns the username and password used to authenticate to InfluxDB.
+// It is called for every write, and every time the InfluxDB client used for pings is created,
+// so that rotated credentials are picked up without restarting the reporter.
+type Credentials func() (username, password string, err error)
+
+// staticCredentials returns Credentials always returning the given username and password.
+func staticCredentials(username, password string) Credentials {
+	return func() (string, string, error) {
+		return username, password, nil
+	}
+}
+
+// String describes the reporter without its credentials, so that it can safely be logged.
+func (r *Reporter) String() string {
+	return fmt.Sprintf("influxdb.Reporter{url=%s database=%s interval=%v}", redactURL(r.url), r.database, r.getInterval())
+}
+
+// String describes the configuration with its password redacted, so that it can safely be logged.
+func (c Config) String() string {
+	u, err := uurl.Parse(c.URL)
+	url := "<invalid>"
+	if err == nil {
+		url = redactURL(*u)
+	}
+	password := ""
+	if c.Password != "" {
+		password = redacted
+	}
+
+	return fmt.Sprintf("influxdb.Config{url=%s database=%s username=%s password=%s interval=%v}", url, c.Database, c.Username, password, c.Interval)
+}
+
+// GoString redacts the password like String does for the %#v verb.
+func (c Config) GoString() string {
+	return c.String()
+}
+
+const redacted = "xxxxx"
+
+// redactURL returns u with the password of its user info redacted.
+func redactURL(u uurl.URL) string {
+	if u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = uurl.UserPassword(u.User.Username(), redacted)
+		}
+	}
+	return u.String()
+}
@@ -0,0 +1,67 @@
+package influxdb
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testPassword = "s3cr3t-p4ss"
+
+func TestCredentialsArentLeaked(t *testing.T) {
+	c := Config{URL: "http://admin:" + testPassword + "@localhost:8086", Database: "db", Username: "admin", Password: testPassword}
+	for _, verb := range []string{"%v", "%+v", "%#v", "%s"} {
+		if s := fmt.Sprintf(verb, c); strings.Contains(s, testPassword) {
+			t.Errorf("%s of the configuration leaks the password: %s", verb, s)
+		}
+	}
+
+	var authorized bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if _, password, ok := req.BasicAuth(); ok && password == testPassword {
+			authorized = true
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":"authorization failed"}`))
+	}))
+	defer srv.Close()
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	for _, url := range []string{srv.URL, strings.Replace(closed.URL, "http://", "http://admin:"+testPassword+"@", 1)} {
+		l := &testLogger{}
+		r, err := New(newRegistryWithCounter(), time.Minute, url, "db", "admin", testPassword, WithLogger(l), WithRetry(1, time.Millisecond))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, verb := range []string{"%v", "%s"} {
+			if s := fmt.Sprintf(verb, r); strings.Contains(s, testPassword) {
+				t.Errorf("%s of the reporter leaks the password: %s", verb, s)
+			}
+		}
+
+		err = r.Flush(context.Background())
+		r.Stop()
+		if err == nil {
+			t.Fatalf("got no error writing to %s", url)
+		}
+		if strings.Contains(err.Error(), testPassword) {
+			t.Errorf("error leaks the password: %v", err)
+		}
+		if err := r.LastWriteError(); err != nil && strings.Contains(err.Error(), testPassword) {
+			t.Errorf("last error leaks the password: %v", err)
+		}
+		for _, msg := range l.messages() {
+			if strings.Contains(msg, testPassword) {
+				t.Errorf("log leaks the password: %s", msg)
+			}
+		}
+	}
+	if !authorized {
+		t.Error("the password wasn't sent to the server")
+	}
+}
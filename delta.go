@@ -0,0 +1,53 @@
+package influxdb
+
+// ResetPolicy selects how a delta is computed when a cumulative count decreases between two flushes,
+// which means the metric was reset or the process restarted.
+type ResetPolicy string
+
+const (
+	// ResetAssume takes the current count as the delta, assuming the count restarted from zero.
+	// This is the default.
+	ResetAssume ResetPolicy = "assume"
+	// ResetDrop omits the delta from the flush which detected the reset.
+	ResetDrop ResetPolicy = "drop"
+)
+
+// deltaTracker computes the difference between successive values of cumulative counts.
+type deltaTracker struct {
+	policy ResetPolicy
+	prev   map[string]int64
+}
+
+func newDeltaTracker() *deltaTracker {
+	return &deltaTracker{
+		policy: ResetAssume,
+		prev:   make(map[string]int64),
+	}
+}
+
+// delta returns the non-negative difference between count and the count given for key at the previous call.
+// The first count of a key is reported in full. ok is false when no delta must be reported.
+func (t *deltaTracker) delta(key string, count int64) (d int64, ok bool) {
+	prev, seen := t.prev[key]
+	t.prev[key] = count
+	return t.diff(prev, seen, count)
+}
+
+// peek returns the delta that delta would return, without recording count.
+func (t *deltaTracker) peek(key string, count int64) (d int64, ok bool) {
+	prev, seen := t.prev[key]
+	return t.diff(prev, seen, count)
+}
+
+func (t *deltaTracker) diff(prev int64, seen bool, count int64) (d int64, ok bool) {
+	switch {
+	case !seen:
+		return count, true
+	case count >= prev:
+		return count - prev, true
+	case t.policy == ResetDrop:
+		return 0, false
+	default:
+		return count, true
+	}
+}
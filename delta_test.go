@@ -0,0 +1,89 @@
+package influxdb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestDeltaTracker(t *testing.T) {
+	tests := []struct {
+		policy ResetPolicy
+		counts []int64
+		want   []int64
+	}{
+		// -1 stands for no delta
+		{ResetAssume, []int64{5, 8, 8, 12}, []int64{5, 3, 0, 4}},
+		{ResetAssume, []int64{5, 8, 2, 4}, []int64{5, 3, 2, 2}},
+		{ResetDrop, []int64{5, 8, 2, 4}, []int64{5, 3, -1, 2}},
+		{ResetDrop, []int64{0, 0}, []int64{0, 0}},
+	}
+	for _, tt := range tests {
+		dt := newDeltaTracker()
+		dt.policy = tt.policy
+		for i, count := range tt.counts {
+			peeked, peekedOK := dt.peek("k", count)
+			d, ok := dt.delta("k", count)
+			if !ok {
+				d = -1
+			}
+			if d != tt.want[i] {
+				t.Errorf("%s %v: got delta %d at %d, want %d", tt.policy, tt.counts, d, i, tt.want[i])
+			}
+			if peekedOK != ok || ok && peeked != d {
+				t.Errorf("%s %v: peeked %d at %d, want %d", tt.policy, tt.counts, peeked, i, d)
+			}
+		}
+	}
+
+	// keys are tracked separately
+	dt := newDeltaTracker()
+	dt.delta("a", 10)
+	if d, _ := dt.delta("b", 3); d != 3 {
+		t.Errorf("got delta %d of a new key, want its count", d)
+	}
+}
+
+func TestDeltas(t *testing.T) {
+	reg := metrics.NewRegistry()
+	c := metrics.GetOrRegisterCounter("requests", reg)
+	m := metrics.GetOrRegisterMeter("events", reg)
+	tm := metrics.GetOrRegisterTimer("latency", reg)
+	h := metrics.GetOrRegisterHistogram("sizes", reg, metrics.NewUniformSample(100))
+	w := &testWriter{}
+	r := newTestReporter(t, reg, w, WithDeltas(TypeCounter, TypeMeter, TypeTimer, TypeHistogram), WithResetPolicy(ResetDrop))
+
+	for _, n := range []int64{2, 3} {
+		c.Inc(n)
+		m.Mark(n)
+		for i := int64(0); i < n; i++ {
+			tm.Update(time.Millisecond)
+			h.Update(i)
+		}
+		w.batches = nil
+		flush(t, r)
+		for _, name := range []string{"requests.count", "events.meter", "latency.timer", "sizes.histogram"} {
+			pts := w.find(name)
+			if len(pts) != 1 || pts[0].Fields["delta"] != n {
+				t.Errorf("got points %v of %s, want a delta of %d", pts, name, n)
+			}
+		}
+	}
+
+	// the counter decreased, the delta is dropped by the policy
+	c.Dec(4)
+	w.batches = nil
+	flush(t, r)
+	pts := w.find("requests.count")
+	if len(pts) != 1 {
+		t.Fatalf("got points %v of the counter, want 1", pts)
+	}
+	if d, ok := pts[0].Fields["delta"]; ok {
+		t.Errorf("got delta %v of a reset counter, want none", d)
+	}
+
+	if _, err := BuildPoints(reg, nil, time.Now(), WithResetPolicy("ignore")); err == nil {
+		t.Error("got no error for an unknown reset policy")
+	}
+}
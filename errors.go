@@ -0,0 +1,169 @@
+package influxdb
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ConfigError reports an invalid setting given to a constructor.
+// Creating the reporter again with the same settings fails the same way.
+type ConfigError struct {
+	Err error
+}
+
+func (e *ConfigError) Error() string {
+	return "invalid InfluxDB reporter configuration: " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// ConnectError reports that the InfluxDB server couldn't be reached when checked at startup,
+// see WithStartupCheck. The failure may be transient, so the caller can retry creating the reporter.
+type ConnectError struct {
+	Err error
+}
+
+func (e *ConnectError) Error() string {
+	return "unable to reach InfluxDB: " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *ConnectError) Unwrap() error {
+	return e.Err
+}
+
+// Temporary reports that the error may go away on retry.
+func (e *ConnectError) Temporary() bool {
+	return true
+}
+
+// PartialWritePolicy selects how a write is handled when InfluxDB drops some of its points.
+type PartialWritePolicy string
+
+const (
+	// PartialWriteAccept logs the dropped points and handles the write as successful, since
+	// writing the batch again would drop the same points. This is the default.
+	PartialWriteAccept PartialWritePolicy = "accept"
+	// PartialWriteFail handles the write as failed, like any other error.
+	PartialWriteFail PartialWritePolicy = "fail"
+)
+
+// PartialWriteError reports that InfluxDB accepted a batch but dropped some of its points,
+// for example because of a field type conflict. Reason holds the explanation of the server,
+// which names the offending measurement and field.
+type PartialWriteError struct {
+	Reason  string
+	Dropped int
+}
+
+func (e *PartialWriteError) Error() string {
+	return fmt.Sprintf("partial write: %s dropped=%d", e.Reason, e.Dropped)
+}
+
+// droppedRe matches the number of dropped points ending the message of a partial write.
+var droppedRe = regexp.MustCompile(`\s*dropped=(\d+)\s*$`)
+
+// partialWrite returns the partial write reported by the error of a write, if it is one.
+// The message is either the JSON error body of the InfluxDB response or contains it.
+func partialWrite(err error) (*PartialWriteError, bool) {
+	if pw, ok := err.(*PartialWriteError); ok {
+		return pw, true
+	}
+
+	msg := err.Error()
+	if i := strings.Index(msg, "{"); i >= 0 {
+		var body struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal([]byte(msg[i:]), &body) == nil && body.Error != "" {
+			msg = body.Error
+		}
+	}
+
+	i := strings.Index(msg, "partial write: ")
+	if i < 0 {
+		return nil, false
+	}
+	reason := msg[i+len("partial write: "):]
+
+	pw := &PartialWriteError{Reason: reason}
+	if m := droppedRe.FindStringSubmatch(reason); m != nil {
+		pw.Reason = reason[:len(reason)-len(m[0])]
+		pw.Dropped, _ = strconv.Atoi(m[1])
+	}
+	return pw, true
+}
+
+// WriteError reports a write rejected by the HTTP server, as returned by the default writer and the Writer
+// of NewHTTPWriter.
+type WriteError struct {
+	StatusCode int
+	Body       string
+	// RetryAfter is the delay requested by the Retry-After header of the response, 0 if there was none.
+	RetryAfter time.Duration
+}
+
+func (e *WriteError) Error() string {
+	return fmt.Sprintf("unable to write: status %d: %s", e.StatusCode, e.Body)
+}
+
+// Temporary reports that the error may go away on retry: the server was unavailable or overloaded.
+func (e *WriteError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// ErrRateLimited reports a write rejected because the server rate limits the client, such as
+// InfluxDB Cloud answering 429 Too Many Requests. errors.Is(err, ErrRateLimited) matches the
+// *WriteError of such a response, and the error returned by the flushes while writes are paused.
+var ErrRateLimited = errors.New("rate limited by the server")
+
+// Is reports whether the write was rejected because of rate limits, see ErrRateLimited.
+func (e *WriteError) Is(target error) bool {
+	return target == ErrRateLimited && e.StatusCode == http.StatusTooManyRequests
+}
+
+// rateLimited returns the delay requested by the server when err reports a write rejected because
+// of rate limits, the interval of the reporter if the server didn't request one.
+func (r *Reporter) rateLimited(err error) (time.Duration, bool) {
+	var we *WriteError
+	if !errors.As(err, &we) || !errors.Is(we, ErrRateLimited) {
+		return 0, false
+	}
+	if we.RetryAfter > 0 {
+		return we.RetryAfter, true
+	}
+	return r.getInterval(), true
+}
+
+// pauseWrites pauses the writes for d after the server rate limited the client.
+func (r *Reporter) pauseWrites(d time.Duration) {
+	r.mu.Lock()
+	r.pausedUntil = r.now().Add(d)
+	r.mu.Unlock()
+
+	r.selfCounter("rate_limited").Inc(1)
+	r.logf("rate limited by InfluxDB, pausing writes for %v", d)
+}
+
+// writesPaused reports whether the writes are paused by pauseWrites.
+func (r *Reporter) writesPaused() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.now().Before(r.pausedUntil)
+}
+
+// pauseLeft returns how long the writes stay paused by pauseWrites.
+func (r *Reporter) pauseLeft() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.pausedUntil.Sub(r.now())
+}
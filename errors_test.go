@@ -0,0 +1,114 @@
+package influxdb
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestRateLimited(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("Retry-After", "7")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"code":"too many requests","message":"write limit exceeded"}`))
+	}))
+	defer srv.Close()
+
+	r, err := New(newRegistryWithCounter(), time.Minute, srv.URL, "db", "", "", WithRetry(3, time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Stop()
+	now := time.Unix(0, 0)
+	r.now = func() time.Time { return now }
+
+	err = r.Flush(context.Background())
+	var we *WriteError
+	if !errors.Is(err, ErrRateLimited) || !errors.As(err, &we) || we.RetryAfter != 7*time.Second {
+		t.Fatalf("got error %v, want a rate limited write retried after 7s", err)
+	}
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Fatalf("got %d requests, want a rate limited write not to be retried", n)
+	}
+
+	// the writes are paused for the delay of the server, the batch stays queued
+	now = now.Add(6 * time.Second)
+	if err := r.Flush(context.Background()); err != ErrRateLimited {
+		t.Fatalf("got error %v while paused, want ErrRateLimited", err)
+	}
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Fatalf("got %d requests while paused, want 1", n)
+	}
+
+	now = now.Add(time.Second)
+	r.Flush(context.Background())
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Fatalf("got %d requests after the pause, want 2", n)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	for v, want := range map[string]time.Duration{
+		"":        0,
+		"120":     2 * time.Minute,
+		"-1":      0,
+		"invalid": 0,
+		time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat): 0,
+	} {
+		if got := retryAfter(v); got != want {
+			t.Errorf("retryAfter(%q) = %v, want %v", v, got, want)
+		}
+	}
+	if d := retryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)); d <= 59*time.Minute || d > time.Hour {
+		t.Errorf("got delay %v for a date in an hour", d)
+	}
+}
+
+func TestConstructorErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("X-Influxdb-Version", "1.8.10")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	for _, url := range []string{"://localhost", "localhost:8086", "ftp://localhost", "http://"} {
+		_, err := New(metrics.NewRegistry(), time.Minute, url, "db", "", "", WithStartupCheck())
+		var ce *ConfigError
+		if !errors.As(err, &ce) {
+			t.Errorf("%s: got error %v, want a *ConfigError", url, err)
+		}
+	}
+
+	_, err := New(metrics.NewRegistry(), time.Minute, closed.URL, "db", "", "", WithStartupCheck())
+	var ce *ConnectError
+	if !errors.As(err, &ce) || !ce.Temporary() {
+		t.Errorf("got error %v for an unreachable server, want a temporary *ConnectError", err)
+	}
+
+	// the reachable server passes the check, and the unreachable one is only checked when asked to
+	for _, url := range []string{srv.URL, closed.URL} {
+		opts := []Option{}
+		if url == srv.URL {
+			opts = append(opts, WithStartupCheck())
+		}
+		r, err := New(metrics.NewRegistry(), time.Minute, url, "db", "", "", opts...)
+		if err != nil {
+			t.Errorf("%s: %v", url, err)
+			continue
+		}
+		r.Stop()
+		if url == srv.URL && r.ServerVersion() != "1.8.10" {
+			t.Errorf("got server version %q, want the one of the startup check", r.ServerVersion())
+		}
+	}
+}
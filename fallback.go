@@ -0,0 +1,23 @@
+package influxdb
+
+import (
+	"context"
+
+	"github.com/influxdata/influxdb/client"
+)
+
+// writeFallback writes bp with the fallback writer set by WithFallback, after its write failed
+// with err. It reports whether the batch was written.
+func (r *Reporter) writeFallback(ctx context.Context, bp client.BatchPoints, err error) bool {
+	if r.fallback == nil {
+		return false
+	}
+
+	if ferr := r.fallback.WriteBatch(ctx, bp); ferr != nil {
+		r.logf("unable to write batch to the fallback writer either. err=%v", ferr)
+		return false
+	}
+	r.selfCounter("fallback_batches").Inc(1)
+	r.logf("unable to write batch to InfluxDB, wrote its %d points to the fallback writer. err=%v", len(bp.Points), err)
+	return true
+}
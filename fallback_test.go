@@ -0,0 +1,72 @@
+package influxdb
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFallback(t *testing.T) {
+	w, fallback := &testWriter{}, &testWriter{}
+	l := &testLogger{}
+	r := newTestReporter(t, newRegistryWithCounter(), w, WithFallback(fallback), WithLogger(l), WithQueue(2, DropOldest))
+	fixedClock(r)
+	w.failWith(errTest)
+
+	// the flush succeeds, its batch being written to the fallback
+	if err := r.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	batches := fallback.written()
+	if len(batches) != 1 {
+		t.Fatalf("got %d batches written to the fallback, want 1", len(batches))
+	}
+	if got := sortedLines(t, batches[0].Points); !reflect.DeepEqual(got, []string{"requests.count value=1i 1000000000000"}) {
+		t.Errorf("got fallback points %q", got)
+	}
+	if batches[0].Database != "db" {
+		t.Errorf("got database %q of the fallback batch, want db", batches[0].Database)
+	}
+	if len(l.messages()) == 0 {
+		t.Error("got no message logged for the failed write")
+	}
+
+	// the batch written to the fallback isn't written again once InfluxDB is back
+	w.failWith(nil)
+	flush(t, r)
+	if n := len(w.points()); n != 1 {
+		t.Errorf("got %d points written to InfluxDB, want the one of the last flush", n)
+	}
+
+	// when the fallback fails too, the batch stays queued
+	w.failWith(errTest)
+	fallback.failWith(errTest)
+	if err := r.Flush(context.Background()); err == nil {
+		t.Fatal("got no error when the fallback fails too")
+	}
+	w.failWith(nil)
+	fallback.failWith(nil)
+	flush(t, r)
+	if n := len(w.points()); n != 3 {
+		t.Errorf("got %d points written to InfluxDB, want the queued batch written", n)
+	}
+	if n := len(fallback.written()); n != 1 {
+		t.Errorf("got %d batches written to the fallback, want 1", n)
+	}
+}
+
+func TestFallbackWithOpenCircuit(t *testing.T) {
+	w, fallback := &testWriter{}, &testWriter{}
+	r := newTestReporter(t, newRegistryWithCounter(), w, WithFallback(fallback), WithCircuitBreaker(1, time.Hour))
+	w.failWith(errTest)
+
+	for i := 0; i < 3; i++ {
+		if err := r.Flush(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := len(fallback.written()); n != 3 {
+		t.Errorf("got %d batches written to the fallback, want those of every flush", n)
+	}
+}
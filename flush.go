@@ -0,0 +1,51 @@
+package influxdb
+
+import (
+	"time"
+
+	"github.com/influxdata/influxdb/client"
+)
+
+// FlushStats describes a flush, as passed to the callback set by WithOnFlush.
+type FlushStats struct {
+	// Start is the time the flush started.
+	Start time.Time
+	// Duration is the time the flush took, including the writes.
+	Duration time.Duration
+	// Points is the number of points of the batch of the flush.
+	Points int
+	// Series is the number of distinct series of the batch of the flush, an estimate.
+	Series int
+	// Bytes is the size of the batch of the flush in line protocol. It is an estimate,
+	// the writer may encode or compress the batch differently.
+	Bytes int
+	// Err is the error of the flush, nil if it succeeded.
+	Err error
+	// Dropped counts the points and batches that weren't written.
+	Dropped DropStats
+}
+
+// DropStats counts what a flush didn't write, by reason.
+type DropStats struct {
+	// Unchanged is the number of points skipped by WithSkipUnchanged or WithSkipUnchangedFlush.
+	Unchanged int
+	// Limited is the number of points held back by WithRateLimit, either dropped or coalesced
+	// with the next flush depending on the policy.
+	Limited int
+	// Oversized is the number of points skipped by WithMaxPointBytes.
+	Oversized int
+	// Invalid is the number of points the line protocol can't represent, such as those with a NaN
+	// or infinite field, or without a measurement.
+	Invalid int
+	// Batches is the number of batches dropped from the queue set by WithQueue.
+	Batches int
+}
+
+// batchSize returns the size of bp in line protocol.
+func batchSize(bp client.BatchPoints, floats FloatFormat) int {
+	var n int
+	for _, line := range lines(bp, floats) {
+		n += len(line)
+	}
+	return n
+}
@@ -0,0 +1,62 @@
+package influxdb
+
+import (
+	"math"
+	"time"
+)
+
+// fieldFormat controls how the float fields of each field group are scaled and rounded.
+// A negative number of decimals leaves the group untouched; counts are never modified.
+type fieldFormat struct {
+	rateUnit     time.Duration
+	rateDecimals int
+
+	durationUnit     time.Duration
+	durationDecimals int
+}
+
+var defaultFieldFormat = fieldFormat{
+	rateDecimals:     -1,
+	durationDecimals: -1,
+}
+
+// rate formats an events per second rate field.
+func (f fieldFormat) rate(v float64) float64 {
+	if f.rateUnit > 0 {
+		v *= f.rateUnit.Seconds()
+	}
+	return round(v, f.rateDecimals)
+}
+
+// duration formats a timer field expressed in nanoseconds.
+func (f fieldFormat) duration(v float64) float64 {
+	if f.durationUnit > 0 {
+		v /= float64(f.durationUnit)
+	}
+	return round(v, f.durationDecimals)
+}
+
+// durationInt formats an integer timer field expressed in nanoseconds.
+// It stays an integer unless a duration unit is set.
+func (f fieldFormat) durationInt(v int64) interface{} {
+	if f.durationUnit <= 0 {
+		return v
+	}
+	return f.duration(float64(v))
+}
+
+// variance formats a timer variance, which is expressed in squared nanoseconds.
+func (f fieldFormat) variance(v float64) float64 {
+	if f.durationUnit > 0 {
+		v /= float64(f.durationUnit) * float64(f.durationUnit)
+	}
+	return round(v, f.durationDecimals)
+}
+
+func round(v float64, decimals int) float64 {
+	if decimals < 0 || math.IsNaN(v) || math.IsInf(v, 0) {
+		return v
+	}
+	p := math.Pow(10, float64(decimals))
+	return math.Round(v*p) / p
+}
@@ -0,0 +1,102 @@
+package influxdb
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/influxdata/influxdb/client"
+)
+
+// pointGroups merges the points of the metrics grouped by WithGroups into one point per group.
+type pointGroups struct {
+	keys []string
+	// the name of the group of every key
+	names []string
+	pts   map[string]*client.Point
+}
+
+// grouped reports whether the metric registered under name with type t is merged into its group,
+// and returns the name of its group and its leaf.
+func (r *Reporter) grouped(name string, t MetricType) (group, leaf string, ok bool) {
+	if len(r.groups) == 0 {
+		return "", "", false
+	}
+	switch t {
+	case TypeCounter, TypeGauge, TypeGaugeFloat64:
+	default:
+		return "", "", false
+	}
+	i := strings.LastIndexByte(name, '.')
+	if i <= 0 || i == len(name)-1 {
+		return "", "", false
+	}
+
+	for _, pattern := range r.groups {
+		// the patterns are checked by validate
+		if ok, _ := path.Match(pattern, name); ok {
+			return name[:i], name[i+1:], true
+		}
+	}
+	return "", "", false
+}
+
+// addToGroup merges p, the point of the metric leaf of group, into the point of its group. The fields of p
+// are renamed after the leaf: "value" becomes the leaf and the others are suffixed with it, such as
+// "user_delta". Groups are told apart by their tags too.
+func (r *Reporter) addToGroup(g *pointGroups, group, leaf string, p client.Point) {
+	key := groupKey(group, p.Tags)
+	gp, ok := g.pts[key]
+	if !ok {
+		if g.pts == nil {
+			g.pts = make(map[string]*client.Point)
+		}
+		gp = &client.Point{
+			Measurement: r.replaceDots(r.metricName(group)),
+			Tags:        p.Tags,
+			Fields:      make(map[string]interface{}, len(p.Fields)),
+			Time:        p.Time,
+			Precision:   p.Precision,
+		}
+		g.pts[key] = gp
+		g.keys = append(g.keys, key)
+		g.names = append(g.names, group)
+	}
+
+	for k, v := range p.Fields {
+		field := leaf
+		if k != "value" {
+			field += "_" + k
+		}
+		if _, ok := gp.Fields[field]; ok {
+			r.warnf("field %s of group %s is already written by another metric, skipping it from %s", field, group, p.Measurement)
+			continue
+		}
+		gp.Fields[field] = v
+	}
+}
+
+// points returns the points of the groups, in the order they were created.
+func (g *pointGroups) points() []client.Point {
+	pts := make([]client.Point, 0, len(g.keys))
+	for _, key := range g.keys {
+		pts = append(pts, *g.pts[key])
+	}
+	return pts
+}
+
+// groupKey identifies the point of a group with the given tags.
+func groupKey(group string, tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(group)
+	for _, k := range keys {
+		b.WriteString("\x00" + k + "=" + tags[k])
+	}
+	return b.String()
+}
@@ -0,0 +1,69 @@
+package influxdb
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestGroups(t *testing.T) {
+	reg := metrics.NewRegistry()
+	metrics.GetOrRegisterGaugeFloat64("cpu.user", reg).Update(0.5)
+	metrics.GetOrRegisterGaugeFloat64("cpu.system", reg).Update(0.25)
+	metrics.GetOrRegisterGauge("cpu.idle", reg).Update(1)
+	metrics.GetOrRegisterCounter("cpu.switches", reg).Inc(3)
+	metrics.GetOrRegisterTimer("cpu.latency", reg).Update(time.Millisecond)
+	metrics.GetOrRegisterGauge("mem.used", reg).Update(7)
+
+	pts, err := BuildPoints(reg, nil, time.Now(), WithGroups("cpu.*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	byName := make(map[string]map[string]interface{})
+	for _, p := range pts {
+		byName[p.Measurement] = p.Fields
+	}
+	if len(pts) != 3 {
+		t.Errorf("got measurements %v, want cpu, the timer and the other gauge", byName)
+	}
+	want := map[string]interface{}{"user": 0.5, "system": 0.25, "idle": int64(1), "switches": int64(3)}
+	if got := byName["cpu"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("got fields %v of the group, want %v", got, want)
+	}
+	// the timers and the metrics matching no pattern aren't grouped
+	for _, m := range []string{"cpu.latency.timer", "mem.used.gauge"} {
+		if _, ok := byName[m]; !ok {
+			t.Errorf("no measurement %s", m)
+		}
+	}
+
+	if _, err := BuildPoints(reg, nil, time.Now(), WithGroups("[")); err == nil {
+		t.Error("got no error for an invalid pattern")
+	}
+	if _, err := BuildPoints(reg, nil, time.Now(), WithGroups("cpu.*"), WithLayout(LayoutNarrow)); err == nil {
+		t.Error("got no error for groups in the narrow layout")
+	}
+}
+
+func TestGroupsConflicts(t *testing.T) {
+	reg := metrics.NewRegistry()
+	metrics.GetOrRegisterCounter("disk.writes", reg).Inc(1)
+	metrics.GetOrRegisterCounter("disk.writes_delta", reg).Inc(2)
+	w := &testWriter{}
+	r := newTestReporter(t, reg, w, WithGroups("disk.*"), WithDeltas(TypeCounter))
+	flush(t, r)
+
+	pts := w.find("disk")
+	if len(pts) != 1 {
+		t.Fatalf("got %d points of the group, want 1", len(pts))
+	}
+	// one of the metrics writing the field writes_delta is kept, the other one skipped with a warning
+	if _, ok := pts[0].Fields["writes_delta"]; !ok {
+		t.Errorf("no field writes_delta in %v", pts[0].Fields)
+	}
+	if len(r.logger.(*testLogger).messages()) == 0 {
+		t.Error("no warning for the conflicting fields")
+	}
+}
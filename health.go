@@ -0,0 +1,37 @@
+package influxdb
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+type health struct {
+	Healthy       bool      `json:"healthy"`
+	LastWrite     time.Time `json:"last_write"`
+	LastError     string    `json:"last_error,omitempty"`
+	ServerVersion string    `json:"server_version,omitempty"`
+}
+
+// HealthHandler returns a handler reporting the health of the reporter as JSON, to be mounted
+// on a mux for readiness or liveness probes. It responds with 200 when the last successful write
+// happened less than staleAfter ago and with 503 otherwise. A staleAfter <= 0 disables the staleness
+// check: the reporter is healthy once a write succeeded.
+func (r *Reporter) HealthHandler(staleAfter time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		h := health{
+			LastWrite:     r.LastWriteTime(),
+			ServerVersion: r.ServerVersion(),
+		}
+		if err := r.LastWriteError(); err != nil {
+			h.LastError = err.Error()
+		}
+		h.Healthy = !h.LastWrite.IsZero() && (staleAfter <= 0 || r.now().Sub(h.LastWrite) < staleAfter)
+
+		w.Header().Set("Content-Type", "application/json")
+		if !h.Healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(h)
+	}
+}
@@ -0,0 +1,51 @@
+package influxdb
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHealthHandler(t *testing.T) {
+	w := &testWriter{}
+	r := newTestReporter(t, newRegistryWithCounter(), w)
+	now := fixedClock(r)
+
+	check := func(name string, staleAfter time.Duration, wantStatus int, wantError bool) {
+		t.Helper()
+
+		rec := httptest.NewRecorder()
+		r.HealthHandler(staleAfter)(rec, httptest.NewRequest("GET", "/health", nil))
+		if rec.Code != wantStatus {
+			t.Errorf("%s: got status %d, want %d", name, rec.Code, wantStatus)
+		}
+		var h health
+		if err := json.Unmarshal(rec.Body.Bytes(), &h); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if h.Healthy != (wantStatus == http.StatusOK) {
+			t.Errorf("%s: got healthy %v with status %d", name, h.Healthy, rec.Code)
+		}
+		if (h.LastError != "") != wantError {
+			t.Errorf("%s: got last error %q", name, h.LastError)
+		}
+	}
+
+	check("no write", time.Minute, http.StatusServiceUnavailable, false)
+	check("no write without staleness", 0, http.StatusServiceUnavailable, false)
+
+	flush(t, r)
+	check("fresh", time.Minute, http.StatusOK, false)
+
+	*now = now.Add(2 * time.Minute)
+	check("stale", time.Minute, http.StatusServiceUnavailable, false)
+	check("without staleness", 0, http.StatusOK, false)
+	check("negative staleness", -time.Second, http.StatusOK, false)
+
+	w.failWith(errTest)
+	r.Flush(context.Background())
+	check("failed write", 0, http.StatusOK, true)
+}
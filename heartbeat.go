@@ -0,0 +1,66 @@
+package influxdb
+
+import (
+	"time"
+
+	"github.com/influxdata/influxdb/client"
+)
+
+// reporterPoints returns the points about the reporter itself of a flush at time now,
+// those of WithHeartbeat, WithUptime, WithRuntimeInfo and WithAnnotations.
+func (r *Reporter) reporterPoints(now time.Time) []client.Point {
+	var pts []client.Point
+	if r.heartbeat != "" {
+		pts = append(pts, r.heartbeatPoint(now))
+	}
+	if r.uptime != "" {
+		pts = append(pts, r.uptimePoint(now))
+	}
+	if r.runtimeInfo != "" {
+		pts = append(pts, r.runtimeInfoPoint(now))
+	}
+	r.filterTags(pts)
+	if r.annotations != "" {
+		pts = append(pts, r.annotationPoints(now)...)
+	}
+	return pts
+}
+
+// writesReporterPoints reports whether every flush writes points of the reporter itself, which are written
+// even when the metrics didn't change since the previous flush, see WithSkipUnchangedFlush.
+func (r *Reporter) writesReporterPoints() bool {
+	return r.heartbeat != "" || r.uptime != "" || r.runtimeInfo != "" || r.annotations != ""
+}
+
+// DefaultHeartbeatMeasurement is the measurement of the heartbeat point when WithHeartbeat is given no name.
+const DefaultHeartbeatMeasurement = "reporter_alive"
+
+// heartbeatPoint returns the heartbeat point of a flush at time now, with the global tags
+// and the time elapsed since the reporter was created in seconds.
+func (r *Reporter) heartbeatPoint(now time.Time) client.Point {
+	return client.Point{
+		Measurement: r.replaceDots(r.heartbeat),
+		Tags:        r.flushTags(),
+		Fields: map[string]interface{}{
+			"uptime": r.now().Sub(r.created).Seconds(),
+		},
+		Time:      now,
+		Precision: r.precision,
+	}
+}
+
+// DefaultUptimeMeasurement is the measurement of the uptime point when WithUptime is given no name.
+const DefaultUptimeMeasurement = "reporter_uptime_seconds"
+
+// uptimePoint returns the uptime point of a flush at time now, see WithUptime.
+func (r *Reporter) uptimePoint(now time.Time) client.Point {
+	return client.Point{
+		Measurement: r.replaceDots(r.uptime),
+		Tags:        r.flushTags(),
+		Fields: map[string]interface{}{
+			"value": r.now().Sub(r.created).Seconds(),
+		},
+		Time:      now,
+		Precision: r.precision,
+	}
+}
@@ -0,0 +1,39 @@
+package influxdb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestUptime(t *testing.T) {
+	w := &testWriter{}
+	r := newTestReporter(t, metrics.NewRegistry(), w, WithUptime(""), WithHeartbeat("heartbeat"), WithTags(map[string]string{"host": "web1"}))
+	now := fixedClock(r)
+	r.created = *now
+
+	for _, want := range []float64{0, 10, 25} {
+		*now = r.created.Add(time.Duration(want) * time.Second)
+		w.batches = nil
+		flush(t, r)
+
+		pts := w.find(DefaultUptimeMeasurement)
+		if len(pts) != 1 || pts[0].Fields["value"] != want {
+			t.Errorf("got uptime points %v, want an uptime of %vs", pts, want)
+		} else if pts[0].Tags["host"] != "web1" {
+			t.Errorf("got tags %v of the uptime point, want those of the reporter", pts[0].Tags)
+		}
+		if pts := w.find("heartbeat"); len(pts) != 1 || pts[0].Fields["uptime"] != want {
+			t.Errorf("got heartbeat points %v, want an uptime of %vs", pts, want)
+		}
+	}
+
+	// a new reporter starts again from 0
+	w = &testWriter{}
+	r = newTestReporter(t, metrics.NewRegistry(), w, WithUptime("uptime"))
+	flush(t, r)
+	if pts := w.find("uptime"); len(pts) != 1 || pts[0].Fields["value"].(float64) > 10 {
+		t.Errorf("got uptime points %v of a new reporter, want an uptime close to 0", pts)
+	}
+}
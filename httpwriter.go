@@ -0,0 +1,150 @@
+package influxdb
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	uurl "net/url"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/influxdata/influxdb/client"
+)
+
+// httpWriter writes batches as line protocol with a POST request to an HTTP endpoint. Unlike the InfluxDB
+// client, whose errors only hold the body of the response, it reports the status and the Retry-After header
+// of a rejected write, so that rate limits are detected.
+type httpWriter struct {
+	endpoint uurl.URL
+	params   uurl.Values
+	// influx adds the database, retention policy and consistency of the batch to the parameters,
+	// as expected by the /write endpoint of InfluxDB.
+	influx      bool
+	credentials Credentials
+	client      *http.Client
+	floats      FloatFormat
+}
+
+func (w *httpWriter) setFloatFormat(f FloatFormat) {
+	w.floats = f
+}
+
+// newInfluxWriter returns an httpWriter to the InfluxDB server of r, the default writer. With WithHTTP2 it
+// negotiates HTTP/2 with servers supporting it over TLS, otherwise its transport is set like the one of
+// the InfluxDB client, which ignores the proxy of the environment.
+func newInfluxWriter(r *Reporter) *httpWriter {
+	u := r.url
+	u.Path = path.Join(u.Path, "write")
+
+	tr := &http.Transport{}
+	if r.http2 {
+		tr.Proxy = http.ProxyFromEnvironment
+		tr.ForceAttemptHTTP2 = true
+	}
+	return &httpWriter{
+		endpoint:    u,
+		influx:      true,
+		credentials: r.credentials,
+		client:      &http.Client{Transport: tr},
+	}
+}
+
+// NewHTTPWriter returns a Writer which posts the points as line protocol to endpoint, with the given
+// query parameters, for example to "http://localhost:8428/write" for VictoriaMetrics or
+// "http://localhost:9000/write" for QuestDB. Unlike the InfluxDB HTTP API, no database is sent.
+// The precision of the batch is sent as the "precision" parameter when it isn't nanoseconds.
+// Credentials set in the user info of the endpoint are sent with basic authentication.
+// A response with a status other than 2xx is reported as a *WriteError.
+func NewHTTPWriter(endpoint string, params uurl.Values) (Writer, error) {
+	u, err := uurl.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse endpoint %s: %v", endpoint, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("endpoint %s must be an absolute http or https url", endpoint)
+	}
+
+	return &httpWriter{
+		endpoint: *u,
+		params:   params,
+		client:   &http.Client{},
+	}, nil
+}
+
+func (w *httpWriter) WriteBatch(ctx context.Context, bp client.BatchPoints) error {
+	u := w.endpoint
+	params := uurl.Values{}
+	for k, vs := range u.Query() {
+		params[k] = vs
+	}
+	for k, vs := range w.params {
+		params[k] = vs
+	}
+	if w.influx {
+		params.Set("db", bp.Database)
+		if bp.RetentionPolicy != "" {
+			params.Set("rp", bp.RetentionPolicy)
+		}
+		if bp.WriteConsistency != "" {
+			params.Set("consistency", bp.WriteConsistency)
+		}
+	}
+	if bp.Precision != "" && (w.influx || bp.Precision != "n" && bp.Precision != "ns") {
+		params.Set("precision", bp.Precision)
+	}
+	u.RawQuery = params.Encode()
+
+	req, err := http.NewRequest("POST", u.String(), bytes.NewReader(bytes.Join(lines(bp, w.floats), nil)))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+
+	if w.credentials != nil {
+		username, password, err := w.credentials()
+		if err != nil {
+			return fmt.Errorf("unable to get InfluxDB credentials: %v", err)
+		}
+		if username != "" {
+			req.SetBasicAuth(username, password)
+		}
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return &WriteError{
+			StatusCode: resp.StatusCode,
+			Body:       string(bytes.TrimSpace(body)),
+			RetryAfter: retryAfter(resp.Header.Get("Retry-After")),
+		}
+	}
+
+	return nil
+}
+
+// retryAfter parses the value of a Retry-After header, either a number of seconds or an HTTP date.
+// It returns 0 when the header is missing or invalid.
+func retryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if s, err := strconv.Atoi(v); err == nil && s >= 0 {
+		return time.Duration(s) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
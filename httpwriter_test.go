@@ -0,0 +1,87 @@
+package influxdb
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb/client"
+)
+
+// newTLSWriter returns the default writer of a reporter writing to a TLS server speaking HTTP/2,
+// and the number of HTTP/2 requests the server received.
+func newTLSWriter(t testing.TB, opts ...Option) (*httpWriter, *int32) {
+	var requests int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.ProtoMajor == 2 {
+			atomic.AddInt32(&requests, 1)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	r, err := New(newRegistryWithCounter(), time.Minute, srv.URL, "db", "", "", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(r.Stop)
+	w := newInfluxWriter(r)
+	tr := w.client.Transport.(*http.Transport)
+	tr.TLSClientConfig = &tls.Config{RootCAs: srv.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs}
+	return w, &requests
+}
+
+var testBatch = client.BatchPoints{
+	Database: "db",
+	Points: []client.Point{{
+		Measurement: "requests.count",
+		Fields:      map[string]interface{}{"value": int64(1)},
+		Time:        time.Unix(1, 0),
+	}},
+}
+
+func TestHTTP2(t *testing.T) {
+	for _, http2 := range []bool{false, true} {
+		var opts []Option
+		if http2 {
+			opts = append(opts, WithHTTP2())
+		}
+		w, requests := newTLSWriter(t, opts...)
+		if err := w.WriteBatch(context.Background(), testBatch); err != nil {
+			t.Fatal(err)
+		}
+		if got := atomic.LoadInt32(requests) == 1; got != http2 {
+			t.Errorf("got HTTP/2 %v, want %v", got, http2)
+		}
+	}
+}
+
+func benchmarkConcurrentWrites(b *testing.B, opts ...Option) {
+	w, _ := newTLSWriter(b, opts...)
+	ctx := context.Background()
+
+	b.ResetTimer()
+	b.SetParallelism(8)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if err := w.WriteBatch(ctx, testBatch); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
+
+func BenchmarkConcurrentWritesHTTP1(b *testing.B) {
+	benchmarkConcurrentWrites(b)
+}
+
+func BenchmarkConcurrentWritesHTTP2(b *testing.B) {
+	benchmarkConcurrentWrites(b, WithHTTP2())
+}
@@ -1,198 +1,775 @@
 package influxdb
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	uurl "net/url"
+	"path"
+	"sync"
 	"time"
 
 	"github.com/influxdata/influxdb/client"
 	"github.com/rcrowley/go-metrics"
 )
 
-type reporter struct {
+// Reporter posts the metrics of a registry to InfluxDB at a fixed interval.
+type Reporter struct {
 	reg      metrics.Registry
 	interval time.Duration
+	name     string
+	logger   Logger
 
-	url      uurl.URL
-	database string
-	username string
-	password string
-	tags     map[string]string
+	url           uurl.URL
+	database      string
+	databaseFunc  func() string
+	credentials   Credentials
+	tags          map[string]string
+	metricTags    []metricTags
+	typeTags      map[MetricType]map[string]string
+	intervalTag   string
+	sourceTag     string
+	sourceValue   string
+	enabledTypes  map[MetricType]bool
+	allowedTags   map[string]bool
+	seriesWarning int
+	overSeries    bool
+	loggedTags    map[string]bool
 
-	client *client.Client
+	layout         Layout
+	normalizeNames bool
+	dedupSuffixes  bool
+	suffixSep      string
+	dotReplacement string
+	templates      map[MetricType]string
+	groups         []string
+	routes         []route
+	// the route of the series of the last flush, see recordRoutes
+	routed      map[string]int
+	buildInfo   bool
+	heartbeat   string
+	uptime      string
+	annotations string
+	runtimeInfo string
+	// the metrics registered at the previous flush, see annotationPoints
+	registered      map[string]bool
+	fetcher         func(ctx context.Context) (RemoteConfig, error)
+	fetchEvery      time.Duration
+	reconfigured    chan struct{}
+	tasks           chan func()
+	created         time.Time
+	runtimeInterval time.Duration
+	registryTag     string
+	snapshotNames   bool
+	precision       string
+	typeIntervals   map[MetricType]time.Duration
+	typeFlushes     map[MetricType]time.Time
+	typePrecisions  map[MetricType]string
+	now             func() time.Time
+	sleep           func(ctx context.Context, d time.Duration) error
+	timeOffset      time.Duration
+	timestamps      func() time.Time
+	truncate        time.Duration
+
+	format     fieldFormat
+	deltaTypes map[MetricType]bool
+	deltas     *deltaTracker
+	// peeking is set while Snapshot builds the points, which must not change the state kept between flushes
+	peeking bool
+
+	unsignedCounters   bool
+	floatGauges        bool
+	totalSeries        bool
+	sampleFraction     float64
+	sampleRounds       uint32
+	sampleNext         uint32
+	counterRates       map[string]counterSample
+	lastUpdates        map[string]lastUpdate
+	sums               bool
+	emitIf             func(name string, value float64) bool
+	boolGauges         []string
+	resetAfterRead     bool
+	percentiles        []float64
+	percentileNames    map[float64]string
+	percentileFields   []string
+	percentileCache    map[string]cachedPercentiles
+	percentileNext     map[string]cachedPercentiles
+	maxPercentiles     int
+	minSamples         int
+	quantileSeries     bool
+	meterRates         map[RateWindow]string
+	timerRates         map[RateWindow]string
+	consistentMeanRate bool
+	fieldNames         map[MetricType]map[string]string
+
+	duplicates    DuplicatePolicy
+	partialWrites PartialWritePolicy
+
+	skipUnchanged      bool
+	prints             map[string]uint64
+	skipUnchangedFlush bool
+	batchPrint         uint64
+	batchPrintSet      bool
+	skipIdle           bool
+	idleCounts         map[string]int64
+
+	limiter     *rateLimiter
+	queue       *batchQueue
+	async       bool
+	wake        chan struct{}
+	selfMetrics bool
+	// the timer of WithWriteTimer, nil unless enabled
+	writeTimer     metrics.Timer
+	writeTimerOn   bool
+	writeTimerName string
+	onFlush        func(FlushStats)
+	// the drains requested by Flush in async mode, see drainOnWriteLoop
+	drains    chan drainRequest
+	writeDone chan struct{}
+
+	client           *client.Client
+	writer           Writer
+	writeTimeout     time.Duration
+	floats           FloatFormat
+	maxPointBytes    int
+	maxBatchBytes    int
+	compressionRatio float64
+	retries          int
+	retryBackoff     time.Duration
+	retryBudget      time.Duration
+	retryable        func(error) bool
+	breaker          *breaker
+	fallback         Writer
+	http2            bool
+	noPing           bool
+	startupCheck     bool
+	wal              *wal
+
+	ctx      context.Context
+	cancel   context.CancelFunc
+	done     chan struct{}
+	stopOnce sync.Once
+
+	mu            sync.Mutex
+	running       bool
+	extraRegs     []namedRegistry
+	lastWrite     time.Time
+	lastErr       error
+	dropped       int64
+	resetPending  bool
+	pausedUntil   time.Time
+	serverVersion string
 }
 
 // InfluxDB starts a InfluxDB reporter which will post the metrics from the given registry at each d interval.
-func InfluxDB(r metrics.Registry, d time.Duration, url, database, username, password string) {
-	InfluxDBWithTags(r, d, url, database, username, password, nil)
+func InfluxDB(r metrics.Registry, d time.Duration, url, database, username, password string, opts ...Option) {
+	InfluxDBWithTags(r, d, url, database, username, password, nil, opts...)
 }
 
 // InfluxDBWithTags starts a InfluxDB reporter which will post the metrics from the given registry at each d interval with the specified tags
-func InfluxDBWithTags(r metrics.Registry, d time.Duration, url, database, username, password string, tags map[string]string) {
-	u, err := uurl.Parse(url)
+func InfluxDBWithTags(r metrics.Registry, d time.Duration, url, database, username, password string, tags map[string]string, opts ...Option) {
+	rep, err := New(r, d, url, database, username, password, append([]Option{WithTags(tags)}, opts...)...)
 	if err != nil {
-		log.Printf("unable to parse InfluxDB url %s. err=%v", url, err)
+		log.Printf("unable to start InfluxDB reporter. err=%v", err)
 		return
 	}
 
-	rep := &reporter{
+	rep.Run()
+}
+
+// New creates a reporter which will post the metrics from the given registry at each d interval once Run is called.
+// Invalid settings are reported as a *ConfigError, a failed startup check as a *ConnectError.
+func New(r metrics.Registry, d time.Duration, url, database, username, password string, opts ...Option) (*Reporter, error) {
+	if r == nil {
+		return nil, &ConfigError{errNilRegistry}
+	}
+	u, err := uurl.Parse(url)
+	if err != nil {
+		return nil, &ConfigError{fmt.Errorf("unable to parse InfluxDB url %s: %v", url, err)}
+	}
+
+	rep := newReporter(r, d, opts)
+	rep.url = *u
+	rep.database = database
+	if rep.credentials == nil {
+		rep.credentials = staticCredentials(username, password)
+	}
+	if err := rep.validate(); err != nil {
+		return nil, &ConfigError{err}
+	}
+	if rep.writer == nil {
+		if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+			return nil, &ConfigError{fmt.Errorf("InfluxDB url %s must be an absolute http or https url", url)}
+		}
+		if err := rep.makeClient(); err != nil {
+			return nil, &ConfigError{fmt.Errorf("unable to make InfluxDB client: %v", err)}
+		}
+		rep.writer = newInfluxWriter(rep)
+
+		if rep.startupCheck {
+			_, version, err := rep.client.Ping()
+			if err != nil {
+				return nil, &ConnectError{err}
+			}
+			rep.setServerVersion(version)
+		}
+	}
+	for _, w := range []Writer{rep.writer, rep.fallback} {
+		if ff, ok := w.(floatFormatter); ok {
+			ff.setFloatFormat(rep.floats)
+		}
+	}
+	if err := acquireRegistry(rep.reg, rep.resetAfterRead); err != nil {
+		return nil, &ConfigError{err}
+	}
+	rep.registerMetrics()
+
+	return rep, nil
+}
+
+// newReporter returns a reporter with the default settings overridden by opts.
+func newReporter(r metrics.Registry, d time.Duration, opts []Option) *Reporter {
+	rep := &Reporter{
 		reg:      r,
 		interval: d,
-		url:      *u,
-		database: database,
-		username: username,
-		password: password,
-		tags:     tags,
-	}
-	if err := rep.makeClient(); err != nil {
-		log.Printf("unable to make InfluxDB client. err=%v", err)
-		return
+		now:      time.Now,
+		sleep:    sleep,
+		logger:   stdLogger{},
+		format:   defaultFieldFormat,
+		deltas:   newDeltaTracker(),
+		done:     make(chan struct{}),
+		queue:    &batchQueue{depth: defaultQueueDepth, policy: DropOldest},
+
+		seriesWarning: defaultSeriesWarning,
+		wake:          make(chan struct{}, 1),
+		drains:        make(chan drainRequest),
+		writeDone:     make(chan struct{}),
+
+		reconfigured: make(chan struct{}, 1),
+		tasks:        make(chan func()),
+		retryable:    DefaultRetryable,
+
+		suffixSep:      ".",
+		percentiles:    defaultPercentiles,
+		maxPercentiles: defaultMaxPercentiles,
+		meterRates:     defaultMeterRates,
+		timerRates:     defaultTimerRates,
+	}
+	rep.ctx, rep.cancel = context.WithCancel(context.Background())
+	for _, opt := range opts {
+		opt(rep)
+	}
+	rep.created = rep.now()
+	if rep.consistentMeanRate {
+		rep.meterRates = consistentMeterRates(rep.meterRates)
+	}
+	if rep.wal != nil {
+		rep.wal.logf = rep.logf
+		rep.wal.floats = rep.floats
+	}
+	rep.percentileFields = percentileFields(rep.percentiles, rep.percentileNames)
+	if rep.precision == PrecisionAuto {
+		rep.precision = autoPrecision(d)
+	}
+	for t, p := range rep.typePrecisions {
+		if p == PrecisionAuto {
+			rep.typePrecisions[t] = autoPrecision(d)
+		}
+	}
+
+	return rep
+}
+
+// registerMetrics registers the metrics added by the options to the registry given to the constructor.
+// It is called once the settings are known to be valid, so that a failed constructor leaves the registry unchanged.
+func (r *Reporter) registerMetrics() {
+	if r.buildInfo {
+		r.registerBuildInfo()
+	}
+	if r.writeTimerOn {
+		r.registerWriteTimer()
+	}
+}
+
+// validate checks the settings applied by the options.
+func (r *Reporter) validate() error {
+	if err := validatePrecision(r.precision); err != nil {
+		return err
+	}
+	for t, d := range r.typeIntervals {
+		if d < 0 {
+			return fmt.Errorf("interval of metric type %q must not be negative", t)
+		}
+	}
+	if r.enabledTypes != nil && len(r.enabledTypes) == 0 {
+		return errors.New("at least one metric type must be enabled")
+	}
+	for t := range r.enabledTypes {
+		if _, ok := suffixes[t]; !ok {
+			return fmt.Errorf("unknown metric type %q to enable", t)
+		}
+	}
+	for t, tmpl := range r.templates {
+		if _, ok := suffixes[t]; !ok {
+			return fmt.Errorf("unknown metric type %q for measurement template", t)
+		}
+		if err := validateTemplate(tmpl); err != nil {
+			return err
+		}
+	}
+	for t := range r.typeTags {
+		if _, ok := suffixes[t]; !ok {
+			return fmt.Errorf("unknown metric type %q for tags", t)
+		}
+	}
+	for _, p := range r.typePrecisions {
+		if err := validatePrecision(p); err != nil {
+			return err
+		}
+	}
+	if r.fetcher != nil && r.fetchEvery <= 0 {
+		return errors.New("config fetch interval must be positive")
+	}
+	switch r.partialWrites {
+	case "", PartialWriteAccept, PartialWriteFail:
+	default:
+		return fmt.Errorf("unknown partial write policy %q", r.partialWrites)
+	}
+	if r.writeTimeout < 0 {
+		return errors.New("write timeout must not be negative")
+	}
+	if err := r.floats.validate(); err != nil {
+		return err
+	}
+	if r.maxPointBytes < 0 {
+		return errors.New("maximum point size must not be negative")
+	}
+	if r.maxBatchBytes < 0 {
+		return errors.New("maximum batch size must not be negative")
+	}
+	if r.compressionRatio != 0 && r.compressionRatio < 1 {
+		return fmt.Errorf("compression ratio %v must be at least 1", r.compressionRatio)
+	}
+	if r.truncate < 0 {
+		return errors.New("timestamp truncation must not be negative")
+	}
+	if r.breaker != nil && (r.breaker.threshold <= 0 || r.breaker.cooldown <= 0) {
+		return errors.New("circuit breaker threshold and cooldown must be positive")
+	}
+	if r.sampleFraction != 0 && !(r.sampleFraction > 0 && r.sampleFraction <= 1) {
+		return fmt.Errorf("sample fraction %v is not within (0, 1]", r.sampleFraction)
+	}
+	if r.retryBudget < 0 {
+		return errors.New("retry budget must not be negative")
+	}
+	if r.retries < 0 || r.retryBackoff < 0 {
+		return errors.New("retry attempts and backoff must not be negative")
+	}
+	switch r.duplicates {
+	case "", DuplicateIgnore, DuplicateWarn, DuplicateOffset, DuplicateMerge:
+	default:
+		return fmt.Errorf("unknown duplicate policy %q", r.duplicates)
+	}
+	for t := range r.deltaTypes {
+		switch t {
+		case TypeCounter, TypeHistogram, TypeMeter, TypeTimer:
+		default:
+			return fmt.Errorf("deltas are not supported for metric type %q", t)
+		}
+	}
+	switch r.deltas.policy {
+	case ResetAssume, ResetDrop:
+	default:
+		return fmt.Errorf("unknown reset policy %q", r.deltas.policy)
+	}
+	if r.limiter != nil {
+		switch r.limiter.policy {
+		case LimitDrop, LimitCoalesce:
+		default:
+			return fmt.Errorf("unknown rate limit policy %q", r.limiter.policy)
+		}
+	}
+	if r.queue.depth < 1 {
+		return errors.New("queue depth must be at least 1")
+	}
+	switch r.queue.policy {
+	case DropOldest, DropNewest:
+	default:
+		return fmt.Errorf("unknown queue policy %q", r.queue.policy)
+	}
+	switch r.layout {
+	case "", LayoutWide:
+	case LayoutNarrow:
+		// a measurement would mix boolean and integer values
+		if len(r.boolGauges) > 0 {
+			return errors.New("boolean gauges are not supported by the narrow layout")
+		}
+		if r.quantileSeries {
+			return errors.New("quantile series are not supported by the narrow layout")
+		}
+		if len(r.groups) > 0 {
+			return errors.New("metric groups are not supported by the narrow layout")
+		}
+	default:
+		return fmt.Errorf("unknown layout %q", r.layout)
+	}
+	for _, pattern := range r.groups {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid group pattern %q: %v", pattern, err)
+		}
+	}
+	for _, rt := range r.routes {
+		if _, err := path.Match(rt.pattern, ""); err != nil {
+			return fmt.Errorf("invalid route pattern %q: %v", rt.pattern, err)
+		}
+		if rt.database == "" {
+			return fmt.Errorf("route %q has no database", rt.pattern)
+		}
+	}
+	for _, mt := range r.metricTags {
+		if _, err := path.Match(mt.pattern, ""); err != nil {
+			return fmt.Errorf("invalid metric tags pattern %q: %v", mt.pattern, err)
+		}
+	}
+	for _, pattern := range r.boolGauges {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid boolean gauge pattern %q: %v", pattern, err)
+		}
+	}
+	if err := validatePercentiles(r.percentiles, r.percentileNames, r.maxPercentiles); err != nil {
+		return err
+	}
+	if r.percentileCache != nil && r.resetAfterRead {
+		return errors.New("the percentile cache can't be used with reset after read")
+	}
+	if err := validateFieldNames(r.fieldNames); err != nil {
+		return err
+	}
+	if err := validateRates(r.meterRates, meterFields); err != nil {
+		return err
+	}
+	if err := validateRates(r.timerRates, append(timerFields[:len(timerFields):len(timerFields)], r.percentileFields...)); err != nil {
+		return err
 	}
 
-	rep.run()
+	return nil
 }
 
-func (r *reporter) makeClient() (err error) {
-	r.client, err = client.NewClient(client.Config{
+func (r *Reporter) makeClient() error {
+	username, password, err := r.credentials()
+	if err != nil {
+		return fmt.Errorf("unable to get InfluxDB credentials: %v", err)
+	}
+
+	c, err := client.NewClient(client.Config{
 		URL:      r.url,
-		Username: r.username,
-		Password: r.password,
+		Username: username,
+		Password: password,
+		// the client can't be cancelled, this bounds the pings
+		Timeout: r.flushTimeout(),
 	})
+	if err != nil {
+		return err
+	}
+
+	// the write loop of async mode reads the client concurrently
+	r.mu.Lock()
+	r.client = c
+	r.mu.Unlock()
+
+	return nil
+}
 
-	return
+// Client returns the InfluxDB client used to ping, for example to run setup queries such
+// as CREATE CONTINUOUS QUERY without opening a second connection. It is nil when the reporter uses
+// a custom Writer. The reporter replaces the client when a ping fails, so don't keep it around, and
+// changing or closing it is at your own risk.
+func (r *Reporter) Client() *client.Client {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.client
 }
 
-func (r *reporter) run() {
-	intervalTicker := time.Tick(r.interval)
-	pingTicker := time.Tick(time.Second * 5)
+// Run posts the metrics at each interval until Stop is called.
+func (r *Reporter) Run() {
+	r.mu.Lock()
+	if r.running || r.ctx.Err() != nil {
+		r.mu.Unlock()
+		return
+	}
+	r.running = true
+	r.mu.Unlock()
+	defer close(r.done)
+
+	if r.runtimeInterval > 0 {
+		r.captureRuntimeStats()
+	}
+	if r.async {
+		go r.writeLoop()
+		defer func() { <-r.writeDone }()
+	}
+
+	ctx, cancel := r.flushContext()
+	r.replayWAL(ctx)
+	cancel()
+
+	if r.fetcher != nil {
+		r.fetchConfig()
+		go r.fetchLoop()
+	}
+
+	intervalTicker := time.NewTicker(r.getInterval())
+	defer func() { intervalTicker.Stop() }()
+
+	// Only the InfluxDB client needs to be kept alive, custom writers handle their own connections.
+	var pingTicker <-chan time.Time
+	if r.client != nil && !r.noPing {
+		t := time.NewTicker(time.Second * 5)
+		defer t.Stop()
+		pingTicker = t.C
+	}
 
 	for {
 		select {
-		case <-intervalTicker:
-			if err := r.send(); err != nil {
-				log.Printf("unable to send metrics to InfluxDB. err=%v", err)
+		case <-r.ctx.Done():
+			return
+		case <-r.reconfigured:
+			intervalTicker.Stop()
+			intervalTicker = time.NewTicker(r.getInterval())
+		case <-intervalTicker.C:
+			err := r.send(nil)
+			if !r.async {
+				r.setWriteResult(err)
 			}
-		case <-pingTicker:
-			_, _, err := r.client.Ping()
 			if err != nil {
-				log.Printf("got error while sending a ping to InfluxDB, trying to recreate client. err=%v", err)
+				r.logf("unable to send metrics to InfluxDB. err=%v", err)
+			}
+		case fn := <-r.tasks:
+			fn()
+		case <-pingTicker:
+			_, version, err := r.client.Ping()
+			if err == nil {
+				r.setServerVersion(version)
+			} else {
+				r.logf("got error while sending a ping to InfluxDB, trying to recreate client. err=%v", err)
 
 				if err = r.makeClient(); err != nil {
-					log.Printf("unable to make InfluxDB client. err=%v", err)
+					r.logf("unable to make InfluxDB client. err=%v", err)
 				}
 			}
 		}
 	}
 }
 
-func (r *reporter) send() error {
-	var pts []client.Point
-
-	r.reg.Each(func(name string, i interface{}) {
-		now := time.Now()
-
-		switch metric := i.(type) {
-		case metrics.Counter:
-			ms := metric.Snapshot()
-			pts = append(pts, client.Point{
-				Measurement: fmt.Sprintf("%s.count", name),
-				Tags:        r.tags,
-				Fields: map[string]interface{}{
-					"value": ms.Count(),
-				},
-				Time: now,
-			})
-		case metrics.Gauge:
-			ms := metric.Snapshot()
-			pts = append(pts, client.Point{
-				Measurement: fmt.Sprintf("%s.gauge", name),
-				Tags:        r.tags,
-				Fields: map[string]interface{}{
-					"value": ms.Value(),
-				},
-				Time: now,
-			})
-		case metrics.GaugeFloat64:
-			ms := metric.Snapshot()
-			pts = append(pts, client.Point{
-				Measurement: fmt.Sprintf("%s.gauge", name),
-				Tags:        r.tags,
-				Fields: map[string]interface{}{
-					"value": ms.Value(),
-				},
-				Time: now,
-			})
-		case metrics.Histogram:
-			ms := metric.Snapshot()
-			ps := ms.Percentiles([]float64{0.5, 0.75, 0.95, 0.99, 0.999, 0.9999})
-			pts = append(pts, client.Point{
-				Measurement: fmt.Sprintf("%s.histogram", name),
-				Tags:        r.tags,
-				Fields: map[string]interface{}{
-					"count":    ms.Count(),
-					"max":      ms.Max(),
-					"mean":     ms.Mean(),
-					"min":      ms.Min(),
-					"stddev":   ms.StdDev(),
-					"variance": ms.Variance(),
-					"p50":      ps[0],
-					"p75":      ps[1],
-					"p95":      ps[2],
-					"p99":      ps[3],
-					"p999":     ps[4],
-					"p9999":    ps[5],
-				},
-				Time: now,
-			})
-		case metrics.Meter:
-			ms := metric.Snapshot()
-			pts = append(pts, client.Point{
-				Measurement: fmt.Sprintf("%s.meter", name),
-				Tags:        r.tags,
-				Fields: map[string]interface{}{
-					"count": ms.Count(),
-					"m1":    ms.Rate1(),
-					"m5":    ms.Rate5(),
-					"m15":   ms.Rate15(),
-					"mean":  ms.RateMean(),
-				},
-				Time: now,
-			})
-		case metrics.Timer:
-			ms := metric.Snapshot()
-			ps := ms.Percentiles([]float64{0.5, 0.75, 0.95, 0.99, 0.999, 0.9999})
-			pts = append(pts, client.Point{
-				Measurement: fmt.Sprintf("%s.timer", name),
-				Tags:        r.tags,
-				Fields: map[string]interface{}{
-					"count":    ms.Count(),
-					"max":      ms.Max(),
-					"mean":     ms.Mean(),
-					"min":      ms.Min(),
-					"stddev":   ms.StdDev(),
-					"variance": ms.Variance(),
-					"p50":      ps[0],
-					"p75":      ps[1],
-					"p95":      ps[2],
-					"p99":      ps[3],
-					"p999":     ps[4],
-					"p9999":    ps[5],
-					"m1":       ms.Rate1(),
-					"m5":       ms.Rate5(),
-					"m15":      ms.Rate15(),
-					"meanrate": ms.RateMean(),
-				},
-				Time: now,
-			})
+// Stop stops the reporter, aborting a write in progress, and waits for Run to return.
+// The registries are then released, so that they can be used by another reporter.
+// A stopped reporter can't be restarted.
+func (r *Reporter) Stop() {
+	r.stopOnce.Do(func() {
+		r.cancel()
+
+		r.mu.Lock()
+		running := r.running
+		r.mu.Unlock()
+		if running {
+			<-r.done
+		}
+
+		for _, nr := range r.registries() {
+			releaseRegistry(nr.reg, r.resetAfterRead)
 		}
 	})
+}
+
+// flushTimeout returns the time a flush may take: the write timeout, bounded by the interval
+// so that a hung write never delays the next flush, unless writes are asynchronous.
+func (r *Reporter) flushTimeout() time.Duration {
+	interval := r.getInterval()
+	if r.writeTimeout > 0 && (r.async || interval <= 0 || r.writeTimeout < interval) {
+		return r.writeTimeout
+	}
+	return interval
+}
 
-	bps := client.BatchPoints{
-		Points:   pts,
-		Database: r.database,
+// flushContext returns the context of a flush starting now, cancelled by Stop.
+func (r *Reporter) flushContext() (context.Context, context.CancelFunc) {
+	if d := r.flushTimeout(); d > 0 {
+		return context.WithTimeout(r.ctx, d)
 	}
+	return context.WithCancel(r.ctx)
+}
 
-	_, err := r.client.Write(bps)
-	return err
+// flushTime returns the timestamp of the points of a flush happening now.
+func (r *Reporter) flushTime() time.Time {
+	now := r.now
+	if r.timestamps != nil {
+		now = r.timestamps
+	}
+	t := now().Add(r.timeOffset)
+	if r.truncate > 0 {
+		t = t.Truncate(r.truncate)
+	}
+	return t
+}
+
+// send flushes the metrics. The write is bounded by ctx when not nil, otherwise by flushContext,
+// or left to the write loop in async mode.
+func (r *Reporter) send(ctx context.Context) (err error) {
+	stats := FlushStats{Start: r.now()}
+	if r.onFlush != nil {
+		defer func() {
+			stats.Duration = r.now().Sub(stats.Start)
+			stats.Err = err
+			r.onFlush(stats)
+		}()
+	}
+
+	r.clearCaches()
+	now := r.flushTime()
+	pts := r.buildPoints(now)
+	if r.skipUnchanged {
+		n := len(pts)
+		pts = r.skipUnchangedPoints(pts)
+		stats.Dropped.Unchanged = n - len(pts)
+	}
+	if r.skipUnchangedFlush && !r.batchChanged(pts) {
+		stats.Dropped.Unchanged += len(pts)
+		if !r.writesReporterPoints() {
+			return nil
+		}
+		pts = nil
+	}
+	// added after the unchanged points are skipped, as they always change
+	pts = append(pts, r.reporterPoints(now)...)
+	if r.limiter != nil {
+		limited, ok := r.limiter.limit(pts, r.now())
+		if !ok {
+			stats.Dropped.Limited = len(pts)
+			r.logf("write rate limit reached, not writing flush (policy %s)", r.limiter.policy)
+			return nil
+		}
+		pts = limited
+	}
+	pts, stats.Dropped.Invalid, stats.Dropped.Oversized = r.skipUnwritable(pts)
+
+	// routed before being queued, the routes recorded by the next flush are those of its own points
+	bps := r.partition(client.BatchPoints{
+		Points:    pts,
+		Database:  r.flushDatabase(),
+		Precision: r.precision,
+	})
+	stats.Points = len(pts)
+	stats.Series = r.checkCardinality(pts)
+	if r.onFlush != nil {
+		for _, bp := range bps {
+			stats.Bytes += batchSize(bp, r.floats)
+		}
+	}
+
+	if !r.enqueue(bps) {
+		stats.Dropped.Batches++
+	}
+	if ctx == nil && r.async {
+		select {
+		case r.wake <- struct{}{}:
+		default:
+			// the write loop is already due to drain the queue
+		}
+		return nil
+	}
+
+	if ctx == nil {
+		var cancel context.CancelFunc
+		ctx, cancel = r.flushContext()
+		defer cancel()
+	}
+
+	r.mu.Lock()
+	running := r.running
+	r.mu.Unlock()
+	if r.async && running {
+		return r.drainOnWriteLoop(ctx)
+	}
+	return r.drain(ctx)
+}
+
+// write sends the batches of a flush with the writer, split by precision. When a write-ahead log is configured
+// a failed batch is appended to it, and the logged batches are replayed after a successful write.
+func (r *Reporter) write(ctx context.Context, bps []client.BatchPoints) error {
+	var subs []client.BatchPoints
+	for _, bp := range bps {
+		for _, sub := range splitPrecision(bp) {
+			subs = append(subs, r.chunks(sub)...)
+		}
+	}
+
+	var (
+		first error
+		// set when a batch was written to the fallback only, InfluxDB is then down
+		fellBack bool
+	)
+	for _, sub := range subs {
+		var err error
+		if r.writesPaused() {
+			// a previous chunk was rate limited, this one would be rejected too
+			err = ErrRateLimited
+		} else {
+			err = r.writeBatch(ctx, sub)
+		}
+		if err == nil {
+			r.recordWrite(nil)
+			continue
+		}
+		if pw, ok := partialWrite(err); ok && r.partialWrites != PartialWriteFail {
+			// writing the batch again would only duplicate the accepted points
+			r.selfCounter("dropped_points").Inc(int64(pw.Dropped))
+			r.logf("InfluxDB dropped %d points of a batch of %d, fix the offending metrics. reason=%s", pw.Dropped, len(sub.Points), pw.Reason)
+			r.recordWrite(nil)
+			continue
+		}
+		if d, ok := r.rateLimited(err); ok {
+			r.pauseWrites(d)
+			r.recordRateLimit(d)
+		} else if err == ErrRateLimited {
+			r.recordRateLimit(r.pauseLeft())
+		} else {
+			r.recordWrite(err)
+		}
+		if r.writeFallback(ctx, sub, err) {
+			fellBack = true
+			continue
+		}
+		if r.wal == nil {
+			// the whole batch stays queued, rewriting the batches already written is harmless
+			return err
+		}
+
+		if werr := r.wal.append(sub); werr != nil {
+			r.logf("unable to append batch to write-ahead log %s, dropping it. err=%v", r.wal.path, werr)
+		}
+		if first == nil {
+			first = err
+		}
+	}
+	if first != nil || r.wal == nil || fellBack {
+		return first
+	}
+
+	r.replayWAL(ctx)
+	return nil
+}
+
+func (r *Reporter) replayWAL(ctx context.Context) {
+	if r.wal == nil || !r.wal.pending {
+		return
+	}
+
+	n, err := r.wal.replay(ctx, r.writer)
+	if n > 0 {
+		r.logf("replayed %d batches from write-ahead log %s", n, r.wal.path)
+	}
+	if err != nil {
+		r.logf("unable to replay write-ahead log %s. err=%v", r.wal.path, err)
+	}
 }
@@ -0,0 +1,352 @@
+package influxdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb/client"
+	"github.com/rcrowley/go-metrics"
+)
+
+// testWriter records the batches written to it. Writes take delay, and fail with err when set.
+type testWriter struct {
+	mu      sync.Mutex
+	delay   time.Duration
+	err     error
+	batches []client.BatchPoints
+}
+
+func (w *testWriter) WriteBatch(ctx context.Context, bp client.BatchPoints) error {
+	if w.delay > 0 {
+		time.Sleep(w.delay)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		return w.err
+	}
+	bp.Points = append([]client.Point(nil), bp.Points...)
+	w.batches = append(w.batches, bp)
+	return nil
+}
+
+func (w *testWriter) failWith(err error) {
+	w.mu.Lock()
+	w.err = err
+	w.mu.Unlock()
+}
+
+func (w *testWriter) written() []client.BatchPoints {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]client.BatchPoints(nil), w.batches...)
+}
+
+func (w *testWriter) points() []client.Point {
+	var pts []client.Point
+	for _, bp := range w.written() {
+		pts = append(pts, bp.Points...)
+	}
+	return pts
+}
+
+// find returns the points written in measurement.
+func (w *testWriter) find(measurement string) []client.Point {
+	var res []client.Point
+	for _, p := range w.points() {
+		if p.Measurement == measurement {
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+// testLogger records the logged messages.
+type testLogger struct {
+	mu   sync.Mutex
+	msgs []string
+}
+
+func (l *testLogger) Printf(format string, v ...interface{}) {
+	l.mu.Lock()
+	l.msgs = append(l.msgs, fmt.Sprintf(format, v...))
+	l.mu.Unlock()
+}
+
+func (l *testLogger) messages() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.msgs...)
+}
+
+// newTestReporter returns a reporter of reg writing to w, stopped at the end of the test.
+func newTestReporter(t testing.TB, reg metrics.Registry, w Writer, opts ...Option) *Reporter {
+	t.Helper()
+
+	r, err := New(reg, time.Minute, "", "db", "", "", append([]Option{WithWriter(w), WithLogger(&testLogger{})}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(r.Stop)
+	return r
+}
+
+// flush flushes r, failing the test on error.
+func flush(t testing.TB, r *Reporter) {
+	t.Helper()
+
+	if err := r.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newRegistryWithCounter returns a registry holding the counter "requests" with a count of 1.
+func newRegistryWithCounter() metrics.Registry {
+	reg := metrics.NewRegistry()
+	metrics.GetOrRegisterCounter("requests", reg).Inc(1)
+	return reg
+}
+
+// newGaugeFloat64 registers a float gauge in reg.
+func newGaugeFloat64(reg metrics.Registry, name string) metrics.GaugeFloat64 {
+	return metrics.GetOrRegisterGaugeFloat64(name, reg)
+}
+
+// sortedLines returns the line protocol of pts, sorted, to compare points regardless of their order.
+func sortedLines(t testing.TB, pts []client.Point) []string {
+	t.Helper()
+
+	res := make([]string, len(pts))
+	for i, p := range pts {
+		line, err := appendLine(nil, p, "", FloatFormat{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		res[i] = string(line)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// fixedClock sets the clock of r to the returned time, which the test can move.
+func fixedClock(r *Reporter) *time.Time {
+	now := time.Unix(1000, 0)
+	r.now = func() time.Time { return now }
+	return &now
+}
+
+var errTest = errors.New("test error")
+
+// discardWriter drops the batches written to it, counting their points.
+type discardWriter struct {
+	points int
+}
+
+func (w *discardWriter) WriteBatch(ctx context.Context, bp client.BatchPoints) error {
+	w.points += len(bp.Points)
+	return nil
+}
+
+// newMixedRegistry returns a registry of n metrics, cycling through the metric types.
+func newMixedRegistry(n int) metrics.Registry {
+	reg := metrics.NewRegistry()
+	for i := 0; i < n; i++ {
+		name := fmt.Sprintf("metric.%d", i)
+		switch i % 5 {
+		case 0:
+			metrics.GetOrRegisterCounter(name, reg).Inc(int64(i))
+		case 1:
+			metrics.GetOrRegisterGauge(name, reg).Update(int64(i))
+		case 2:
+			metrics.GetOrRegisterMeter(name, reg).Mark(int64(i))
+		case 3:
+			metrics.GetOrRegisterTimer(name, reg).Update(time.Duration(i))
+		case 4:
+			h := metrics.GetOrRegisterHistogram(name, reg, metrics.NewUniformSample(100))
+			for j := 0; j < 100; j++ {
+				h.Update(int64(j))
+			}
+		}
+	}
+	return reg
+}
+
+func TestOnePointPerMetric(t *testing.T) {
+	pts, err := BuildPoints(newMixedRegistry(10), nil, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pts) != 10 {
+		t.Fatalf("got %d points, want one per metric", len(pts))
+	}
+	seen := make(map[string]bool)
+	for _, p := range pts {
+		if seen[p.Measurement] {
+			t.Errorf("got several points of %s", p.Measurement)
+		}
+		seen[p.Measurement] = true
+	}
+	composite := 0
+	for _, p := range pts {
+		if strings.HasPrefix(p.Measurement, "metric.3") || strings.HasPrefix(p.Measurement, "metric.4") {
+			composite++
+			if len(p.Fields) < 10 {
+				t.Errorf("got fields %v of %s, want all of them in the point", p.Fields, p.Measurement)
+			}
+		}
+	}
+	if composite != 2 {
+		t.Errorf("got %d points of the timer and the histogram, want 2", composite)
+	}
+}
+
+func benchmarkSend(b *testing.B, n int) {
+	w := &discardWriter{}
+	r := newTestReporter(b, newMixedRegistry(n), w)
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := r.send(ctx); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(w.points)/float64(b.N), "points/op")
+}
+
+func BenchmarkSend100(b *testing.B) { benchmarkSend(b, 100) }
+func BenchmarkSend1k(b *testing.B)  { benchmarkSend(b, 1000) }
+func BenchmarkSend10k(b *testing.B) { benchmarkSend(b, 10000) }
+
+// blockingWriter blocks its writes until their context is done.
+type blockingWriter struct {
+	started chan struct{}
+}
+
+func (w *blockingWriter) WriteBatch(ctx context.Context, bp client.BatchPoints) error {
+	select {
+	case w.started <- struct{}{}:
+	default:
+	}
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestWriteTimeout(t *testing.T) {
+	tests := []struct {
+		name     string
+		interval time.Duration
+		opts     []Option
+	}{
+		{"write timeout", time.Minute, []Option{WithWriteTimeout(20 * time.Millisecond)}},
+		{"interval", 20 * time.Millisecond, []Option{WithWriteTimeout(time.Minute)}},
+		{"default", 20 * time.Millisecond, nil},
+	}
+	for _, tt := range tests {
+		r, err := New(newRegistryWithCounter(), tt.interval, "", "db", "", "", append([]Option{WithWriter(&blockingWriter{}), WithLogger(&testLogger{})}, tt.opts...)...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		start := time.Now()
+		err = r.send(nil)
+		r.Stop()
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("%s: got error %v, want the deadline exceeded", tt.name, err)
+		}
+		if d := time.Since(start); d > 10*time.Second {
+			t.Errorf("%s: the write took %v", tt.name, d)
+		}
+	}
+}
+
+func TestStopAbortsWrite(t *testing.T) {
+	w := &blockingWriter{started: make(chan struct{}, 1)}
+	r, err := New(newRegistryWithCounter(), time.Millisecond, "", "db", "", "", WithWriter(w), WithWriteTimeout(time.Hour), WithLogger(&testLogger{}), WithAsync())
+	if err != nil {
+		t.Fatal(err)
+	}
+	go r.Run()
+	<-w.started
+
+	stopped := make(chan struct{})
+	go func() {
+		r.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Stop didn't abort the write in progress")
+	}
+}
+
+func TestTimestamps(t *testing.T) {
+	reg := newRegistryWithCounter()
+	metrics.GetOrRegisterGauge("goroutines", reg).Update(3)
+	epoch := time.Date(2020, 1, 1, 0, 0, 0, 123456789, time.UTC)
+	w := &testWriter{}
+	r := newTestReporter(t, reg, w, WithTimestamps(func() time.Time { return epoch }), WithHeartbeat("heartbeat"))
+
+	for i := 0; i < 2; i++ {
+		flush(t, r)
+	}
+	for _, p := range w.points() {
+		if !p.Time.Equal(epoch) {
+			t.Errorf("got time %v of %s, want %v", p.Time, p.Measurement, epoch)
+		}
+	}
+
+	// golden lines, with the timestamps shifted then truncated to the precision
+	w = &testWriter{}
+	r = newTestReporter(t, reg, w, WithTimestamps(func() time.Time { return epoch }), WithTimeOffset(time.Second), WithPrecision("s"))
+	flush(t, r)
+	bp := w.written()[0]
+	var got []string
+	for _, line := range lines(bp, FloatFormat{}) {
+		got = append(got, strings.TrimSuffix(string(line), "\n"))
+	}
+	sort.Strings(got)
+	want := []string{"goroutines.gauge value=3i 1577836801", "requests.count value=1i 1577836801"}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got lines\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestTimestampTruncation(t *testing.T) {
+	at := time.Date(2020, 1, 1, 0, 0, 0, 123456789, time.UTC)
+	for _, tt := range []struct {
+		d    time.Duration
+		opts []Option
+		want time.Time
+	}{
+		{0, nil, at},
+		{time.Microsecond, nil, at.Add(-789)},
+		{time.Millisecond, nil, at.Add(-456789)},
+		// truncated after the offset
+		{time.Millisecond, []Option{WithTimeOffset(time.Microsecond * 900)}, at.Add(time.Millisecond - 456789)},
+	} {
+		w := &testWriter{}
+		opts := append([]Option{WithTimestamps(func() time.Time { return at }), WithTimestampTruncation(tt.d)}, tt.opts...)
+		r := newTestReporter(t, newRegistryWithCounter(), w, opts...)
+		flush(t, r)
+
+		bp := w.written()[0]
+		if !bp.Points[0].Time.Equal(tt.want) {
+			t.Errorf("truncation %v: got time %v, want %v", tt.d, bp.Points[0].Time, tt.want)
+		}
+		// the precision of the writes is unchanged, the truncated digits are zeros
+		line := string(lines(bp, FloatFormat{})[0])
+		if want := strconv.FormatInt(tt.want.UnixNano(), 10) + "\n"; !strings.HasSuffix(line, " "+want) {
+			t.Errorf("truncation %v: got line %q, want the timestamp %s in nanoseconds", tt.d, line, want)
+		}
+	}
+}
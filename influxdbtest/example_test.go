@@ -0,0 +1,32 @@
+package influxdbtest_test
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+	"github.com/vrischmann/go-metrics-influxdb"
+	"github.com/vrischmann/go-metrics-influxdb/influxdbtest"
+)
+
+func ExampleMemoryWriter() {
+	reg := metrics.NewRegistry()
+	w := influxdbtest.NewMemoryWriter()
+	r, err := influxdb.New(reg, time.Second, "", "", "", "", influxdb.WithWriter(w), influxdb.WithTags(map[string]string{"host": "web1"}))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer r.Stop()
+
+	metrics.GetOrRegisterCounter("requests", reg).Inc(3)
+	if err := r.Flush(context.Background()); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	p, ok := w.Last("requests.count", map[string]string{"host": "web1"})
+	fmt.Println(ok, p.Fields["value"])
+	// Output: true 3
+}
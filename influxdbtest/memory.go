@@ -0,0 +1,128 @@
+// Package influxdbtest provides helpers to test code reporting metrics with the influxdb package
+// without an InfluxDB server.
+//
+// A MemoryWriter given to the reporter with influxdb.WithWriter records the points it writes,
+// which can then be looked up by measurement and tags:
+//
+//	w := influxdbtest.NewMemoryWriter()
+//	r, err := influxdb.New(reg, time.Second, "", "", "", "", influxdb.WithWriter(w))
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	metrics.GetOrRegisterCounter("requests", reg).Inc(1)
+//	if err := r.Flush(context.Background()); err != nil {
+//		t.Fatal(err)
+//	}
+//	p, ok := w.Last("requests.count", nil)
+//	if !ok || p.Fields["value"] != int64(1) {
+//		t.Errorf("unexpected points %v", w.Points())
+//	}
+package influxdbtest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/influxdata/influxdb/client"
+	"github.com/vrischmann/go-metrics-influxdb"
+)
+
+var _ influxdb.Writer = (*MemoryWriter)(nil)
+
+// MemoryWriter is an influxdb.Writer recording the batches written to it in memory.
+// It is safe for concurrent use, so that it can be inspected while the reporter runs.
+type MemoryWriter struct {
+	mu      sync.Mutex
+	batches []client.BatchPoints
+	err     error
+}
+
+// NewMemoryWriter returns an empty MemoryWriter.
+func NewMemoryWriter() *MemoryWriter {
+	return &MemoryWriter{}
+}
+
+// WriteBatch records bp, or returns the error set by FailWith without recording it.
+func (w *MemoryWriter) WriteBatch(ctx context.Context, bp client.BatchPoints) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.err != nil {
+		return w.err
+	}
+	bp.Points = append([]client.Point(nil), bp.Points...)
+	w.batches = append(w.batches, bp)
+	return nil
+}
+
+// FailWith makes the following writes fail with err, to test how failed writes are handled,
+// until it is called again with nil.
+func (w *MemoryWriter) FailWith(err error) {
+	w.mu.Lock()
+	w.err = err
+	w.mu.Unlock()
+}
+
+// Batches returns the batches written so far, oldest first.
+func (w *MemoryWriter) Batches() []client.BatchPoints {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return append([]client.BatchPoints(nil), w.batches...)
+}
+
+// Points returns the points of the batches written so far, in the order they were written.
+func (w *MemoryWriter) Points() []client.Point {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var pts []client.Point
+	for _, bp := range w.batches {
+		pts = append(pts, bp.Points...)
+	}
+	return pts
+}
+
+// Measurement returns the points written so far in the given measurement.
+func (w *MemoryWriter) Measurement(measurement string) []client.Point {
+	return w.Find(measurement, nil)
+}
+
+// Find returns the points written so far in the given measurement, any if empty, holding
+// every tag of tags with the same value. Their other tags aren't compared.
+func (w *MemoryWriter) Find(measurement string, tags map[string]string) []client.Point {
+	var res []client.Point
+	for _, p := range w.Points() {
+		if measurement != "" && p.Measurement != measurement || !hasTags(p, tags) {
+			continue
+		}
+		res = append(res, p)
+	}
+	return res
+}
+
+// Last returns the last point written in the given measurement holding tags, as Find,
+// and whether there is one.
+func (w *MemoryWriter) Last(measurement string, tags map[string]string) (client.Point, bool) {
+	pts := w.Find(measurement, tags)
+	if len(pts) == 0 {
+		return client.Point{}, false
+	}
+	return pts[len(pts)-1], true
+}
+
+// Reset forgets the batches written so far.
+func (w *MemoryWriter) Reset() {
+	w.mu.Lock()
+	w.batches = nil
+	w.mu.Unlock()
+}
+
+func hasTags(p client.Point, tags map[string]string) bool {
+	for k, v := range tags {
+		if got, ok := p.Tags[k]; !ok || got != v {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,61 @@
+package influxdbtest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/influxdata/influxdb/client"
+)
+
+func TestMemoryWriter(t *testing.T) {
+	w := NewMemoryWriter()
+	ctx := context.Background()
+	pts := []client.Point{
+		{Measurement: "requests.count", Tags: map[string]string{"host": "a"}, Fields: map[string]interface{}{"value": int64(1)}},
+		{Measurement: "requests.count", Tags: map[string]string{"host": "b"}, Fields: map[string]interface{}{"value": int64(2)}},
+		{Measurement: "latency.timer", Tags: map[string]string{"host": "a"}, Fields: map[string]interface{}{"count": int64(3)}},
+	}
+	if err := w.WriteBatch(ctx, client.BatchPoints{Points: pts[:2]}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteBatch(ctx, client.BatchPoints{Points: pts[2:]}); err != nil {
+		t.Fatal(err)
+	}
+	// the writer keeps its own copy of the points
+	pts[0].Measurement = "changed"
+
+	if n := len(w.Batches()); n != 2 {
+		t.Errorf("got %d batches, want 2", n)
+	}
+	if n := len(w.Points()); n != 3 {
+		t.Errorf("got %d points, want 3", n)
+	}
+	if n := len(w.Measurement("requests.count")); n != 2 {
+		t.Errorf("got %d points of requests.count, want 2", n)
+	}
+	if n := len(w.Find("", map[string]string{"host": "a"})); n != 2 {
+		t.Errorf("got %d points of host a, want 2", n)
+	}
+	if p, ok := w.Last("requests.count", map[string]string{"host": "b"}); !ok || p.Fields["value"] != int64(2) {
+		t.Errorf("got last point %v, want the one of host b", p)
+	}
+	if _, ok := w.Last("requests.count", map[string]string{"host": "c"}); ok {
+		t.Error("got a point of host c")
+	}
+
+	errDown := errors.New("down")
+	w.FailWith(errDown)
+	if err := w.WriteBatch(ctx, client.BatchPoints{Points: pts}); err != errDown {
+		t.Errorf("got error %v, want %v", err, errDown)
+	}
+	w.FailWith(nil)
+	if n := len(w.Points()); n != 3 {
+		t.Errorf("got %d points after a failed write, want 3", n)
+	}
+
+	w.Reset()
+	if n := len(w.Points()); n != 0 {
+		t.Errorf("got %d points after a reset, want none", n)
+	}
+}
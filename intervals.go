@@ -0,0 +1,55 @@
+package influxdb
+
+import (
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+// typeOf returns the type of the metric i as handled by appendPoints, or "" if it isn't reported.
+func typeOf(i interface{}) MetricType {
+	switch i.(type) {
+	case metrics.Counter:
+		return TypeCounter
+	case metrics.Gauge:
+		return TypeGauge
+	case metrics.GaugeFloat64:
+		return TypeGaugeFloat64
+	case metrics.Histogram:
+		return TypeHistogram
+	case metrics.Meter:
+		return TypeMeter
+	case metrics.Timer:
+		return TypeTimer
+	case int64Valuer:
+		return TypeGauge
+	case float64Valuer:
+		return TypeGaugeFloat64
+	case counter:
+		return TypeCounter
+	}
+	return ""
+}
+
+// dueTypes returns the metric types with their own interval that the flush at now skips,
+// and records the flush of the others. A type is due once its interval elapsed since its last flush,
+// give or take half the base interval to absorb the jitter of the ticker.
+func (r *Reporter) dueTypes(now time.Time) map[MetricType]bool {
+	if len(r.typeIntervals) == 0 {
+		return nil
+	}
+
+	slack := r.getInterval() / 2
+	skipped := make(map[MetricType]bool)
+	for t, d := range r.typeIntervals {
+		last, ok := r.typeFlushes[t]
+		if ok && now.Sub(last) < d-slack {
+			skipped[t] = true
+			continue
+		}
+		if !r.peeking {
+			r.typeFlushes[t] = now
+		}
+	}
+	return skipped
+}
@@ -0,0 +1,47 @@
+package influxdb
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+func TestTypeIntervals(t *testing.T) {
+	reg := metrics.NewRegistry()
+	metrics.GetOrRegisterCounter("requests", reg).Inc(1)
+	metrics.GetOrRegisterHistogram("latency", reg, metrics.NewUniformSample(100)).Update(1)
+	w := &testWriter{}
+	r := newTestReporter(t, reg, w, WithTypeInterval(TypeHistogram, 3*time.Minute))
+	now := fixedClock(r)
+	start := *now
+
+	both := []string{"latency.histogram", "requests.count"}
+	counter := []string{"requests.count"}
+	tests := []struct {
+		at   time.Duration
+		want []string
+	}{
+		{0, both},
+		{time.Minute, counter},
+		{2 * time.Minute, counter},
+		// the ticker may fire early, by less than half the one minute interval
+		{2*time.Minute + 50*time.Second, both},
+		{3*time.Minute + 50*time.Second, counter},
+	}
+	for _, tt := range tests {
+		*now = start.Add(tt.at)
+		before := len(w.points())
+		flush(t, r)
+		var got []string
+		for _, p := range w.points()[before:] {
+			got = append(got, p.Measurement)
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("got measurements %v at %s, want %v", got, tt.at, tt.want)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package influxdb
+
+import (
+	"fmt"
+	"time"
+)
+
+// LastUpdatedField is the field added by WithLastUpdated.
+const LastUpdatedField = "last_updated"
+
+// lastUpdate is the value of a metric at the time it last changed.
+type lastUpdate struct {
+	value string
+	time  time.Time
+}
+
+// addLastUpdated adds to fields, the fields of the metric of type t identified by id, the time of
+// the flush its value last changed at, in seconds since the Unix epoch, see WithLastUpdated.
+// The value of a metric is its "value" field, or its "count" field for the types without one.
+func (r *Reporter) addLastUpdated(fields map[string]interface{}, t MetricType, id string, now time.Time) {
+	if r.lastUpdates == nil {
+		return
+	}
+	v, ok := fields["value"]
+	if !ok {
+		if v, ok = fields["count"]; !ok {
+			return
+		}
+	}
+
+	value := fmt.Sprint(v)
+	prev, seen := r.lastUpdates[id]
+	changed := !seen || value != prev.value
+	if r.resetAfterRead && (t == TypeCounter || t == TypeHistogram) {
+		// the metric was cleared by the previous flush, any count is an update
+		changed = !seen || value != "0"
+	}
+	if changed {
+		prev = lastUpdate{value: value, time: now}
+		if !r.peeking {
+			r.lastUpdates[id] = prev
+		}
+	}
+	fields[LastUpdatedField] = prev.time.Unix()
+}
@@ -0,0 +1,73 @@
+package influxdb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestLastUpdated(t *testing.T) {
+	reg := metrics.NewRegistry()
+	g := metrics.GetOrRegisterGauge("queue", reg)
+	g.Update(1)
+	m := metrics.GetOrRegisterMeter("events", reg)
+	w := &testWriter{}
+	r := newTestReporter(t, reg, w, WithLastUpdated())
+	now := fixedClock(r)
+
+	for _, step := range []struct {
+		update       func()
+		queue, event int64
+	}{
+		// the first flush sees every value as new
+		{func() {}, 1000, 1000},
+		{func() {}, 1000, 1000},
+		{func() { g.Update(2) }, 1040, 1000},
+		// a value changed and changed back between two flushes isn't seen
+		{func() { g.Update(3); g.Update(2) }, 1040, 1000},
+		{func() { m.Mark(1) }, 1040, 1080},
+	} {
+		step.update()
+		w.batches = nil
+		flush(t, r)
+		for m, want := range map[string]int64{"queue.gauge": step.queue, "events.meter": step.event} {
+			pts := w.find(m)
+			if len(pts) != 1 || pts[0].Fields[LastUpdatedField] != want {
+				t.Errorf("flush at %d: got points %v of %s, want %s=%d", now.Unix(), pts, m, LastUpdatedField, want)
+			}
+		}
+		*now = now.Add(20 * time.Second)
+	}
+
+	// a snapshot doesn't record the changes it sees
+	g.Update(4)
+	r.Snapshot()
+	w.batches = nil
+	flush(t, r)
+	if pts := w.find("queue.gauge"); len(pts) != 1 || pts[0].Fields[LastUpdatedField] != now.Unix() {
+		t.Errorf("got points %v, want %s=%d", pts, LastUpdatedField, now.Unix())
+	}
+}
+
+func TestLastUpdatedResetAfterRead(t *testing.T) {
+	reg := metrics.NewRegistry()
+	c := metrics.GetOrRegisterCounter("requests", reg)
+	c.Inc(1)
+	w := &testWriter{}
+	r := newTestReporter(t, reg, w, WithLastUpdated(), WithResetAfterRead())
+	now := fixedClock(r)
+
+	// the counter is cleared by every flush, so any count is an update even when it repeats
+	for i, want := range []int64{1000, 1000, 1040} {
+		if i == 2 {
+			c.Inc(1)
+		}
+		w.batches = nil
+		flush(t, r)
+		if pts := w.find("requests.count"); len(pts) != 1 || pts[0].Fields[LastUpdatedField] != want {
+			t.Errorf("flush %d: got points %v, want %s=%d", i, pts, LastUpdatedField, want)
+		}
+		*now = now.Add(20 * time.Second)
+	}
+}
@@ -0,0 +1,51 @@
+package influxdb
+
+import (
+	"sort"
+	"time"
+
+	"github.com/influxdata/influxdb/client"
+)
+
+// Layout selects how the fields of a metric are spread over points.
+type Layout string
+
+const (
+	// LayoutWide writes one point per metric, in a measurement named after the metric,
+	// with one field per statistic. This is the default.
+	LayoutWide Layout = "wide"
+	// LayoutNarrow writes one point per statistic, in a measurement named after the metric type
+	// and the statistic such as "timer.p99", with the metric name in the MetricTag tag and the
+	// statistic in a single "value" field.
+	LayoutNarrow Layout = "narrow"
+)
+
+// MetricTag is the tag holding the metric name in the narrow layout.
+const MetricTag = "metric"
+
+// narrowPoints appends to pts one point per field of a metric, as laid out by LayoutNarrow.
+func (r *Reporter) narrowPoints(pts []client.Point, name string, t MetricType, fields map[string]interface{}, tags map[string]string, now time.Time) []client.Point {
+	metricTags := make(map[string]string, len(tags)+1)
+	for k, v := range tags {
+		metricTags[k] = v
+	}
+	metricTags[MetricTag] = r.metricName(name)
+
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		pts = append(pts, client.Point{
+			Measurement: r.replaceDots(string(t) + "." + k),
+			Tags:        metricTags,
+			Fields:      map[string]interface{}{"value": fields[k]},
+			Time:        now,
+			Precision:   r.precisionFor(t),
+		})
+	}
+
+	return pts
+}
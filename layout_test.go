@@ -0,0 +1,62 @@
+package influxdb
+
+import (
+	"testing"
+	"time"
+
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+func TestNarrowLayout(t *testing.T) {
+	reg := metrics.NewRegistry()
+	metrics.GetOrRegisterCounter("requests", reg).Inc(3)
+	h := metrics.GetOrRegisterHistogram("latency", reg, metrics.NewUniformSample(100))
+	for i := int64(1); i <= 10; i++ {
+		h.Update(i)
+	}
+	tags := map[string]string{"host": "web1"}
+	now := time.Unix(1000, 0)
+
+	wide, err := BuildPoints(reg, tags, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	narrow, err := BuildPoints(reg, tags, now, WithLayout(LayoutNarrow))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// one point per field of the wide layout, the metric name moving to a tag
+	type stat struct{ measurement, metric string }
+	want := make(map[stat]interface{})
+	for _, p := range wide {
+		var metric, typ string
+		switch p.Measurement {
+		case "requests.count":
+			metric, typ = "requests", "counter"
+		case "latency.histogram":
+			metric, typ = "latency", "histogram"
+		default:
+			t.Fatalf("got point of %s", p.Measurement)
+		}
+		for k, v := range p.Fields {
+			want[stat{typ + "." + k, metric}] = v
+		}
+	}
+	if len(narrow) != len(want) {
+		t.Fatalf("got %d points, want one per statistic %v", len(narrow), want)
+	}
+	for _, p := range narrow {
+		v, ok := want[stat{p.Measurement, p.Tags[MetricTag]}]
+		if !ok || len(p.Fields) != 1 || p.Fields["value"] != v {
+			t.Errorf("got point %s %v %v, want the value %v", p.Measurement, p.Tags, p.Fields, v)
+		}
+		if p.Tags["host"] != "web1" || len(p.Tags) != 2 || !p.Time.Equal(now) {
+			t.Errorf("got tags %v and time %v of %s, want those of the metric", p.Tags, p.Time, p.Measurement)
+		}
+	}
+
+	if _, err := BuildPoints(reg, tags, now, WithLayout("tall")); err == nil {
+		t.Error("got no error for an unknown layout")
+	}
+}
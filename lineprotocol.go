@@ -0,0 +1,222 @@
+package influxdb
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/influxdata/influxdb/client"
+)
+
+// The characters escaped with a backslash in the line protocol syntax elements. Newlines would end
+// the line, they are written as the two characters \n.
+const (
+	measurementSpecial = ", "
+	keySpecial         = ",= "
+)
+
+var stringEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// escapeName escapes the characters of s in special. The parser reads a backslash as escaping the next
+// character, so an odd run of backslashes before an escaped character, or at the end of s, would escape
+// the backslash escaping it, or the separator following s: another backslash is added.
+func escapeName(special, s string) string {
+	if !strings.ContainsAny(s, special+"\\\n") {
+		return s
+	}
+
+	b := make([]byte, 0, len(s)+8)
+	backslashes := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\n':
+			b = append(b, `\n`...)
+		case strings.IndexByte(special, c) >= 0:
+			if backslashes%2 == 1 {
+				b = append(b, '\\')
+			}
+			b = append(b, '\\', c)
+		default:
+			b = append(b, c)
+		}
+		if c == '\\' {
+			backslashes++
+		} else {
+			backslashes = 0
+		}
+	}
+	if backslashes%2 == 1 {
+		b = append(b, '\\')
+	}
+	return string(b)
+}
+
+// escapeMeasurement escapes a measurement name for the line protocol.
+func escapeMeasurement(s string) string {
+	return escapeName(measurementSpecial, s)
+}
+
+// escapeKey escapes a tag key, a tag value or a field key for the line protocol.
+func escapeKey(s string) string {
+	return escapeName(keySpecial, s)
+}
+
+// escapeStringField escapes a string field value for the line protocol, without the surrounding quotes.
+func escapeStringField(s string) string {
+	return stringEscaper.Replace(s)
+}
+
+// precisionMultipliers divide nanosecond timestamps to the unit of a precision.
+var precisionMultipliers = map[string]int64{
+	"u":  1e3,
+	"ms": 1e6,
+	"s":  1e9,
+	"m":  60e9,
+	"h":  3600e9,
+}
+
+// appendLine appends p to b as a line of line protocol, without the trailing newline,
+// with its timestamp in the given precision. Tags with an empty key or value are omitted,
+// tags are written in the order of their escaped keys, the one of the series keys of InfluxDB, and fields
+// in key order, so that the line of a point is always the same.
+func appendLine(b []byte, p client.Point, precision string, floats FloatFormat) ([]byte, error) {
+	if p.Measurement == "" {
+		return b, errors.New("missing measurement")
+	}
+	if len(p.Fields) == 0 {
+		return b, fmt.Errorf("point %s has no fields", p.Measurement)
+	}
+
+	b = append(b, escapeMeasurement(p.Measurement)...)
+
+	// InfluxDB sorts the tags of a line by their escaped keys unless they already are,
+	// which can differ from the order of the keys themselves, "a b" is written "a\ b" after "aZ"
+	tags := make([][2]string, 0, len(p.Tags))
+	for k, v := range p.Tags {
+		if k != "" && v != "" {
+			tags = append(tags, [2]string{escapeKey(k), escapeKey(v)})
+		}
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i][0] < tags[j][0] })
+	for _, tag := range tags {
+		b = append(b, ',')
+		b = append(b, tag[0]...)
+		b = append(b, '=')
+		b = append(b, tag[1]...)
+	}
+
+	keys := make([]string, 0, len(p.Fields))
+	for k, v := range p.Fields {
+		if v != nil {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for i, k := range keys {
+		if i == 0 {
+			b = append(b, ' ')
+		} else {
+			b = append(b, ',')
+		}
+		b = append(b, escapeKey(k)...)
+		b = append(b, '=')
+
+		var err error
+		if b, err = appendFieldValue(b, p.Fields[k], floats); err != nil {
+			return b, fmt.Errorf("field %s of point %s: %v", k, p.Measurement, err)
+		}
+	}
+
+	if !p.Time.IsZero() {
+		ts := p.Time.UnixNano()
+		if m, ok := precisionMultipliers[precision]; ok {
+			ts /= m
+		}
+		b = append(b, ' ')
+		b = strconv.AppendInt(b, ts, 10)
+	}
+
+	return b, nil
+}
+
+// FloatFormat selects how the line protocol writers serialize float fields, with the format and
+// precision of strconv.FormatFloat. The zero value is the default, 'f' with the smallest precision
+// representing the value exactly, which never uses scientific notation and is what the InfluxDB
+// client writes.
+type FloatFormat struct {
+	// Format is 'f', 'e' or 'g'.
+	Format byte
+	// Precision is the number of digits: after the decimal point for 'f' and 'e',
+	// significant ones for 'g'. -1 selects the smallest number representing the value exactly.
+	Precision int
+}
+
+func (f FloatFormat) validate() error {
+	switch f.Format {
+	case 0, 'f', 'e', 'g':
+	default:
+		return fmt.Errorf("unsupported float format %q", f.Format)
+	}
+	if f.Precision < -1 {
+		return fmt.Errorf("float precision %d must be -1 or more", f.Precision)
+	}
+	return nil
+}
+
+// appendFloat appends v to b formatted with f, for a float of the given bit size.
+func (f FloatFormat) appendFloat(b []byte, v float64, bitSize int) ([]byte, error) {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return b, fmt.Errorf("unsupported value %v", v)
+	}
+	if f.Format == 0 {
+		return strconv.AppendFloat(b, v, 'f', -1, bitSize), nil
+	}
+	return strconv.AppendFloat(b, v, f.Format, f.Precision, bitSize), nil
+}
+
+// appendFieldValue appends a field value to b, typed like the InfluxDB client does.
+func appendFieldValue(b []byte, v interface{}, floats FloatFormat) ([]byte, error) {
+	switch v := v.(type) {
+	case float64:
+		return floats.appendFloat(b, v, 64)
+	case float32:
+		return floats.appendFloat(b, float64(v), 32)
+	case int64:
+		return append(strconv.AppendInt(b, v, 10), 'i'), nil
+	case int:
+		return append(strconv.AppendInt(b, int64(v), 10), 'i'), nil
+	case int32:
+		return append(strconv.AppendInt(b, int64(v), 10), 'i'), nil
+	case int16:
+		return append(strconv.AppendInt(b, int64(v), 10), 'i'), nil
+	case int8:
+		return append(strconv.AppendInt(b, int64(v), 10), 'i'), nil
+	case uint64:
+		return append(strconv.AppendUint(b, v, 10), 'u'), nil
+	case uint:
+		// written as a signed integer like the InfluxDB client does, for compatibility
+		return append(strconv.AppendInt(b, int64(v), 10), 'i'), nil
+	case uint32:
+		return append(strconv.AppendInt(b, int64(v), 10), 'i'), nil
+	case uint16:
+		return append(strconv.AppendInt(b, int64(v), 10), 'i'), nil
+	case uint8:
+		return append(strconv.AppendInt(b, int64(v), 10), 'i'), nil
+	case bool:
+		return strconv.AppendBool(b, v), nil
+	case string:
+		return appendString(b, v), nil
+	default:
+		return appendString(b, fmt.Sprintf("%v", v)), nil
+	}
+}
+
+func appendString(b []byte, s string) []byte {
+	b = append(b, '"')
+	b = append(b, escapeStringField(s)...)
+	return append(b, '"')
+}
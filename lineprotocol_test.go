@@ -0,0 +1,225 @@
+package influxdb
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb/client"
+)
+
+func TestEscape(t *testing.T) {
+	for _, tt := range []struct {
+		escape func(string) string
+		in     string
+		want   string
+	}{
+		{escapeMeasurement, "cpu load", `cpu\ load`},
+		{escapeMeasurement, "cpu,host", `cpu\,host`},
+		{escapeMeasurement, "cpu=1", `cpu=1`},
+		{escapeMeasurement, "a\nb", `a\nb`},
+		{escapeMeasurement, `trailing\`, `trailing\\`},
+		{escapeMeasurement, `trailing\\`, `trailing\\`},
+		{escapeKey, "host name", `host\ name`},
+		{escapeKey, "a,b=c", `a\,b\=c`},
+		{escapeKey, `C:\`, `C:\\`},
+		{escapeKey, `a\b`, `a\b`},
+		{escapeKey, `a\,b`, `a\\\,b`},
+		{escapeKey, `a\\ b`, `a\\\ b`},
+		{escapeKey, "", ""},
+		{escapeStringField, `say "hi"`, `say \"hi\"`},
+		{escapeStringField, `C:\dir\`, `C:\\dir\\`},
+		{escapeStringField, "a b,c=d", "a b,c=d"},
+	} {
+		if got := tt.escape(tt.in); got != tt.want {
+			t.Errorf("escaping %q: got %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestAppendLine(t *testing.T) {
+	p := client.Point{
+		Measurement: "http requests",
+		Tags:        map[string]string{"path": "/a,b", "host": "web 1", "empty": ""},
+		Fields:      map[string]interface{}{"count": int64(3), "rate": 1.5, "ok": true, "msg": `a "b"`, "nil": nil},
+		Time:        time.Unix(1, 500),
+	}
+	got, err := appendLine(nil, p, "ms", FloatFormat{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `http\ requests,host=web\ 1,path=/a\,b count=3i,msg="a \"b\"",ok=true,rate=1.5 1000`
+	if string(got) != want {
+		t.Errorf("got line\n%s\nwant\n%s", got, want)
+	}
+
+	for _, p := range []client.Point{
+		{Fields: map[string]interface{}{"value": 1}},
+		{Measurement: "m"},
+		{Measurement: "m", Fields: map[string]interface{}{"value": math.NaN()}},
+		{Measurement: "m", Fields: map[string]interface{}{"value": math.Inf(-1)}},
+	} {
+		if _, err := appendLine(nil, p, "", FloatFormat{}); err == nil {
+			t.Errorf("got no error for point %v", p)
+		}
+	}
+}
+
+func TestInvalidPointsAreCounted(t *testing.T) {
+	reg := newRegistryWithCounter()
+	g := newGaugeFloat64(reg, "load")
+	g.Update(math.NaN())
+	w := &testWriter{}
+	l := &testLogger{}
+	var stats FlushStats
+	r := newTestReporter(t, reg, w, WithLogger(l), WithOnFlush(func(s FlushStats) { stats = s }))
+	flush(t, r)
+
+	if stats.Dropped.Invalid != 1 || stats.Points != 1 {
+		t.Errorf("got %d invalid points of %d, want 1 of 1", stats.Dropped.Invalid, stats.Points)
+	}
+	if len(w.find("load.gauge")) != 0 || len(w.find("requests.count")) != 1 {
+		t.Errorf("got points %v, want the NaN gauge to be skipped", w.points())
+	}
+	if msgs := l.messages(); len(msgs) != 1 || !strings.Contains(msgs[0], "NaN") {
+		t.Errorf("got log messages %q", msgs)
+	}
+}
+
+// scanToken returns the length of the token at the start of s as the line protocol parser reads it:
+// up to the first unescaped byte of seps, a backslash escaping the byte following it.
+func scanToken(s, seps string) int {
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\':
+			i++
+		case strings.IndexByte(seps, s[i]) >= 0:
+			return i
+		}
+	}
+	return len(s)
+}
+
+func FuzzEscape(f *testing.F) {
+	for _, s := range []string{"", "a", "a b", "a,b", "a=b", `a\`, `a\\`, `a\,`, "a\nb", `"`, `\"`} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		// an escaped name is read as a single token, whatever follows it
+		if m := escapeMeasurement(s); scanToken(m+", x", ", \n") != len(m) {
+			t.Errorf("measurement %q escaped as %q isn't a single token", s, m)
+		}
+		if k := escapeKey(s); scanToken(k+"=,x", ",= \n") != len(k) {
+			t.Errorf("key %q escaped as %q isn't a single token", s, k)
+		}
+		if v := escapeStringField(s); scanToken(v+`"x`, `"`) != len(v) {
+			t.Errorf("string %q escaped as %q ends before its closing quote", s, v)
+		}
+
+		// the string fields escape the backslash itself, so they are read back unchanged
+		unescaped := strings.NewReplacer(`\\`, `\`, `\"`, `"`).Replace(escapeStringField(s))
+		if unescaped != s {
+			t.Errorf("string %q read back as %q", s, unescaped)
+		}
+	})
+}
+
+// lineFloat matches the floats accepted by the line protocol parser of InfluxDB.
+var lineFloat = regexp.MustCompile(`^[-+]?([0-9]+(\.[0-9]*)?|\.[0-9]+)([eE][-+]?[0-9]+)?$`)
+
+func TestFloatFormat(t *testing.T) {
+	tests := []struct {
+		v      float64
+		format FloatFormat
+		want   string
+	}{
+		{1e20, FloatFormat{}, "100000000000000000000"},
+		{1e-20, FloatFormat{}, "0.00000000000000000001"},
+		{-1.5e20, FloatFormat{}, "-150000000000000000000"},
+		{1e20, FloatFormat{Format: 'f', Precision: 2}, "100000000000000000000.00"},
+		{1e-20, FloatFormat{Format: 'f', Precision: 6}, "0.000000"},
+		{1e20, FloatFormat{Format: 'e', Precision: -1}, "1e+20"},
+		{1e-20, FloatFormat{Format: 'e', Precision: 2}, "1.00e-20"},
+		{1e20, FloatFormat{Format: 'g', Precision: 3}, "1e+20"},
+		{0.125, FloatFormat{Format: 'g', Precision: -1}, "0.125"},
+	}
+	for _, tt := range tests {
+		b, err := tt.format.appendFloat(nil, tt.v, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != tt.want {
+			t.Errorf("%v with %+v: got %s, want %s", tt.v, tt.format, b, tt.want)
+		}
+		if !lineFloat.Match(b) {
+			t.Errorf("%v with %+v: %s isn't a line protocol float", tt.v, tt.format, b)
+		}
+	}
+
+	for _, v := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+		if b, err := (FloatFormat{}).appendFloat(nil, v, 64); err == nil {
+			t.Errorf("got %s and no error for %v", b, v)
+		}
+	}
+	for _, f := range []FloatFormat{{Format: 'x'}, {Format: 'b'}, {Format: 'f', Precision: -2}} {
+		if err := f.validate(); err == nil {
+			t.Errorf("got no error for format %+v", f)
+		}
+	}
+}
+
+// tagKeys returns the escaped keys of the tags of line, in the order they are written.
+func tagKeys(line string) []string {
+	i := scanToken(line, ", ")
+	var keys []string
+	for i < len(line) && line[i] == ',' {
+		line = line[i+1:]
+		k := scanToken(line, "=")
+		keys = append(keys, line[:k])
+		i = k + 1 + scanToken(line[k+1:], ", ")
+	}
+	return keys
+}
+
+func TestTagOrder(t *testing.T) {
+	p := client.Point{
+		Measurement: "m",
+		// "a=" is written a\= after aA, the backslash sorting after the letters
+		Tags:   map[string]string{"b": "1", "a=": "2", "aA": "3", "a b": "4", "A": "5"},
+		Fields: map[string]interface{}{"value": 1},
+	}
+	for i := 0; i < 10; i++ {
+		got, err := appendLine(nil, p, "", FloatFormat{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := `m,A=5,aA=3,a\ b=4,a\==2,b=1 value=1i`
+		if string(got) != want {
+			t.Fatalf("got line\n%s\nwant\n%s", got, want)
+		}
+	}
+}
+
+func FuzzTagOrder(f *testing.F) {
+	f.Add("a", "b", "c")
+	f.Add("a b", "aZ", "a,")
+	f.Add(`a\`, "a=", "")
+	f.Fuzz(func(t *testing.T, k1, k2, k3 string) {
+		tags := map[string]string{k1: "1", k2: "2", k3: "3"}
+		line, err := appendLine(nil, client.Point{Measurement: "m", Tags: tags, Fields: map[string]interface{}{"value": 1}}, "", FloatFormat{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys := tagKeys(string(line))
+		if !sort.StringsAreSorted(keys) {
+			t.Errorf("got tags %q of line %s, want them sorted", keys, line)
+		}
+		delete(tags, "")
+		if len(keys) != len(tags) {
+			t.Errorf("got tags %q of line %s, want %d", keys, line, len(tags))
+		}
+	})
+}
@@ -0,0 +1,32 @@
+package influxdb
+
+import "log"
+
+// Logger receives the log messages of a reporter. A *log.Logger implements it.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// stdLogger logs with the standard logger of the log package.
+type stdLogger struct{}
+
+func (stdLogger) Printf(format string, v ...interface{}) {
+	log.Printf(format, v...)
+}
+
+// warnf logs a warning about the points being built, unless they are built by Snapshot, which would
+// repeat the warnings of the flushes.
+func (r *Reporter) warnf(format string, v ...interface{}) {
+	if !r.peeking {
+		r.logf(format, v...)
+	}
+}
+
+// logf logs a message with the logger of the reporter, prefixed by its name if it has one.
+func (r *Reporter) logf(format string, v ...interface{}) {
+	if r.name == "" {
+		r.logger.Printf(format, v...)
+		return
+	}
+	r.logger.Printf("%s: "+format, append([]interface{}{r.name}, v...)...)
+}
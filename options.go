@@ -0,0 +1,969 @@
+package influxdb
+
+import (
+	"context"
+	"time"
+)
+
+// Option configures optional behaviour of a reporter.
+type Option func(*Reporter)
+
+// WithName names the reporter, to tell apart the reporters of a process: every log message
+// is prefixed by the name. The default is no name.
+func WithName(name string) Option {
+	return func(r *Reporter) {
+		r.name = name
+	}
+}
+
+// WithLogger sends the log messages of the reporter to l instead of the standard logger.
+func WithLogger(l Logger) Option {
+	return func(r *Reporter) {
+		r.logger = l
+	}
+}
+
+// WithTags adds the given tags to every point. The map is copied, changing it afterwards has no effect.
+func WithTags(tags map[string]string) Option {
+	tags = copyTags(tags)
+	return func(r *Reporter) {
+		r.tags = tags
+	}
+}
+
+// WithAnnotations writes at every flush a point in the given measurement, DefaultAnnotationMeasurement
+// if empty, for every metric registered or unregistered since the previous flush, so that series
+// appearing or disappearing can be correlated with deploys. The points are tagged with the metric name
+// under MetricTag, prefixed with the registry name for the registries added with AddRegistry, and hold
+// an "event" field set to "registered" or "unregistered". The first flush writes none, and at most
+// 100 are written per flush.
+func WithAnnotations(measurement string) Option {
+	return func(r *Reporter) {
+		if measurement == "" {
+			measurement = DefaultAnnotationMeasurement
+		}
+		r.annotations = measurement
+	}
+}
+
+// WithLayout selects how the fields of a metric are spread over points, see Layout.
+// The narrow layout stores every metric name as a value of MetricTag, so every metric becomes
+// a series of each of its measurements: this multiplies the series cardinality by the number of
+// metrics, which matters with many metrics or short-lived metric names.
+func WithLayout(l Layout) Option {
+	return func(r *Reporter) {
+		r.layout = l
+	}
+}
+
+// WithHeartbeat adds to every flush a point of the given measurement, DefaultHeartbeatMeasurement
+// if empty, with the global tags and an "uptime" field holding the seconds elapsed since the reporter
+// was created. It is written even when the registries are empty or nothing changed, so that a silent
+// process can be told apart from a dead one.
+func WithHeartbeat(measurement string) Option {
+	return func(r *Reporter) {
+		if measurement == "" {
+			measurement = DefaultHeartbeatMeasurement
+		}
+		r.heartbeat = measurement
+	}
+}
+
+// WithUptime writes at every flush a point in the given measurement, DefaultUptimeMeasurement
+// if empty, with the seconds elapsed since the reporter was created in a "value" field.
+// The uptime dropping back to about 0 reveals a restart of the process.
+func WithUptime(measurement string) Option {
+	return func(r *Reporter) {
+		if measurement == "" {
+			measurement = DefaultUptimeMeasurement
+		}
+		r.uptime = measurement
+	}
+}
+
+// WithConfigFetcher makes the reporter get its interval and tags from fetch when it starts and
+// at each every, so that they can be tuned centrally, for example from a configuration service.
+// fetch runs under a context bounded like a write. When it fails, the failure is logged and
+// the current interval and tags are kept.
+func WithConfigFetcher(fetch func(ctx context.Context) (RemoteConfig, error), every time.Duration) Option {
+	return func(r *Reporter) {
+		r.fetcher = fetch
+		r.fetchEvery = every
+	}
+}
+
+// WithNormalizedNames trims the whitespace and dots surrounding the metric names and collapses
+// repeated dots before forming the measurement names, so that a metric "svc..requests." is written
+// as "svc.requests.count" instead of "svc..requests..count". It changes the measurement of such
+// metrics, so it is off by default. The metrics whose name is empty once normalized, such as "..",
+// are skipped and logged.
+func WithNormalizedNames() Option {
+	return func(r *Reporter) {
+		r.normalizeNames = true
+	}
+}
+
+// WithSuffixSeparator joins the metric names and the suffixes of their type with sep rather than ".",
+// so that with "_" a counter "requests" is written as "requests_count", matching underscore based
+// naming conventions. It applies to every metric type but not to the types with a template set by
+// WithMeasurementTemplate, whose template holds its own separator.
+func WithSuffixSeparator(sep string) Option {
+	return func(r *Reporter) {
+		r.suffixSep = sep
+	}
+}
+
+// WithDotReplacement replaces every dot of the measurements with s, such as "_" to write the counter
+// "api.requests" as "api_requests_count", for tools struggling with dotted measurement names. It applies
+// to the whole measurement, the metric name included, after every other naming option: WithSuffixSeparator,
+// WithMeasurementTemplate, WithNormalizedNames and so on, and to the measurements of the reporter
+// itself, such as those of WithHeartbeat, WithUptime, WithRuntimeInfo and WithAnnotations.
+// The name in the tag of the narrow layout is unchanged. The default keeps the dots.
+func WithDotReplacement(s string) Option {
+	return func(r *Reporter) {
+		r.dotReplacement = s
+	}
+}
+
+// WithoutDuplicateSuffixes doesn't append the suffix of the metric type to the names already ending
+// with it, so that a counter "requests.count" is written as "requests.count" rather than
+// "requests.count.count". It changes the measurement of such metrics, so it is off by default.
+// It doesn't apply to the types with a template set by WithMeasurementTemplate.
+func WithoutDuplicateSuffixes() Option {
+	return func(r *Reporter) {
+		r.dedupSuffixes = true
+	}
+}
+
+// WithMeasurementTemplate forms the measurement of the metrics of type t from tmpl, in which
+// "{name}" is replaced by the metric name, "{type}" by the metric type such as "counter" and
+// "{suffix}" by the suffix of the type such as "count". The default is "{name}.{suffix}".
+// For example "app_{type}_{name}" prefixes every measurement, and "{name}" combined with
+// WithTypeTags(t, map[string]string{"type": "counter"}) moves the type to a tag.
+// New returns an error for a template holding another placeholder.
+func WithMeasurementTemplate(t MetricType, tmpl string) Option {
+	return func(r *Reporter) {
+		if r.templates == nil {
+			r.templates = make(map[MetricType]string)
+		}
+		r.templates[t] = tmpl
+	}
+}
+
+// WithGroups merges the counters and gauges whose name matches one of the patterns into one point
+// per prefix, the part of the name before its last dot, with the rest of the name as field name:
+// with WithGroups("cpu.*"), the gauges "cpu.user", "cpu.system" and "cpu.idle" are written as the
+// fields user, system and idle of a single point in the measurement "cpu", instead of three
+// measurements. The other fields of the metrics, such as those of WithDeltas, are suffixed with their
+// name, such as "user_delta". The patterns use the syntax of path.Match. Metrics with differing tags
+// are written in separate points. When two metrics of a group would write the same field, such as
+// "cpu.user" and "cpu.user_delta", the first one is kept. It isn't supported by the narrow layout.
+func WithGroups(patterns ...string) Option {
+	return func(r *Reporter) {
+		r.groups = append(r.groups, patterns...)
+	}
+}
+
+// WithDatabaseFunc writes every flush to the database returned by fn, called once per flush,
+// instead of the database given to the constructor, which is used when fn returns an empty name.
+// It suits time-partitioned schemes where old databases are dropped rather than expired, such as
+//
+//	WithDatabaseFunc(func() string { return time.Now().UTC().Format("metrics_2006_01") })
+//
+// The databases of WithRoute still apply to the metrics they match. A queued batch is written to
+// the database of the flush which built it.
+func WithDatabaseFunc(fn func() string) Option {
+	return func(r *Reporter) {
+		r.databaseFunc = fn
+	}
+}
+
+// WithRoute writes the metrics whose name matches pattern to database, with retentionPolicy when
+// not empty, instead of the database of the reporter, so that for example the business metrics
+// are kept longer than the infrastructure ones. The pattern uses the syntax of path.Match and
+// the first matching route applies, in the order they were added. Every flush issues one write per
+// database and retention policy. Groups of WithGroups are routed by their name, and the points
+// of the reporter itself, such as the heartbeat, go to the database of the reporter. The points
+// of a queued flush are written to the databases they were routed to when it was built.
+func WithRoute(pattern, database, retentionPolicy string) Option {
+	return func(r *Reporter) {
+		r.routes = append(r.routes, route{pattern: pattern, database: database, retentionPolicy: retentionPolicy})
+	}
+}
+
+// WithBuildInfo registers in the registry a gauge named BuildInfoMetric with the value 1,
+// tagged with the version, commit and go_version of the running binary as reported by
+// runtime/debug.ReadBuildInfo, so that every service gets a series telling which build it runs.
+func WithBuildInfo() Option {
+	return func(r *Reporter) {
+		r.buildInfo = true
+	}
+}
+
+// WithRuntimeInfo writes at every flush a point in the given measurement, DefaultRuntimeInfoMeasurement
+// if empty, with the number of goroutines, of CPUs and GOMAXPROCS in the integer fields goroutines,
+// num_cpu and gomaxprocs, and the Go version in go_version. Unlike WithRuntimeStats it doesn't read
+// the memory statistics, which stops the world, and it is collected by the flush itself.
+func WithRuntimeInfo(measurement string) Option {
+	return func(r *Reporter) {
+		if measurement == "" {
+			measurement = DefaultRuntimeInfoMeasurement
+		}
+		r.runtimeInfo = measurement
+	}
+}
+
+// WithRuntimeStats registers the Go runtime metrics of go-metrics, such as runtime.MemStats.HeapAlloc,
+// in the registry given to the constructor when the reporter starts, and captures them at each d
+// until it stops. The go-metrics runtime metrics are process wide, so enable this on one reporter only.
+func WithRuntimeStats(d time.Duration) Option {
+	return func(r *Reporter) {
+		r.runtimeInterval = d
+	}
+}
+
+// WithMetricTags adds the given tags to the points of the metrics whose name matches pattern,
+// for example WithMetricTags("db.*", map[string]string{"component": "database"}). The patterns
+// use the syntax of path.Match. These tags override the ones of WithTags and WithTypeTags, and those
+// of a pattern override the ones of the patterns added before it. The tag of WithRegistryTag
+// overrides them all.
+func WithMetricTags(pattern string, tags map[string]string) Option {
+	tags = copyTags(tags)
+	return func(r *Reporter) {
+		r.metricTags = append(r.metricTags, metricTags{pattern: pattern, tags: tags})
+	}
+}
+
+// WithAllowedTagKeys drops from every point the tags whose key isn't one of keys, logging every
+// dropped key once. This guards shared InfluxDB servers against a bug introducing an unbounded
+// tag key, such as a request id, which would explode the number of series. Dropped tags are
+// counted as "dropped_tags" by WithSelfMetrics. The keys of WithTags and WithMetricTags must be
+// listed too, the tag of WithRegistryTag and MetricTag of the narrow layout and of the points
+// of WithAnnotations are always allowed.
+// The default allows every key.
+func WithAllowedTagKeys(keys ...string) Option {
+	return func(r *Reporter) {
+		if r.allowedTags == nil {
+			r.allowedTags = make(map[string]bool)
+			r.loggedTags = make(map[string]bool)
+		}
+		for _, k := range keys {
+			r.allowedTags[k] = true
+		}
+	}
+}
+
+// WithSeriesWarning logs a warning when a flush produces more than n distinct series, that is
+// combinations of measurement and tags: a high cardinality is the first cause of InfluxDB
+// operational problems. The default is 10000, 0 or less disables the warning. The number of series
+// of the last flush is reported as "series" by WithSelfMetrics.
+func WithSeriesWarning(n int) Option {
+	return func(r *Reporter) {
+		r.seriesWarning = n
+	}
+}
+
+// WithRegistryTag adds to every point a tag with the given key holding the name of the registry
+// the metric comes from, "default" for the registry given to the constructor, see AddRegistry.
+// Without it, a metric whose name is already reported from a previous registry is skipped.
+func WithRegistryTag(key string) Option {
+	return func(r *Reporter) {
+		r.registryTag = key
+	}
+}
+
+// WithRegistrySnapshot makes every flush collect the names of the registered metrics up front,
+// then read each metric in name order. A metric unregistered in between is skipped, so that
+// concurrent registrations never affect a flush in progress.
+func WithRegistrySnapshot() Option {
+	return func(r *Reporter) {
+		r.snapshotNames = true
+	}
+}
+
+// WithWriteTimeout bounds the time a flush may spend writing its batch, including the replay of
+// the write-ahead log. A write taking longer is aborted and reported as failed. The timeout never
+// exceeds the interval, which is also the default, so that a hung write can't delay the next flush.
+// With WithAsync, writes don't delay flushes and the timeout may exceed the interval.
+func WithWriteTimeout(d time.Duration) Option {
+	return func(r *Reporter) {
+		r.writeTimeout = d
+	}
+}
+
+// WithFloatFormat selects how float fields are serialized by the writers producing line protocol
+// themselves: the default writer, NewSocketWriter, NewHTTPWriter, NewStreamWriter and the write-ahead log.
+// For example FloatFormat{Format: 'f', Precision: 6} rounds to the microunit, which keeps lines
+// short, but writes 1e-20 as 0.
+// NaN and infinite values are never written, as line protocol can't represent them.
+func WithFloatFormat(f FloatFormat) Option {
+	return func(r *Reporter) {
+		r.floats = f
+	}
+}
+
+// WithMaxPointBytes skips and logs the points whose line protocol exceeds n bytes, such as the point
+// of a histogram with a huge list of percentiles, so that a single pathological metric doesn't
+// get the whole batch rejected by a server limiting the size of a point. Skipped points are counted
+// by the "oversized_points" self-metric and in FlushStats. The default is no limit.
+func WithMaxPointBytes(n int) Option {
+	return func(r *Reporter) {
+		r.maxPointBytes = n
+	}
+}
+
+// WithMaxBatchBytes splits the batches whose line protocol exceeds n bytes into several writes,
+// for servers or proxies limiting the size of a request body. When a chunk fails, the batch stays
+// queued as a whole: rewriting the chunks already written is harmless, their points overwrite
+// themselves. A point larger than n is written alone.
+func WithMaxBatchBytes(n int) Option {
+	return func(r *Reporter) {
+		r.maxBatchBytes = n
+	}
+}
+
+// WithCompressionRatio tells WithMaxBatchBytes that the writer compresses the batches, for example
+// a custom Writer using gzip, so that the limit applies to the compressed size estimated as the size
+// of the line protocol divided by ratio. This is a heuristic: line protocol of metrics usually
+// compresses 5 to 10 times, but a ratio higher than the actual one makes chunks exceed the limit.
+// Pick the lowest ratio measured, or leave it unset to size the chunks uncompressed.
+func WithCompressionRatio(ratio float64) Option {
+	return func(r *Reporter) {
+		r.compressionRatio = ratio
+	}
+}
+
+// WithRetry writes a batch again up to attempts times when its write fails, waiting backoff before
+// the first retry and doubling the wait after every retry. The retries are bounded by the write
+// timeout of the flush. A retry writes the points with the timestamps of their flush, so that
+// rewriting a batch which InfluxDB actually stored before the previous attempt timed out overwrites
+// the points rather than duplicating them. Only the errors accepted by WithRetryable are retried.
+func WithRetry(attempts int, backoff time.Duration) Option {
+	return func(r *Reporter) {
+		r.retries = attempts
+		r.retryBackoff = backoff
+	}
+}
+
+// WithRetryBudget bounds the time spent writing a batch with the retries of WithRetry: no retry
+// starts once the elapsed time plus its backoff would exceed budget, the batch then fails
+// as it would after its last attempt. This keeps a flush from blocking the reporter beyond
+// a known duration whatever the number of attempts and their backoff. The time is measured
+// from the first attempt. The default is no budget.
+func WithRetryBudget(budget time.Duration) Option {
+	return func(r *Reporter) {
+		r.retryBudget = budget
+	}
+}
+
+// WithCircuitBreaker stops writing after failures consecutive failed writes, for cooldown, so that
+// a long outage of InfluxDB doesn't cost a failing write at every flush. Meanwhile the flushes return
+// ErrCircuitOpen, which is logged, and their batches are queued as set by WithQueue. Once cooldown
+// elapsed, a single write probes InfluxDB: the writes resume if it succeeds, otherwise they stop for
+// another cooldown, or until the end of a longer Retry-After delay if it is rate limited. With
+// WithSelfMetrics, the gauge "circuit_open" is 1 while writes are stopped.
+func WithCircuitBreaker(failures int, cooldown time.Duration) Option {
+	return func(r *Reporter) {
+		r.breaker = &breaker{threshold: failures, cooldown: cooldown}
+	}
+}
+
+// WithRetryable selects the errors for which WithRetry writes a batch again, DefaultRetryable by default.
+// fn should only accept the transient errors, such as timeouts and 5xx statuses, as retrying a write
+// rejected because of its points, such as a field type conflict, fails the same way. Writes rate
+// limited by the server and partial writes are never retried.
+func WithRetryable(fn func(error) bool) Option {
+	return func(r *Reporter) {
+		r.retryable = fn
+	}
+}
+
+// WithQueue keeps up to depth batches waiting to be written, so that a batch that failed to be
+// written is retried by the next flush, followed by the batches queued after it. A flush adding a
+// batch to a full queue drops a batch according to p. The default depth of 1 keeps only the batch
+// of the last flush. With WithWAL, a failed batch is taken over by the log instead of staying queued.
+func WithQueue(depth int, p QueuePolicy) Option {
+	if p == "" {
+		p = DropOldest
+	}
+	return func(r *Reporter) {
+		r.queue.depth = depth
+		r.queue.policy = p
+	}
+}
+
+// WithOnFlush makes the reporter call fn after every flush, including failed or skipped ones,
+// with statistics about it. fn runs on the goroutine of Run and delays the next flush,
+// so it must return quickly.
+func WithOnFlush(fn func(FlushStats)) Option {
+	return func(r *Reporter) {
+		r.onFlush = fn
+	}
+}
+
+// WithAsync makes flushes hand their batch to a separate goroutine writing the queued batches,
+// instead of writing it themselves, so that a slow write never delays the collection of the next
+// flush. When the writes fall behind, the queue set by WithQueue fills up and drops batches.
+// The write status and the error seen by the WithOnFlush callback then only reflect the writes
+// of that goroutine.
+func WithAsync() Option {
+	return func(r *Reporter) {
+		r.async = true
+	}
+}
+
+// WithSelfMetrics registers metrics about the reporter itself in the registry given to the
+// constructor, so that they get reported with the others. Their names start with
+// "influxdb.reporter.", followed by the name of the reporter if it has one.
+func WithSelfMetrics() Option {
+	return func(r *Reporter) {
+		r.selfMetrics = true
+	}
+}
+
+// WithWriteTimer registers in the registry given to the constructor a timer recording the duration
+// of the write of every batch, retries included and whether it succeeded or not, so that the
+// percentiles of the write latency are reported with the other metrics. The timer is named name,
+// or, if empty, like the metrics of WithSelfMetrics, such as "influxdb.reporter.write_duration".
+// Unlike WithOnFlush, the whole distribution is kept. The duration of a write is reported
+// by the next flush.
+func WithWriteTimer(name string) Option {
+	return func(r *Reporter) {
+		r.writeTimerOn = true
+		r.writeTimerName = name
+	}
+}
+
+// WithRateLimit caps the writes per second and points per second sent to InfluxDB, allowing bursts
+// of one second worth of either, a rate of 0 leaves it unlimited. A flush exceeding the limit is
+// handled according to p instead of being queued. This is a safety valve for shared clusters
+// fed by many reporters or reporters with a tiny interval: the interval is the primary control.
+func WithRateLimit(writesPerSecond, pointsPerSecond float64, p LimitPolicy) Option {
+	if p == "" {
+		p = LimitDrop
+	}
+	return func(r *Reporter) {
+		r.limiter = &rateLimiter{
+			writes: newTokenBucket(writesPerSecond),
+			points: newTokenBucket(pointsPerSecond),
+			policy: p,
+		}
+	}
+}
+
+// WithStartupCheck makes the constructor ping the InfluxDB server and fail with a *ConnectError
+// when it can't be reached, so that the caller can retry or give up at startup.
+// Without it, an unreachable server only surfaces as write errors.
+func WithStartupCheck() Option {
+	return func(r *Reporter) {
+		r.startupCheck = true
+	}
+}
+
+// WithoutPing disables the periodic ping of the InfluxDB server and the recreation of the client
+// when it fails. Problems then only surface as write errors. This suits servers behind a
+// persistent proxy, where a transient ping failure would needlessly drop a working client.
+func WithoutPing() Option {
+	return func(r *Reporter) {
+		r.noPing = true
+	}
+}
+
+// WithWAL keeps the batches that failed to be written in an append-only file at path, so that they
+// survive a restart during an InfluxDB outage. The log is replayed when the reporter starts and after
+// each successful write, then truncated. Batches that would grow the file beyond maxSize bytes are
+// dropped, a maxSize of 0 means no limit.
+func WithWAL(path string, maxSize int64) Option {
+	return func(r *Reporter) {
+		r.wal = newWAL(path, maxSize)
+	}
+}
+
+// WithPrecision sets the precision of the written timestamps: "n", "u", "ms", "s", "m", "h",
+// or PrecisionAuto to derive it from the interval. The default is nanoseconds.
+func WithPrecision(p string) Option {
+	return func(r *Reporter) {
+		r.precision = p
+	}
+}
+
+// WithEnabledTypes reports only the metrics of the given types, for example TypeCounter and TypeGauge
+// to leave out the histograms, meters and timers which cost the most fields. The metrics of the other
+// types are skipped whatever their name. Every type is reported by default, and enabling none is an error.
+func WithEnabledTypes(types ...MetricType) Option {
+	return func(r *Reporter) {
+		r.enabledTypes = make(map[MetricType]bool, len(types))
+		for _, t := range types {
+			r.enabledTypes[t] = true
+		}
+	}
+}
+
+// WithIntervalTag tags every point with the interval of the reporter, such as "interval=10s",
+// under the given key, "interval" if empty, so that queries aggregating points of reporters running
+// at different intervals can pick the right window. The metrics of a type reported at its own
+// interval by WithTypeInterval are tagged with that interval.
+func WithIntervalTag(key string) Option {
+	return func(r *Reporter) {
+		if key == "" {
+			key = "interval"
+		}
+		r.intervalTag = key
+	}
+}
+
+// WithSourceTag tags every point with the name and version of this library, such as
+// "exporter=go-metrics-influxdb/v1.2.0", under the given key, "exporter" if empty, so that its series
+// can be told apart from those of Telegraf or other agents writing to the same database.
+// The version is read from the build info of the binary, it is "unknown" when it isn't recorded.
+func WithSourceTag(key string) Option {
+	return func(r *Reporter) {
+		if key == "" {
+			key = "exporter"
+		}
+		r.sourceTag = key
+		r.sourceValue = sourceTagValue()
+	}
+}
+
+// WithTypeTags adds tags to the points of the metrics of type t only, for example "unit=ns" to timers
+// to document the unit of their durations. Tags are merged in this order, each overriding the previous
+// ones: the tags of the reporter, the tags of the metric type, the tags of WithMetricTags and finally
+// the tag of WithRegistryTag. Calling it again for the same type adds to its tags.
+func WithTypeTags(t MetricType, tags map[string]string) Option {
+	return func(r *Reporter) {
+		if r.typeTags == nil {
+			r.typeTags = make(map[MetricType]map[string]string)
+		}
+		if r.typeTags[t] == nil {
+			r.typeTags[t] = make(map[string]string, len(tags))
+		}
+		for k, v := range tags {
+			r.typeTags[t][k] = v
+		}
+	}
+}
+
+// WithTypeInterval reports the metrics of type t every d instead of at every flush, for example
+// histograms every minute while counters are reported every 10 seconds, to reduce the write volume
+// of expensive metric types. d is rounded to a multiple of the interval of the reporter.
+// The state kept between flushes, such as deltas, then spans d.
+func WithTypeInterval(t MetricType, d time.Duration) Option {
+	return func(r *Reporter) {
+		if r.typeIntervals == nil {
+			r.typeIntervals = make(map[MetricType]time.Duration)
+			r.typeFlushes = make(map[MetricType]time.Time)
+		}
+		r.typeIntervals[t] = d
+	}
+}
+
+// WithTypePrecision sets the precision of the timestamps of the points of metric type t,
+// overriding WithPrecision, for example second precision for slowly changing counters.
+// A flush mixing precisions costs one write per precision.
+func WithTypePrecision(t MetricType, p string) Option {
+	return func(r *Reporter) {
+		if r.typePrecisions == nil {
+			r.typePrecisions = make(map[MetricType]string)
+		}
+		r.typePrecisions[t] = p
+	}
+}
+
+// WithTimestamps takes the timestamp of the points of every flush from fn instead of the current time,
+// for example to backfill metrics at a fixed reference time or to get deterministic points in tests.
+// The timestamps are still shifted by WithTimeOffset and truncated to the precision of the writes.
+// Only the timestamps are affected: the interval, the rates and the other durations measured by
+// the reporter follow the real clock.
+func WithTimestamps(fn func() time.Time) Option {
+	return func(r *Reporter) {
+		r.timestamps = fn
+	}
+}
+
+// WithTimestampTruncation truncates the timestamp of the points to a multiple of d, for example
+// time.Microsecond or time.Millisecond for the tools misbehaving with nanosecond timestamps.
+// Unlike WithPrecision, which changes the unit of the timestamps sent to InfluxDB, the points are
+// still written in the precision of the reporter, with the truncated digits as zeros.
+// The timestamps are truncated after being shifted by WithTimeOffset.
+func WithTimestampTruncation(d time.Duration) Option {
+	return func(r *Reporter) {
+		r.truncate = d
+	}
+}
+
+// WithTimeOffset shifts the timestamp of every point by d, to compensate for a local clock that
+// drifts from the one of the InfluxDB server. This is a band-aid for constrained environments,
+// synchronizing the clock with NTP is the real fix.
+func WithTimeOffset(d time.Duration) Option {
+	return func(r *Reporter) {
+		r.timeOffset = d
+	}
+}
+
+// WithSkipUnchanged omits from every flush the points whose fields didn't change since the previous
+// flush. A series is then only written when it changes, which suits dashboards filling gaps with
+// the previous value.
+func WithSkipUnchanged() Option {
+	return func(r *Reporter) {
+		r.skipUnchanged = true
+	}
+}
+
+// WithSkipUnchangedFlush skips a whole flush when none of its points changed since the previous one.
+// It is coarser than WithSkipUnchanged, writing every point as soon as one changed, and cheaper
+// as it keeps a single fingerprint instead of one per series.
+func WithSkipUnchangedFlush() Option {
+	return func(r *Reporter) {
+		r.skipUnchangedFlush = true
+	}
+}
+
+// WithSkipIdleHistograms omits the whole point of a histogram whose count didn't change since
+// the previous flush, as its percentiles would only repeat stale values. The first flush writes
+// every histogram. With WithResetAfterRead, histograms without samples are omitted.
+func WithSkipIdleHistograms() Option {
+	return func(r *Reporter) {
+		r.skipIdle = true
+		r.idleCounts = make(map[string]int64)
+	}
+}
+
+// WithMeterDelta makes the reporter emit, next to the cumulative count of every meter,
+// the number of events marked since the previous flush as a "delta" field.
+// It is equivalent to WithDeltas(TypeMeter).
+func WithMeterDelta() Option {
+	return WithDeltas(TypeMeter)
+}
+
+// WithDeltas makes the reporter emit, next to the cumulative count of the metrics of the given types,
+// the difference since the previous flush as a "delta" field. Counters, histograms, meters and timers
+// are supported. The first flush reports the whole count, see WithResetPolicy for decreasing counts.
+func WithDeltas(types ...MetricType) Option {
+	return func(r *Reporter) {
+		if r.deltaTypes == nil {
+			r.deltaTypes = make(map[MetricType]bool)
+		}
+		for _, t := range types {
+			r.deltaTypes[t] = true
+		}
+	}
+}
+
+// WithResetPolicy selects how deltas are computed when a count decreases, which means it was reset.
+func WithResetPolicy(p ResetPolicy) Option {
+	return func(r *Reporter) {
+		r.deltas.policy = p
+	}
+}
+
+// WithUnsignedCounters writes the value of counters as an unsigned integer field.
+// Unsigned integers require InfluxDB 1.8 or later, or InfluxDB 2.x. A measurement that already
+// stored a counter as a signed integer rejects the unsigned one, so this is best enabled from the start.
+func WithUnsignedCounters() Option {
+	return func(r *Reporter) {
+		r.unsignedCounters = true
+	}
+}
+
+// WithSampling reports about fraction of the metrics at each flush, such as 0.25 for a quarter,
+// to bound the volume written for enormous registries reported at a short interval. The metrics
+// are spread over 1/fraction rounds, rounded up, by a hash of their name, and the flushes cycle
+// through the rounds, so that every metric is reported once every 1/fraction flushes. Deltas
+// are then those since the previous report of the metric. The metrics of a group of WithGroups may
+// be reported by different flushes. The default reports every metric at every flush.
+func WithSampling(fraction float64) Option {
+	return func(r *Reporter) {
+		r.sampleFraction = fraction
+		if fraction > 0 && fraction <= 1 {
+			r.sampleRounds = sampleRounds(fraction)
+		}
+	}
+}
+
+// WithTotalSeries also writes the cumulative count of counters, meters, histograms and timers in
+// a "value" field of the measurement named after the metric with a "_total" suffix, such as
+// "requests_total", after the Prometheus convention for counters, so that queries written for
+// Prometheus keep working during a migration. The points of the metrics keep their fields,
+// including the count, rates and percentiles. With WithResetAfterRead the counts of counters and
+// histograms are those since the previous flush rather than cumulative ones. The total series
+// isn't merged into the points of WithGroups nor spread by the narrow layout.
+func WithTotalSeries() Option {
+	return func(r *Reporter) {
+		r.totalSeries = true
+	}
+}
+
+// WithLastUpdated adds to the point of every metric a LastUpdatedField field holding the time
+// its value last changed, in seconds since the Unix epoch, so that dashboards can gray out the stale
+// series. go-metrics doesn't record when a metric is updated, so the time is the one of the first
+// flush seeing a new value: the "value" field of counters and gauges, and the "count" field of the
+// other types. It starts at the first flush of the reporter.
+func WithLastUpdated() Option {
+	return func(r *Reporter) {
+		r.lastUpdates = make(map[string]lastUpdate)
+	}
+}
+
+// WithFloatGauges writes the value of integer gauges as a float field, like the one of float gauges,
+// so that a metric migrated from a Gauge to a GaugeFloat64, or back, keeps a single field type:
+// InfluxDB rejects the points of a field whose type differs from the one it first stored.
+// A measurement that already stored an integer gauge rejects the float one, so this is best enabled
+// before the migration starts. The gauges of WithBoolGauges stay booleans.
+func WithFloatGauges() Option {
+	return func(r *Reporter) {
+		r.floatGauges = true
+	}
+}
+
+// WithEmitIf writes the point of a counter or gauge only when fn returns true for its name and
+// current value, for example to write an error count only when it isn't 0. Unlike removing
+// metrics from the registry, the decision is made again at every flush. A counter reset by
+// WithResetAfterRead is only reset when it is written. The default writes every point.
+func WithEmitIf(fn func(name string, value float64) bool) Option {
+	return func(r *Reporter) {
+		r.emitIf = fn
+	}
+}
+
+// WithSums adds to histograms and timers a "sum" field holding the total of their samples,
+// which unlike percentiles can be added up across instances. With go-metrics versions whose
+// histograms don't keep their sum, it is approximated as mean*count, biased by the sampling.
+func WithSums() Option {
+	return func(r *Reporter) {
+		r.sums = true
+	}
+}
+
+// WithCounterRate adds to the points of counters a "rate" field holding the per second rate of their
+// count since the previous flush, next to the cumulative "value", so that a single query shows either.
+// The rate is left out of the first point of a counter and of the point following a decrease of
+// its count, such as a restart or a Clear, since the time of the reset is unknown.
+func WithCounterRate() Option {
+	return func(r *Reporter) {
+		r.counterRates = make(map[string]counterSample)
+	}
+}
+
+// WithBoolGauges writes the value of the gauges whose name matches one of the given patterns
+// as a boolean field, true for any value but 0. The patterns use the syntax of path.Match,
+// so a plain name matches only itself and "*.up" matches every name ending with ".up".
+// A measurement that already stored a gauge as an integer rejects the boolean one.
+func WithBoolGauges(patterns ...string) Option {
+	return func(r *Reporter) {
+		r.boolGauges = append(r.boolGauges, patterns...)
+	}
+}
+
+// WithPercentiles selects the distinct quantiles written for histograms and timers, each within [0, 1],
+// such as 0.95 rather than 95; New returns an error otherwise.
+// Their fields are named "p" followed by the digits of the percentage, such as "p99" for 0.99
+// and "p999" for 0.999, unless named by WithPercentileNames. The default is 0.5, 0.75, 0.95,
+// 0.99, 0.999 and 0.9999. WithPercentiles() without quantiles writes none, which avoids sorting
+// the sample of every histogram and timer on each flush.
+func WithPercentiles(qs ...float64) Option {
+	return func(r *Reporter) {
+		r.percentiles = qs
+	}
+}
+
+// WithQuantileSeries writes the quantiles selected by WithPercentiles as separate series rather than
+// as fields of the point of each histogram and timer, like Prometheus summaries: one point per quantile
+// in the measurement of the metric, tagged with QuantileTag such as "quantile=0.99", or the name
+// of the quantile given to WithPercentileNames, and holding a single "value" field. This suits
+// panels drawing every quantile as its own series, but multiplies the number of series of each
+// histogram and timer by the number of quantiles plus one, which InfluxDB has to index.
+// It isn't supported by the narrow layout.
+func WithQuantileSeries() Option {
+	return func(r *Reporter) {
+		r.quantileSeries = true
+	}
+}
+
+// WithMinSamples leaves the standard deviation, the variance and the quantiles out of the points
+// of the histograms and timers holding fewer than n samples, for which they are zero or meaningless,
+// to reduce the noise of rarely updated distributions. The count, min, max and mean are still written.
+// Every field is written by default.
+func WithMinSamples(n int) Option {
+	return func(r *Reporter) {
+		r.minSamples = n
+	}
+}
+
+// WithMaxPercentiles sets the maximum number of quantiles accepted by WithPercentiles, 20 by default.
+// Every quantile adds a field to each point of the histograms and timers, so a long list is most
+// likely a mistake. A max of 0 or less removes the limit.
+func WithMaxPercentiles(max int) Option {
+	return func(r *Reporter) {
+		r.maxPercentiles = max
+	}
+}
+
+// WithPercentileCache reuses the quantiles of the histograms and timers whose count didn't change
+// since the previous flush, instead of sorting their sample again. This saves CPU for registries
+// of many rarely updated histograms. Quantiles are computed in a single pass per metric either way.
+// A histogram cleared and updated back to the same count between two flushes reports its previous
+// quantiles, so this can't be combined with WithResetAfterRead. Only the quantiles of the metrics
+// of the last flush are kept.
+func WithPercentileCache() Option {
+	return func(r *Reporter) {
+		r.percentileCache = make(map[string]cachedPercentiles)
+		r.percentileNext = make(map[string]cachedPercentiles)
+	}
+}
+
+// WithPercentileNames names the fields of some of the reported quantiles, for example
+// map[float64]string{0.999: "three_nines"}. The other quantiles keep their default name.
+// Naming a quantile that isn't reported is an error. The map is copied, so that later changes
+// made by the caller don't affect the reporter.
+func WithPercentileNames(names map[float64]string) Option {
+	var copied map[float64]string
+	if names != nil {
+		copied = make(map[float64]string, len(names))
+		for q, name := range names {
+			copied[q] = name
+		}
+	}
+	return func(r *Reporter) {
+		r.percentileNames = copied
+	}
+}
+
+// WithMeterRates selects the rates written for meters and their field names.
+// The default writes every window: m1, m5, m15 and mean. The field names must differ from each
+// other and from the other fields of meters, such as count.
+func WithMeterRates(names map[RateWindow]string) Option {
+	return func(r *Reporter) {
+		r.meterRates = names
+	}
+}
+
+// WithTimerRates selects the rates written for timers and their field names.
+// The default writes every window: m1, m5, m15 and meanrate. The field names must differ from each
+// other and from the other fields of timers, such as mean or the percentiles.
+func WithTimerRates(names map[RateWindow]string) Option {
+	return func(r *Reporter) {
+		r.timerRates = names
+	}
+}
+
+// WithConsistentMeanRate names the mean rate field of meters "meanrate", as for timers.
+// Historically meters write their mean rate as "mean" while timers write it as "meanrate", since
+// the "mean" field of a timer is its mean duration, so that dashboards can't be moved from meters
+// to timers as is. The meter field is kept as "mean" by default for compatibility with existing
+// series. A name set by WithMeterRates other than "mean" is left as is.
+func WithConsistentMeanRate() Option {
+	return func(r *Reporter) {
+		r.consistentMeanRate = true
+	}
+}
+
+// WithPartialWritePolicy selects how a write is handled when InfluxDB accepts a batch but drops some
+// of its points, for example because of a field type conflict. See PartialWritePolicy.
+func WithPartialWritePolicy(p PartialWritePolicy) Option {
+	return func(r *Reporter) {
+		r.partialWrites = p
+	}
+}
+
+// WithDuplicatePolicy selects how to handle points of a batch sharing their measurement and tags,
+// which InfluxDB would silently overwrite. The real fix is to give such series distinct tags,
+// the policy is a safety net to detect them or keep them apart.
+func WithDuplicatePolicy(p DuplicatePolicy) Option {
+	return func(r *Reporter) {
+		r.duplicates = p
+	}
+}
+
+// WithResetAfterRead clears counters and histograms right after they are read, so that every flush
+// reports only what happened during the last interval. This changes the metrics of the registry
+// for every other reader, so a reporter with this option refuses to share its registry with another
+// reporter. Updates made between the read and the clear are lost.
+func WithResetAfterRead() Option {
+	return func(r *Reporter) {
+		r.resetAfterRead = true
+	}
+}
+
+// WithFieldName renames a field of the points of the given metric type, for example
+// WithFieldName(TypeHistogram, "count", "samples"). It applies after every other option,
+// so field names set by WithMeterRates or WithTimerRates can be renamed too. The renames of a type
+// apply at once, so two fields can be swapped, and two fields can't be renamed to the same name.
+// A field renamed to the name of a field that isn't renamed replaces it.
+func WithFieldName(t MetricType, field, name string) Option {
+	return func(r *Reporter) {
+		if r.fieldNames == nil {
+			r.fieldNames = make(map[MetricType]map[string]string)
+		}
+		if r.fieldNames[t] == nil {
+			r.fieldNames[t] = make(map[string]string)
+		}
+		r.fieldNames[t][field] = name
+	}
+}
+
+// WithRateDecimals rounds the m1, m5, m15 and mean rate fields of meters and timers to n decimals.
+func WithRateDecimals(n int) Option {
+	return func(r *Reporter) {
+		r.format.rateDecimals = n
+	}
+}
+
+// WithRateUnit expresses the rate fields, the m1, m5, m15 and mean rates of meters and timers and
+// the rate of WithCounterRate, in events per unit rather than per second, for example per minute
+// with WithRateUnit(time.Minute) to match dashboards built for per-minute rates. The counts and
+// durations are unchanged. The rates are scaled before being rounded by WithRateDecimals.
+func WithRateUnit(unit time.Duration) Option {
+	return func(r *Reporter) {
+		r.format.rateUnit = unit
+	}
+}
+
+// WithDurationUnit scales the duration fields of timers to the given unit, rounded to n decimals,
+// for example WithDurationUnit(time.Millisecond, 3). A negative n disables rounding.
+// Note that scaling turns the integer min and max fields into floats, which InfluxDB rejects
+// for measurements that already stored them as integers.
+func WithDurationUnit(unit time.Duration, n int) Option {
+	return func(r *Reporter) {
+		r.format.durationUnit = unit
+		r.format.durationDecimals = n
+	}
+}
+
+// WithHTTP2 makes the reporter write through an HTTP transport negotiating HTTP/2 with https servers
+// supporting it, such as an HTTP/2 capable proxy in front of InfluxDB, instead of HTTP/1.1. It uses
+// the proxy of the environment. Cleartext http urls keep using HTTP/1.1. The InfluxDB client,
+// used for pings, only speaks HTTP/1.1.
+func WithHTTP2() Option {
+	return func(r *Reporter) {
+		r.http2 = true
+	}
+}
+
+// WithCredentials makes the reporter get its username and password from c instead of the
+// constructor arguments, so that they can be rotated and aren't kept by the reporter.
+// The InfluxDB client used for pings still holds the credentials it was created with.
+func WithCredentials(c Credentials) Option {
+	return func(r *Reporter) {
+		r.credentials = c
+	}
+}
+
+// WithFallback writes to w the batches that failed to be written to InfluxDB, or that weren't
+// written because the circuit breaker of WithCircuitBreaker is open, as a last resort for the points
+// that can't be lost: a file with NewStreamWriter, a secondary database with NewHTTPWriter and so on.
+// A batch written to the fallback is handled as written and isn't queued nor logged to the
+// write-ahead log, which is only used when the fallback fails too.
+func WithFallback(w Writer) Option {
+	return func(r *Reporter) {
+		r.fallback = w
+	}
+}
+
+// WithWriter makes the reporter deliver its points to w instead of the InfluxDB HTTP API,
+// for example a Writer returned by NewSocketWriter. The url, username and password are then unused.
+func WithWriter(w Writer) Option {
+	return func(r *Reporter) {
+		r.writer = w
+	}
+}
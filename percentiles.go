@@ -0,0 +1,171 @@
+package influxdb
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/influxdata/influxdb/client"
+)
+
+// defaultPercentiles are the quantiles reported for histograms and timers, computed in a single
+// Percentiles call per metric so that the sample is sorted only once.
+var defaultPercentiles = []float64{0.5, 0.75, 0.95, 0.99, 0.999, 0.9999}
+
+// percentileName returns the default field name of quantile q: "p" followed by the digits
+// of its percentage, such as "p99" for 0.99 and "p999" for 0.999.
+func percentileName(q float64) string {
+	if q >= 1 {
+		return "p100"
+	}
+	// formatting q*100 would suffer from rounding errors, such as 56.99999999999999 for 0.57
+	frac := strings.TrimPrefix(strconv.FormatFloat(q, 'f', -1, 64), "0")
+	frac = strings.TrimPrefix(frac, ".")
+	for len(frac) < 2 {
+		frac += "0"
+	}
+
+	whole := strings.TrimLeft(frac[:2], "0")
+	if whole == "" {
+		whole = "0"
+	}
+	return "p" + whole + frac[2:]
+}
+
+// percentileFields returns the field names of the quantiles qs, taken from names or defaulted.
+func percentileFields(qs []float64, names map[float64]string) []string {
+	fields := make([]string, len(qs))
+	for i, q := range qs {
+		if name, ok := names[q]; ok && name != "" {
+			fields[i] = name
+		} else {
+			fields[i] = percentileName(q)
+		}
+	}
+	return fields
+}
+
+// defaultMaxPercentiles is the default number of quantiles a reporter accepts, see WithMaxPercentiles.
+const defaultMaxPercentiles = 20
+
+// validatePercentiles checks that there are at most max distinct quantiles, all within [0, 1],
+// that every named quantile is reported and that no two quantiles share a field name.
+func validatePercentiles(qs []float64, names map[float64]string, max int) error {
+	if max > 0 && len(qs) > max {
+		return fmt.Errorf("%d percentiles are configured, more than the maximum of %d", len(qs), max)
+	}
+
+	reported := make(map[float64]bool, len(qs))
+	for _, q := range qs {
+		if math.IsNaN(q) || q < 0 || q > 1 {
+			return fmt.Errorf("percentile %v is not within [0, 1]", q)
+		}
+		if reported[q] {
+			return fmt.Errorf("percentile %v is configured more than once", q)
+		}
+		reported[q] = true
+	}
+
+	named := make([]float64, 0, len(names))
+	for q := range names {
+		named = append(named, q)
+	}
+	sort.Float64s(named)
+	for _, q := range named {
+		if !reported[q] {
+			return fmt.Errorf("percentile %v is named %q but isn't reported", q, names[q])
+		}
+	}
+
+	seen := make(map[string]float64, len(qs))
+	for i, field := range percentileFields(qs, names) {
+		if other, ok := seen[field]; ok {
+			return fmt.Errorf("percentiles %v and %v share the field name %q", other, qs[i], field)
+		}
+		seen[field] = qs[i]
+	}
+
+	return nil
+}
+
+// QuantileTag is the tag holding the quantile of the points written by WithQuantileSeries.
+const QuantileTag = "quantile"
+
+// quantilePoints appends to pts one point per quantile of a histogram or timer, in the measurement
+// of the metric, with the quantile in the QuantileTag tag and its value in a single "value" field.
+// The tag holds the name given to the quantile by WithPercentileNames, or the quantile itself.
+func (r *Reporter) quantilePoints(pts []client.Point, name string, t MetricType, values []interface{}, tags map[string]string, now time.Time) []client.Point {
+	for i, v := range values {
+		quantileTags := make(map[string]string, len(tags)+1)
+		for k, v := range tags {
+			quantileTags[k] = v
+		}
+		quantile, ok := r.percentileNames[r.percentiles[i]]
+		if !ok || quantile == "" {
+			quantile = strconv.FormatFloat(r.percentiles[i], 'f', -1, 64)
+		}
+		quantileTags[QuantileTag] = quantile
+
+		pts = append(pts, client.Point{
+			Measurement: r.measurement(name, t),
+			Tags:        quantileTags,
+			Fields:      map[string]interface{}{"value": v},
+			Time:        now,
+			Precision:   r.precisionFor(t),
+		})
+	}
+	return pts
+}
+
+// fewSamples reports whether a histogram or timer with count samples has too few of them for its
+// spread to be meaningful, see WithMinSamples.
+func (r *Reporter) fewSamples(count int64) bool {
+	return count < int64(r.minSamples)
+}
+
+// percentiler is implemented by the snapshots of histograms and timers.
+type percentiler interface {
+	Count() int64
+	Percentiles(qs []float64) []float64
+}
+
+// cachedPercentiles are the quantiles computed for a sample of count values.
+type cachedPercentiles struct {
+	count  int64
+	values []float64
+}
+
+// percentilesOf returns the quantiles of the sample of the metric identified by key.
+// With the percentile cache, the quantiles of a metric whose count didn't change since the
+// previous flush are reused instead of sorting its sample again, and kept for the next flush,
+// see rotatePercentileCache. Without quantiles to report, the sample isn't sorted at all.
+func (r *Reporter) percentilesOf(key string, ms percentiler) []float64 {
+	if len(r.percentiles) == 0 || r.fewSamples(ms.Count()) {
+		return nil
+	}
+	if r.percentileCache == nil {
+		return ms.Percentiles(r.percentiles)
+	}
+
+	count := ms.Count()
+	c, ok := r.percentileCache[key]
+	if !ok || c.count != count {
+		c = cachedPercentiles{count: count, values: ms.Percentiles(r.percentiles)}
+	}
+	if !r.peeking {
+		r.percentileNext[key] = c
+	}
+	return c.values
+}
+
+// rotatePercentileCache replaces the percentile cache with the quantiles of the metrics of the flush
+// which just built its points, so that the metrics no longer reported don't stay cached forever.
+func (r *Reporter) rotatePercentileCache() {
+	if r.percentileCache == nil || r.peeking {
+		return
+	}
+	r.percentileCache, r.percentileNext = r.percentileNext, make(map[string]cachedPercentiles, len(r.percentileNext))
+}
@@ -0,0 +1,250 @@
+package influxdb
+
+import (
+	"math"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+// newHistograms returns a registry of n histograms holding 1000 samples each.
+func newHistograms(n int) metrics.Registry {
+	reg := metrics.NewRegistry()
+	for i := 0; i < n; i++ {
+		h := metrics.GetOrRegisterHistogram("latency."+strconv.Itoa(i), reg, metrics.NewUniformSample(1028))
+		for j := 0; j < 1000; j++ {
+			h.Update(int64(j * i))
+		}
+	}
+	return reg
+}
+
+func TestPercentileCache(t *testing.T) {
+	reg := newHistograms(2)
+	r := newTestReporter(t, reg, &testWriter{}, WithPercentileCache())
+	flush(t, r)
+	if n := len(r.percentileCache); n != 2 {
+		t.Fatalf("got %d cached metrics, want 2", n)
+	}
+	cached := r.percentileCache["histogram:latency.1"].values
+
+	// the cached quantiles are reused while the count doesn't change
+	flush(t, r)
+	if got := r.percentileCache["histogram:latency.1"].values; &got[0] != &cached[0] {
+		t.Error("quantiles of an unchanged histogram computed again")
+	}
+	reg.Get("latency.1").(metrics.Histogram).Update(1)
+	flush(t, r)
+	if got := r.percentileCache["histogram:latency.1"].values; &got[0] == &cached[0] {
+		t.Error("quantiles of an updated histogram reused")
+	}
+
+	reg.Unregister("latency.0")
+	flush(t, r)
+	if _, ok := r.percentileCache["histogram:latency.0"]; ok || len(r.percentileCache) != 1 {
+		t.Errorf("got cached metrics %v, want the unregistered histogram pruned", r.percentileCache)
+	}
+}
+
+func TestPercentileNames(t *testing.T) {
+	pts, err := BuildPoints(newHistograms(2), nil, time.Now(), WithPercentiles(0.5, 0.99), WithPercentileNames(map[float64]string{0.99: "p99_latency"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range pts {
+		if _, ok := p.Fields["p99_latency"]; !ok {
+			t.Errorf("no field p99_latency in %v", p.Fields)
+		}
+		if _, ok := p.Fields["p50"]; !ok {
+			t.Errorf("no field p50 in %v", p.Fields)
+		}
+	}
+
+	if _, err := BuildPoints(metrics.NewRegistry(), nil, time.Now(), WithPercentiles(0.5), WithPercentileNames(map[float64]string{0.99: "p99"})); err == nil {
+		t.Error("got no error naming a quantile that isn't reported")
+	}
+	// the names are copied by the option
+	names := map[float64]string{0.99: "p99_latency"}
+	opt := WithPercentileNames(names)
+	names[0.99] = "changed"
+	pts, err = BuildPoints(newHistograms(1), nil, time.Now(), WithPercentiles(0.99), opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := pts[0].Fields["p99_latency"]; !ok {
+		t.Errorf("got fields %v, want the name given to the option", pts[0].Fields)
+	}
+}
+
+func TestQuantileSeries(t *testing.T) {
+	reg := newHistograms(1)
+	pts, err := BuildPoints(reg, nil, time.Now(), WithPercentiles(0.5, 0.99), WithPercentileNames(map[float64]string{0.99: "p99"}), WithQuantileSeries())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	quantiles := make(map[string]bool)
+	for _, p := range pts {
+		q, ok := p.Tags[QuantileTag]
+		if !ok {
+			if _, ok := p.Fields["p50"]; ok {
+				t.Errorf("got quantile fields %v in the point of the histogram", p.Fields)
+			}
+			continue
+		}
+		if len(p.Fields) != 1 || p.Fields["value"] == nil {
+			t.Errorf("got fields %v of quantile %s, want a single value", p.Fields, q)
+		}
+		quantiles[q] = true
+	}
+	if len(quantiles) != 2 || !quantiles["0.5"] || !quantiles["p99"] {
+		t.Errorf("got quantiles %v, want 0.5 and p99", quantiles)
+	}
+}
+
+// countingHistogram counts the Percentiles calls of its snapshots.
+type countingHistogram struct {
+	metrics.Histogram
+	calls *int
+}
+
+func (h countingHistogram) Snapshot() metrics.Histogram {
+	return countingHistogram{h.Histogram.Snapshot(), h.calls}
+}
+
+func (h countingHistogram) Percentiles(qs []float64) []float64 {
+	*h.calls++
+	return h.Histogram.Percentiles(qs)
+}
+
+func TestNoPercentiles(t *testing.T) {
+	var calls int
+	reg := metrics.NewRegistry()
+	h := countingHistogram{metrics.NewHistogram(metrics.NewUniformSample(100)), &calls}
+	h.Update(1)
+	if err := reg.Register("latency", h); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		opts  []Option
+		calls int
+	}{
+		{nil, 1},
+		{[]Option{WithPercentiles()}, 0},
+		{[]Option{WithPercentiles(), WithPercentileCache()}, 0},
+		{[]Option{WithMinSamples(2)}, 0},
+	} {
+		calls = 0
+		pts, err := BuildPoints(reg, nil, time.Now(), tt.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if calls != tt.calls {
+			t.Errorf("got %d Percentiles calls, want %d", calls, tt.calls)
+		}
+		if len(pts) != 1 || pts[0].Fields["count"] != int64(1) {
+			t.Errorf("got points %v, want the count of the histogram", pts)
+		}
+	}
+}
+
+func TestValidatePercentiles(t *testing.T) {
+	many := make([]float64, 21)
+	for i := range many {
+		many[i] = float64(i) / 100
+	}
+	tests := []struct {
+		name string
+		opts []Option
+		ok   bool
+	}{
+		{"default", nil, true},
+		{"none", []Option{WithPercentiles()}, true},
+		{"bounds", []Option{WithPercentiles(0, 1)}, true},
+		{"percentage", []Option{WithPercentiles(0.5, 95)}, false},
+		{"negative", []Option{WithPercentiles(-0.5)}, false},
+		{"NaN", []Option{WithPercentiles(math.NaN())}, false},
+		{"duplicate", []Option{WithPercentiles(0.5, 0.99, 0.5)}, false},
+		{"same field name", []Option{WithPercentiles(0.5, 0.99), WithPercentileNames(map[float64]string{0.5: "p99"})}, false},
+		{"too many", []Option{WithPercentiles(many...)}, false},
+		{"raised maximum", []Option{WithPercentiles(many...), WithMaxPercentiles(21)}, true},
+		{"no maximum", []Option{WithPercentiles(many...), WithMaxPercentiles(0)}, true},
+		{"lowered maximum", []Option{WithPercentiles(0.5, 0.99), WithMaxPercentiles(1)}, false},
+	}
+	for _, tt := range tests {
+		_, err := BuildPoints(metrics.NewRegistry(), nil, time.Now(), tt.opts...)
+		if ok := err == nil; ok != tt.ok {
+			t.Errorf("%s: got error %v, want success %v", tt.name, err, tt.ok)
+		}
+	}
+}
+
+func benchmarkPercentiles(b *testing.B, opts ...Option) {
+	reg := newHistograms(1000)
+	r := newTestReporter(b, reg, &testWriter{}, opts...)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.buildPoints(time.Now())
+	}
+}
+
+// The histograms don't change between the flushes of the benchmarks: with the cache, their samples
+// are sorted once, and without quantiles they aren't sorted at all.
+
+func BenchmarkPercentiles(b *testing.B) {
+	benchmarkPercentiles(b)
+}
+
+func BenchmarkPercentilesCached(b *testing.B) {
+	benchmarkPercentiles(b, WithPercentileCache())
+}
+
+func BenchmarkPercentilesNone(b *testing.B) {
+	benchmarkPercentiles(b, WithPercentiles())
+}
+
+func TestMinSamples(t *testing.T) {
+	reg := metrics.NewRegistry()
+	metrics.GetOrRegisterHistogram("single", reg, metrics.NewUniformSample(100)).Update(5)
+	metrics.GetOrRegisterTimer("rare", reg).Update(time.Millisecond)
+	busy := metrics.GetOrRegisterHistogram("busy", reg, metrics.NewUniformSample(100))
+	busy.Update(1)
+	busy.Update(2)
+
+	pts, err := BuildPoints(reg, nil, time.Now(), WithMinSamples(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pts) != 3 {
+		t.Fatalf("got %d points, want 3", len(pts))
+	}
+	for _, p := range pts {
+		few := p.Measurement != "busy.histogram"
+		for _, k := range []string{"count", "min", "max", "mean"} {
+			if _, ok := p.Fields[k]; !ok {
+				t.Errorf("no field %s in %s", k, p.Measurement)
+			}
+		}
+		for _, k := range []string{"stddev", "variance", "p50", "p99"} {
+			if _, ok := p.Fields[k]; ok == few {
+				t.Errorf("got field %s in %s: %v, want it only with enough samples", k, p.Measurement, ok)
+			}
+		}
+	}
+
+	// every field is written by default
+	pts, err = BuildPoints(reg, nil, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range pts {
+		if _, ok := p.Fields["stddev"]; !ok {
+			t.Errorf("no field stddev in %s by default", p.Measurement)
+		}
+	}
+}
@@ -0,0 +1,490 @@
+package influxdb
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/influxdata/influxdb/client"
+	"github.com/rcrowley/go-metrics"
+)
+
+// BuildPoints returns the points a reporter configured with opts would write for the metrics of reg
+// at time now, without sending them, so that they can be fed to another write pipeline.
+// State kept by a reporter between flushes, such as the previous counts used for deltas,
+// starts afresh on every call. Like New, it registers in reg the metrics added by the options,
+// the gauge of WithBuildInfo and the timer of WithWriteTimer, which are then part of the points
+// of every later call and of the reporters of reg.
+func BuildPoints(reg metrics.Registry, tags map[string]string, now time.Time, opts ...Option) ([]client.Point, error) {
+	if reg == nil {
+		return nil, errNilRegistry
+	}
+	r := newReporter(reg, 0, append([]Option{WithTags(tags)}, opts...))
+	if err := r.validate(); err != nil {
+		return nil, err
+	}
+	r.registerMetrics()
+
+	return r.buildPoints(now), nil
+}
+
+// Snapshot returns the points the next flush would write for the current metrics, with every naming,
+// tag and filtering option of the reporter applied, without writing them, for example to debug the
+// configuration or to expose the metrics through another endpoint. Unlike a flush, it doesn't change
+// the state kept between flushes: the deltas and rates are those since the previous flush and the
+// metrics aren't cleared by WithResetAfterRead. The points skipped by a flush because they didn't
+// change or exceed the rate limit are included. It is safe to call while the reporter runs.
+func (r *Reporter) Snapshot() []*client.Point {
+	var pts []client.Point
+	r.do(context.Background(), func() {
+		r.peeking = true
+		defer func() { r.peeking = false }()
+
+		now := r.flushTime()
+		pts = append(r.buildPoints(now), r.reporterPoints(now)...)
+	})
+
+	res := make([]*client.Point, len(pts))
+	for i := range pts {
+		res[i] = &pts[i]
+	}
+	return res
+}
+
+// buildPoints returns the points for the metrics of the registries at time now.
+func (r *Reporter) buildPoints(now time.Time) []client.Point {
+	var pts []client.Point
+
+	globalTags := r.flushTags()
+	// the intervals follow the clock of the reporter, the timestamps may be pinned by WithTimestamps
+	skipped := r.dueTypes(r.now())
+	seen := make(map[string]string)
+	var groups pointGroups
+	round := r.sampleRound()
+	// the route of every point, see WithRoute
+	var routes []int
+	for _, nr := range r.registries() {
+		// metrics of the additional registries are told apart from the default one by their id
+		var prefix string
+		if nr.name != defaultRegistryName {
+			prefix = nr.name + "/"
+		}
+
+		tags := globalTags
+		if r.registryTag != "" {
+			tags = make(map[string]string, len(globalTags)+1)
+			for k, v := range globalTags {
+				tags[k] = v
+			}
+			tags[r.registryTag] = nr.name
+		}
+
+		// the tags of a type are merged once per registry rather than for every metric
+		typed := make(map[MetricType]map[string]string)
+		r.each(nr.reg, func(name string, i interface{}) {
+			t := typeOf(i)
+			if skipped != nil && skipped[t] || r.enabledTypes != nil && !r.enabledTypes[t] {
+				return
+			}
+			if !r.sampled(prefix+name, round) {
+				return
+			}
+			if r.normalizeNames && normalizeName(name) == "" {
+				r.warnf("name %q of a metric of registry %s is empty once normalized, skipping it", name, nr.name)
+				return
+			}
+			if r.registryTag == "" {
+				if other, ok := seen[name]; ok {
+					r.warnf("metric %s of registry %s is already reported from registry %s, skipping it. Use WithRegistryTag to report both", name, nr.name, other)
+					return
+				}
+				seen[name] = nr.name
+			}
+			tt, ok := typed[t]
+			if !ok {
+				tt = r.typeTagsFor(t, tags)
+				typed[t] = tt
+			}
+			n := len(pts)
+			pts = r.appendPoints(pts, prefix+name, name, i, r.tagsFor(name, tt), now)
+			if group, leaf, ok := r.grouped(name, t); ok && len(pts) > n {
+				// the point of the metric is the first one, followed by its total series
+				r.addToGroup(&groups, group, leaf, pts[n])
+				pts = append(pts[:n], pts[n+1:]...)
+			}
+			if len(r.routes) > 0 {
+				route := r.routeOf(name)
+				for len(routes) < len(pts) {
+					routes = append(routes, route)
+				}
+			}
+		})
+	}
+	for i, gp := range groups.points() {
+		// a group is routed by its name
+		pts = append(pts, gp)
+		if len(r.routes) > 0 {
+			routes = append(routes, r.routeOf(groups.names[i]))
+		}
+	}
+
+	r.filterTags(pts)
+	r.recordRoutes(pts, routes)
+	r.rotatePercentileCache()
+
+	return r.checkDuplicates(pts)
+}
+
+// each calls fn for every metric of reg.
+func (r *Reporter) each(reg metrics.Registry, fn func(name string, i interface{})) {
+	if !r.snapshotNames {
+		reg.Each(fn)
+		return
+	}
+
+	var names []string
+	reg.Each(func(name string, _ interface{}) {
+		names = append(names, name)
+	})
+	sort.Strings(names)
+
+	for _, name := range names {
+		i := reg.Get(name)
+		if i == nil {
+			// unregistered since the names were collected
+			continue
+		}
+		fn(name, i)
+	}
+}
+
+// addDelta adds to fields the count difference since the previous flush, if enabled for the metric type.
+func (r *Reporter) addDelta(fields map[string]interface{}, t MetricType, name string, count int64) {
+	if !r.deltaTypes[t] {
+		return
+	}
+	delta := r.deltas.delta
+	if r.peeking {
+		delta = r.deltas.peek
+	}
+	if d, ok := delta(string(t)+":"+name, count); ok {
+		fields["delta"] = d
+	}
+}
+
+// idleHistogram reports whether the point of a histogram with the given count is skipped
+// because no sample was added since the previous flush.
+func (r *Reporter) idleHistogram(id string, count int64) bool {
+	if !r.skipIdle {
+		return false
+	}
+	if r.resetAfterRead {
+		return count == 0
+	}
+
+	prev, ok := r.idleCounts[id]
+	if !r.peeking {
+		r.idleCounts[id] = count
+	}
+	return ok && count == prev
+}
+
+// summer is implemented by the histograms and timers of the go-metrics versions keeping their sum.
+type summer interface {
+	Sum() int64
+}
+
+// sum returns the sum of the samples of a histogram or timer, or an approximation computed
+// from the mean of the sample, which may be biased as the sample may not hold every value.
+func sum(i interface {
+	Count() int64
+}, mean float64) interface{} {
+	if s, ok := i.(summer); ok {
+		return s.Sum()
+	}
+	return mean * float64(i.Count())
+}
+
+// emits reports whether the point of the counter or gauge registered under name with the given value
+// is written by this flush, see WithEmitIf.
+func (r *Reporter) emits(name string, value float64) bool {
+	return r.emitIf == nil || r.emitIf(name, value)
+}
+
+// unsigned converts the value of a counter to an unsigned field.
+// Counters are expected to only be incremented, a negative value is logged and reported as 0.
+func (r *Reporter) unsigned(name string, v int64) uint64 {
+	if v < 0 {
+		r.warnf("counter %s has a negative value %d, reporting 0 as unsigned integer", name, v)
+		return 0
+	}
+	return uint64(v)
+}
+
+// boolGauge reports whether the gauge registered under name is written as a boolean field.
+func (r *Reporter) boolGauge(name string) bool {
+	for _, pattern := range r.boolGauges {
+		// the patterns are checked by validate
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// gaugeValue returns the field value of the integer gauge registered under name with value v:
+// a boolean for gauges of WithBoolGauges, a float with WithFloatGauges, or v itself.
+func (r *Reporter) gaugeValue(name string, v int64) interface{} {
+	if r.boolGauge(name) {
+		return v != 0
+	}
+	if r.floatGauges {
+		return float64(v)
+	}
+	return v
+}
+
+// appendPoints appends to pts the points for the metric i registered under name.
+// id identifies the metric across registries.
+func (r *Reporter) appendPoints(pts []client.Point, id, name string, i interface{}, tags map[string]string, now time.Time) []client.Point {
+	var (
+		t      MetricType
+		fields map[string]interface{}
+		// the quantiles written as separate series, see WithQuantileSeries
+		quantiles []interface{}
+	)
+
+	switch metric := i.(type) {
+	case metrics.Counter:
+		t = TypeCounter
+		ms := metric.Snapshot()
+		if !r.emits(name, float64(ms.Count())) {
+			return pts
+		}
+		if r.resetAfterRead && !r.peeking {
+			metric.Clear()
+		}
+		var value interface{} = ms.Count()
+		if r.unsignedCounters {
+			value = r.unsigned(name, ms.Count())
+		}
+		fields = map[string]interface{}{
+			"value": value,
+		}
+		r.addDelta(fields, t, id, ms.Count())
+		r.addCounterRate(fields, id, ms.Count(), r.resetAfterRead, r.now())
+	case metrics.Gauge:
+		t = TypeGauge
+		ms := metric.Snapshot()
+		if !r.emits(name, float64(ms.Value())) {
+			return pts
+		}
+		fields = map[string]interface{}{
+			"value": r.gaugeValue(name, ms.Value()),
+		}
+	case metrics.GaugeFloat64:
+		t = TypeGaugeFloat64
+		ms := metric.Snapshot()
+		if !r.emits(name, ms.Value()) {
+			return pts
+		}
+		fields = map[string]interface{}{
+			"value": ms.Value(),
+		}
+	case metrics.Histogram:
+		t = TypeHistogram
+		ms := metric.Snapshot()
+		if r.idleHistogram(id, ms.Count()) {
+			return pts
+		}
+		if r.resetAfterRead && !r.peeking {
+			metric.Clear()
+		}
+		fields = map[string]interface{}{
+			"count":    ms.Count(),
+			"max":      ms.Max(),
+			"mean":     ms.Mean(),
+			"min":      ms.Min(),
+			"stddev":   ms.StdDev(),
+			"variance": ms.Variance(),
+		}
+		for i, v := range r.percentilesOf(string(t)+":"+id, ms) {
+			if r.quantileSeries {
+				quantiles = append(quantiles, v)
+			} else {
+				fields[r.percentileFields[i]] = v
+			}
+		}
+		if r.sums {
+			fields["sum"] = sum(ms, ms.Mean())
+		}
+		addBuckets(fields, metric, 0)
+		if r.fewSamples(ms.Count()) {
+			delete(fields, "stddev")
+			delete(fields, "variance")
+		}
+		r.addDelta(fields, t, id, ms.Count())
+	case metrics.Meter:
+		t = TypeMeter
+		ms := metric.Snapshot()
+		fields = map[string]interface{}{
+			"count": ms.Count(),
+		}
+		r.addRates(fields, ms, r.meterRates)
+		r.addDelta(fields, t, id, ms.Count())
+	case metrics.Timer:
+		t = TypeTimer
+		ms := metric.Snapshot()
+		fields = map[string]interface{}{
+			"count":    ms.Count(),
+			"max":      r.format.durationInt(ms.Max()),
+			"mean":     r.format.duration(ms.Mean()),
+			"min":      r.format.durationInt(ms.Min()),
+			"stddev":   r.format.duration(ms.StdDev()),
+			"variance": r.format.variance(ms.Variance()),
+		}
+		for i, v := range r.percentilesOf(string(t)+":"+id, ms) {
+			if r.quantileSeries {
+				quantiles = append(quantiles, r.format.duration(v))
+			} else {
+				fields[r.percentileFields[i]] = r.format.duration(v)
+			}
+		}
+		if r.sums {
+			switch v := sum(ms, ms.Mean()).(type) {
+			case int64:
+				fields["sum"] = r.format.durationInt(v)
+			case float64:
+				fields["sum"] = r.format.duration(v)
+			}
+		}
+		addBuckets(fields, metric, r.format.durationUnit)
+		if r.fewSamples(ms.Count()) {
+			delete(fields, "stddev")
+			delete(fields, "variance")
+		}
+		r.addRates(fields, ms, r.timerRates)
+		r.addDelta(fields, t, id, ms.Count())
+	default:
+		if t, fields = r.adaptedFields(id, name, i); fields == nil {
+			return pts
+		}
+	}
+
+	r.addLastUpdated(fields, t, string(t)+":"+id, now)
+	// the count is read before the fields are renamed
+	total, hasTotal := r.totalPoint(name, t, fields, tags, now)
+	r.renameFields(t, fields)
+	if r.layout == LayoutNarrow {
+		pts = r.narrowPoints(pts, name, t, fields, tags, now)
+	} else {
+		pts = append(pts, client.Point{
+			Measurement: r.measurement(name, t),
+			Tags:        tags,
+			Fields:      fields,
+			Time:        now,
+			// The InfluxDB client serializes every point with its own precision.
+			Precision: r.precisionFor(t),
+		})
+		pts = r.quantilePoints(pts, name, t, quantiles, tags, now)
+	}
+	// the total series comes last, so that the point of the metric is the first one
+	if hasTotal {
+		pts = append(pts, total)
+	}
+	return pts
+}
+
+// measurement returns the measurement of the points of a metric.
+func (r *Reporter) measurement(name string, t MetricType) string {
+	name = r.metricName(name)
+	if tmpl, ok := r.templates[t]; ok {
+		return r.replaceDots(expandTemplate(tmpl, name, t))
+	}
+	suffix := r.suffixSep + suffixes[t]
+	if r.dedupSuffixes && strings.HasSuffix(name, suffix) {
+		return r.replaceDots(name)
+	}
+	return r.replaceDots(name + suffix)
+}
+
+// metricName returns the name of a metric as written to InfluxDB.
+func (r *Reporter) metricName(name string) string {
+	if r.normalizeNames {
+		name = normalizeName(name)
+	}
+	return name
+}
+
+// replaceDots replaces the dots of the measurement m as set by WithDotReplacement.
+// It is the last step of the naming of a measurement.
+func (r *Reporter) replaceDots(m string) string {
+	if r.dotReplacement == "" {
+		return m
+	}
+	return strings.Replace(m, ".", r.dotReplacement, -1)
+}
+
+// normalizeName trims the whitespace and dots surrounding name, and collapses repeated dots,
+// so that "svc..requests." becomes "svc.requests".
+func normalizeName(name string) string {
+	parts := strings.Split(strings.TrimSpace(name), ".")
+	res := parts[:0]
+	for _, p := range parts {
+		if p != "" {
+			res = append(res, p)
+		}
+	}
+	return strings.Join(res, ".")
+}
+
+// renameFields applies the field names configured with WithFieldName. The fields are renamed at once,
+// from their names before any rename, so that chains and swaps don't depend on the order of the renames.
+func (r *Reporter) renameFields(t MetricType, fields map[string]interface{}) {
+	names := r.fieldNames[t]
+	if len(names) == 0 {
+		return
+	}
+
+	renamed := make(map[string]interface{}, len(names))
+	for from, to := range names {
+		if v, ok := fields[from]; ok {
+			renamed[to] = v
+			delete(fields, from)
+		}
+	}
+	for k, v := range renamed {
+		fields[k] = v
+	}
+}
+
+// validateFieldNames checks the renames of WithFieldName: a field can't be renamed to an empty name,
+// nor two fields of a metric type to the same name.
+func validateFieldNames(fieldNames map[MetricType]map[string]string) error {
+	for t, names := range fieldNames {
+		if _, ok := suffixes[t]; !ok {
+			return fmt.Errorf("unknown metric type %q to rename fields of", t)
+		}
+
+		froms := make([]string, 0, len(names))
+		for from := range names {
+			froms = append(froms, from)
+		}
+		sort.Strings(froms)
+		renamed := make(map[string]string, len(names))
+		for _, from := range froms {
+			to := names[from]
+			if to == "" {
+				return fmt.Errorf("empty name for field %s of metric type %q", from, t)
+			}
+			if other, ok := renamed[to]; ok {
+				return fmt.Errorf("fields %s and %s of metric type %q are both renamed %s", other, from, t, to)
+			}
+			renamed[to] = from
+		}
+	}
+	return nil
+}
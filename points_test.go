@@ -0,0 +1,461 @@
+package influxdb
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestSnapshotDoesntChangeState(t *testing.T) {
+	newReporter := func(snapshots int) (*testWriter, *testLogger, metrics.Registry) {
+		reg := metrics.NewRegistry()
+		c := metrics.GetOrRegisterCounter("requests", reg)
+		c.Inc(3)
+		metrics.GetOrRegisterHistogram("latency", reg, metrics.NewUniformSample(10)).Update(5)
+		w, l := &testWriter{}, &testLogger{}
+		r := newTestReporter(t, reg, w,
+			WithLogger(l),
+			WithTags(map[string]string{"host": "a", "pod": "b"}),
+			WithAllowedTagKeys("host"),
+			WithSelfMetrics(),
+			WithDeltas(TypeCounter),
+			WithCounterRate(),
+			WithLastUpdated(),
+			WithSkipIdleHistograms(),
+			WithSampling(0.5),
+			WithAnnotations(""),
+			WithSeriesWarning(1),
+		)
+		now := fixedClock(r)
+
+		for i := 0; i < 2; i++ {
+			for j := 0; j < snapshots; j++ {
+				r.Snapshot()
+			}
+			flush(t, r)
+			*now = now.Add(time.Minute)
+			c.Inc(2)
+			metrics.GetOrRegisterGauge("added", reg).Update(1)
+		}
+		return w, l, reg
+	}
+
+	want, wantLogs, wantReg := newReporter(0)
+	got, gotLogs, gotReg := newReporter(2)
+	if g, w := sortedLines(t, got.points()), sortedLines(t, want.points()); !reflect.DeepEqual(g, w) {
+		t.Errorf("got points after snapshots\n%q\nwant\n%q", g, w)
+	}
+	// the measurement named by a warning depends on the order the metrics are read in
+	if g, w := gotLogs.messages(), wantLogs.messages(); len(g) != len(w) {
+		t.Errorf("got log messages after snapshots\n%q\nwant\n%q", g, w)
+	}
+	if g, w := registered(gotReg), registered(wantReg); !reflect.DeepEqual(g, w) {
+		t.Errorf("got metrics after snapshots %v, want %v", g, w)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	reg := newRegistryWithCounter()
+	w := &testWriter{}
+	r := newTestReporter(t, reg, w, WithTags(map[string]string{"host": "a"}), WithHeartbeat("heartbeat"))
+	fixedClock(r)
+
+	snap := r.Snapshot()
+	flush(t, r)
+	pts := w.points()
+	if len(snap) != len(pts) {
+		t.Fatalf("got %d points in the snapshot, %d written", len(snap), len(pts))
+	}
+	for i := range pts {
+		if !reflect.DeepEqual(*snap[i], pts[i]) {
+			t.Errorf("got point %v in the snapshot, %v written", *snap[i], pts[i])
+		}
+	}
+}
+
+// registered returns the names of the metrics of reg.
+func registered(reg metrics.Registry) map[string]bool {
+	names := make(map[string]bool)
+	reg.Each(func(name string, _ interface{}) { names[name] = true })
+	return names
+}
+
+func TestFieldNames(t *testing.T) {
+	reg := metrics.NewRegistry()
+	h := metrics.GetOrRegisterHistogram("latency", reg, metrics.NewUniformSample(10))
+	h.Update(2)
+	h.Update(4)
+	w := &testWriter{}
+	r := newTestReporter(t, reg, w,
+		WithPercentiles(),
+		// a swap and a chain, whatever the order of the renames
+		WithFieldName(TypeHistogram, "min", "max"),
+		WithFieldName(TypeHistogram, "max", "min"),
+		WithFieldName(TypeHistogram, "count", "samples"),
+		WithFieldName(TypeHistogram, "samples", "unused"),
+		WithFieldName(TypeHistogram, "mean", "avg"),
+	)
+	flush(t, r)
+
+	pts := w.find("latency.histogram")
+	if len(pts) != 1 {
+		t.Fatalf("got points %v", w.points())
+	}
+	want := map[string]interface{}{"min": int64(4), "max": int64(2), "samples": int64(2), "avg": 3.0, "stddev": 1.0, "variance": 1.0}
+	if !reflect.DeepEqual(pts[0].Fields, want) {
+		t.Errorf("got fields %v, want %v", pts[0].Fields, want)
+	}
+}
+
+func TestFieldNamesCollisions(t *testing.T) {
+	for _, opts := range [][]Option{
+		{WithFieldName(TypeTimer, "min", "low"), WithFieldName(TypeTimer, "max", "low")},
+		{WithFieldName(TypeTimer, "min", "")},
+		{WithFieldName("summary", "min", "low")},
+	} {
+		if _, err := BuildPoints(metrics.NewRegistry(), nil, time.Now(), opts...); err == nil {
+			t.Errorf("got no error for renames %d", len(opts))
+		}
+	}
+}
+
+func TestEnabledTypes(t *testing.T) {
+	reg := newMixedRegistry(5)
+	pts, err := BuildPoints(reg, nil, time.Now(), WithEnabledTypes(TypeCounter, TypeGauge))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pts) != 2 {
+		t.Fatalf("got %d points, want the counter and the gauge", len(pts))
+	}
+	for _, p := range pts {
+		if !strings.HasPrefix(p.Measurement, "metric.0") && !strings.HasPrefix(p.Measurement, "metric.1") {
+			t.Errorf("got a point of disabled metric %s", p.Measurement)
+		}
+	}
+
+	if _, err := BuildPoints(reg, nil, time.Now(), WithEnabledTypes()); err == nil {
+		t.Error("got no error enabling no metric type")
+	}
+	if _, err := BuildPoints(reg, nil, time.Now(), WithEnabledTypes("sample")); err == nil {
+		t.Error("got no error enabling an unknown metric type")
+	}
+
+	// an empty list in a configuration enables every type
+	r, err := NewFromConfig(Config{Registry: reg, Database: "db", EnabledTypes: []MetricType{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Stop()
+	if r.enabledTypes != nil {
+		t.Errorf("got enabled types %v, want all of them", r.enabledTypes)
+	}
+}
+
+func TestNormalizedNames(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"svc..requests", "svc.requests.count"},
+		{".svc.requests", "svc.requests.count"},
+		{"svc.requests.", "svc.requests.count"},
+		{" ..svc...requests.. ", "svc.requests.count"},
+	}
+	for _, tt := range tests {
+		reg := metrics.NewRegistry()
+		metrics.GetOrRegisterCounter(tt.name, reg).Inc(1)
+		pts, err := BuildPoints(reg, nil, time.Now(), WithNormalizedNames())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pts) != 1 || pts[0].Measurement != tt.want {
+			t.Errorf("%q: got points %v, want measurement %s", tt.name, pts, tt.want)
+		}
+	}
+
+	// names made of dots are skipped
+	reg := metrics.NewRegistry()
+	for _, name := range []string{"..", ".", " "} {
+		metrics.GetOrRegisterCounter(name, reg).Inc(1)
+	}
+	w := &testWriter{}
+	l := &testLogger{}
+	r := newTestReporter(t, reg, w, WithNormalizedNames(), WithLogger(l))
+	flush(t, r)
+	if pts := w.points(); len(pts) != 0 {
+		t.Errorf("got points %v of empty names", pts)
+	}
+	if msgs := l.messages(); len(msgs) != 3 {
+		t.Errorf("got messages %q, want one per skipped metric", msgs)
+	}
+
+	pts, err := BuildPoints(reg, nil, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pts) != 3 {
+		t.Errorf("got %d points without normalization, want 3", len(pts))
+	}
+}
+
+func TestDotReplacement(t *testing.T) {
+	reg := metrics.NewRegistry()
+	metrics.GetOrRegisterCounter("api.v1.users.requests", reg).Inc(1)
+
+	tests := []struct {
+		name string
+		opts []Option
+		want string
+	}{
+		{"multiple dots", nil, "api_v1_users_requests_count"},
+		{"suffix separator", []Option{WithSuffixSeparator(".")}, "api_v1_users_requests_count"},
+		{"template", []Option{WithMeasurementTemplate(TypeCounter, "app.{name}.{type}")}, "app_api_v1_users_requests_counter"},
+		{"normalized", []Option{WithNormalizedNames()}, "api_v1_users_requests_count"},
+		{"several characters", []Option{WithDotReplacement("::")}, "api::v1::users::requests::count"},
+	}
+	for _, tt := range tests {
+		pts, err := BuildPoints(reg, nil, time.Now(), append([]Option{WithDotReplacement("_")}, tt.opts...)...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pts) != 1 || pts[0].Measurement != tt.want {
+			t.Errorf("%s: got points %v, want measurement %s", tt.name, pts, tt.want)
+		}
+	}
+
+	// the measurements of the reporter are replaced too
+	w := &testWriter{}
+	r := newTestReporter(t, reg, w, WithDotReplacement("_"), WithHeartbeat("app.heartbeat"), WithUptime("app.uptime"),
+		WithRuntimeInfo("app.runtime"), WithAnnotations("app.events"))
+	flush(t, r)
+	reg.Unregister("api.v1.users.requests")
+	flush(t, r)
+	for _, m := range []string{"app_heartbeat", "app_uptime", "app_runtime", "app_events"} {
+		if len(w.find(m)) == 0 {
+			t.Errorf("got no point of %s", m)
+		}
+	}
+	for _, p := range w.points() {
+		if strings.Contains(p.Measurement, ".") {
+			t.Errorf("got measurement %s holding a dot", p.Measurement)
+		}
+	}
+}
+
+// unregisteringRegistry unregisters the metric gone once its name was listed, as a concurrent
+// unregistration would.
+type unregisteringRegistry struct {
+	metrics.Registry
+}
+
+func (r unregisteringRegistry) Each(fn func(string, interface{})) {
+	r.Registry.Each(fn)
+	r.Unregister("gone")
+}
+
+func TestRegistrySnapshot(t *testing.T) {
+	reg := metrics.NewRegistry()
+	for _, name := range []string{"c", "gone", "a", "b"} {
+		metrics.GetOrRegisterCounter(name, reg).Inc(1)
+	}
+
+	pts, err := BuildPoints(unregisteringRegistry{reg}, nil, time.Now(), WithRegistrySnapshot())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, p := range pts {
+		got = append(got, p.Measurement)
+	}
+	if want := []string{"a.count", "b.count", "c.count"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got measurements %v, want %v in name order", got, want)
+	}
+
+	// registrations during the flushes don't affect them
+	w := &testWriter{}
+	r := newTestReporter(t, reg, w, WithRegistrySnapshot())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 200; i++ {
+			name := "m" + strconv.Itoa(i%10)
+			metrics.GetOrRegisterCounter(name, reg).Inc(1)
+			reg.Unregister(name)
+		}
+	}()
+	for i := 0; i < 10; i++ {
+		flush(t, r)
+	}
+	<-done
+	for _, p := range w.points() {
+		if p.Fields["value"] == nil {
+			t.Errorf("got point %v without value", p)
+		}
+	}
+}
+
+func TestBoolGauges(t *testing.T) {
+	reg := metrics.NewRegistry()
+	metrics.GetOrRegisterGauge("api.up", reg).Update(1)
+	metrics.GetOrRegisterGauge("db.up", reg).Update(0)
+	metrics.GetOrRegisterGauge("leader", reg).Update(7)
+	metrics.GetOrRegisterGauge("connections", reg).Update(1)
+
+	pts, err := BuildPoints(reg, nil, time.Now(), WithBoolGauges("*.up", "leader"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"api.up.gauge": true, "db.up.gauge": false, "leader.gauge": true, "connections.gauge": int64(1)}
+	if len(pts) != len(want) {
+		t.Fatalf("got %d points, want %d", len(pts), len(want))
+	}
+	for _, p := range pts {
+		if v := p.Fields["value"]; v != want[p.Measurement] {
+			t.Errorf("got value %#v of %s, want %#v", v, p.Measurement, want[p.Measurement])
+		}
+	}
+
+	if _, err := BuildPoints(reg, nil, time.Now(), WithBoolGauges("[")); err == nil {
+		t.Error("got no error for an invalid pattern")
+	}
+	if _, err := BuildPoints(reg, nil, time.Now(), WithBoolGauges("*.up"), WithLayout(LayoutNarrow)); err == nil {
+		t.Error("got no error for boolean gauges in the narrow layout")
+	}
+}
+
+func TestFloatGauges(t *testing.T) {
+	reg := metrics.NewRegistry()
+	metrics.GetOrRegisterGauge("connections", reg).Update(7)
+	metrics.GetOrRegisterGauge("api.up", reg).Update(1)
+	newGaugeFloat64(reg, "load").Update(0.5)
+
+	for _, tt := range []struct {
+		name string
+		opts []Option
+		want map[string]interface{}
+	}{
+		{"default", nil, map[string]interface{}{"connections.gauge": int64(7), "api.up.gauge": int64(1), "load.gauge": 0.5}},
+		{"float", []Option{WithFloatGauges()}, map[string]interface{}{"connections.gauge": 7.0, "api.up.gauge": 1.0, "load.gauge": 0.5}},
+		// boolean gauges stay booleans
+		{"bool", []Option{WithFloatGauges(), WithBoolGauges("*.up")}, map[string]interface{}{"connections.gauge": 7.0, "api.up.gauge": true, "load.gauge": 0.5}},
+	} {
+		pts, err := BuildPoints(reg, nil, time.Now(), tt.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pts) != len(tt.want) {
+			t.Fatalf("%s: got %d points, want %d", tt.name, len(pts), len(tt.want))
+		}
+		for _, p := range pts {
+			if v := p.Fields["value"]; v != tt.want[p.Measurement] {
+				t.Errorf("%s: got value %#v of %s, want %#v", tt.name, v, p.Measurement, tt.want[p.Measurement])
+			}
+		}
+	}
+}
+
+func TestSkipIdleHistograms(t *testing.T) {
+	reg := metrics.NewRegistry()
+	idle := metrics.GetOrRegisterHistogram("idle", reg, metrics.NewUniformSample(100))
+	busy := metrics.GetOrRegisterHistogram("busy", reg, metrics.NewUniformSample(100))
+	idle.Update(1)
+	w := &testWriter{}
+	r := newTestReporter(t, reg, w, WithSkipIdleHistograms())
+
+	for i := 0; i < 3; i++ {
+		busy.Update(int64(i))
+		w.batches = nil
+		flush(t, r)
+		// the first flush writes every histogram
+		if n := len(w.find("idle.histogram")); n != 0 && i > 0 || n != 1 && i == 0 {
+			t.Errorf("flush %d: got %d points of the idle histogram", i, n)
+		}
+		if n := len(w.find("busy.histogram")); n != 1 {
+			t.Errorf("flush %d: got %d points of the updated histogram, want 1", i, n)
+		}
+	}
+	idle.Update(2)
+	w.batches = nil
+	flush(t, r)
+	if n := len(w.find("idle.histogram")); n != 1 {
+		t.Errorf("got %d points of the histogram updated again, want 1", n)
+	}
+
+	// with reset after read, the histograms without samples are skipped
+	r.Stop()
+	w = &testWriter{}
+	r = newTestReporter(t, reg, w, WithSkipIdleHistograms(), WithResetAfterRead())
+	flush(t, r)
+	busy.Update(1)
+	w.batches = nil
+	flush(t, r)
+	if pts := w.points(); len(pts) != 1 || pts[0].Measurement != "busy.histogram" {
+		t.Errorf("got points %v, want the updated histogram only", pts)
+	}
+}
+
+func TestDuplicateSuffixes(t *testing.T) {
+	reg := metrics.NewRegistry()
+	metrics.GetOrRegisterCounter("requests.count", reg).Inc(1)
+	metrics.GetOrRegisterTimer("latency.timer", reg).Update(1)
+	metrics.GetOrRegisterGauge("queue.count", reg).Update(1)
+	metrics.GetOrRegisterMeter("events", reg).Mark(1)
+
+	for _, tt := range []struct {
+		name string
+		opts []Option
+		want []string
+	}{
+		{"default", nil, []string{"events.meter", "latency.timer.timer", "queue.count.gauge", "requests.count.count"}},
+		{"deduplicated", []Option{WithoutDuplicateSuffixes()}, []string{"events.meter", "latency.timer", "queue.count.gauge", "requests.count"}},
+		{"separator", []Option{WithoutDuplicateSuffixes(), WithSuffixSeparator("_")}, []string{"events_meter", "latency.timer_timer", "queue.count_gauge", "requests.count_count"}},
+		{"template", []Option{WithoutDuplicateSuffixes(), WithMeasurementTemplate(TypeCounter, "{name}.{suffix}")}, []string{"events.meter", "latency.timer", "queue.count.gauge", "requests.count.count"}},
+	} {
+		pts, err := BuildPoints(reg, nil, time.Now(), tt.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, p := range pts {
+			got = append(got, p.Measurement)
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got measurements %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSuffixSeparator(t *testing.T) {
+	reg := newMixedRegistry(5)
+
+	for _, tt := range []struct {
+		name string
+		opts []Option
+		want []string
+	}{
+		{"default", nil, []string{"metric.0.count", "metric.1.gauge", "metric.2.meter", "metric.3.timer", "metric.4.histogram"}},
+		{"underscore", []Option{WithSuffixSeparator("_")}, []string{"metric.0_count", "metric.1_gauge", "metric.2_meter", "metric.3_timer", "metric.4_histogram"}},
+		{"template", []Option{WithSuffixSeparator("_"), WithMeasurementTemplate(TypeTimer, "app.{name}.{suffix}")}, []string{"metric.0_count", "metric.1_gauge", "metric.2_meter", "app.metric.3.timer", "metric.4_histogram"}},
+	} {
+		pts, err := BuildPoints(reg, nil, time.Now(), tt.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, p := range pts {
+			got = append(got, p.Measurement)
+		}
+		sort.Strings(got)
+		sort.Strings(tt.want)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got measurements %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
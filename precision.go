@@ -0,0 +1,73 @@
+package influxdb
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/influxdata/influxdb/client"
+)
+
+// PrecisionAuto selects the coarsest write precision which still gives distinct timestamps to
+// successive flushes: hours, minutes or seconds when the interval is at least that long,
+// nanoseconds for sub-second intervals.
+const PrecisionAuto = "auto"
+
+// autoPrecision returns the coarsest precision which separates flushes made every interval.
+// Since timestamps are rounded, two flushes at least one unit apart never share a timestamp.
+func autoPrecision(interval time.Duration) string {
+	switch {
+	case interval >= time.Hour:
+		return "h"
+	case interval >= time.Minute:
+		return "m"
+	case interval >= time.Second:
+		return "s"
+	default:
+		return "n"
+	}
+}
+
+func validatePrecision(p string) error {
+	switch p {
+	case "", "n", "ns", "u", "ms", "s", "m", "h":
+		return nil
+	default:
+		return fmt.Errorf("unknown precision %q", p)
+	}
+}
+
+// precisionFor returns the precision of the points of metric type t.
+func (r *Reporter) precisionFor(t MetricType) string {
+	if p, ok := r.typePrecisions[t]; ok {
+		return p
+	}
+	return r.precision
+}
+
+// splitPrecision splits bp into one batch per precision of its points, since the precision
+// of a write applies to all its timestamps. bp is returned as is when its points share a precision.
+func splitPrecision(bp client.BatchPoints) []client.BatchPoints {
+	var precisions []string
+	groups := make(map[string][]client.Point)
+	for _, p := range bp.Points {
+		precision := p.Precision
+		if precision == "" {
+			precision = bp.Precision
+		}
+		if _, ok := groups[precision]; !ok {
+			precisions = append(precisions, precision)
+		}
+		groups[precision] = append(groups[precision], p)
+	}
+	if len(precisions) <= 1 {
+		return []client.BatchPoints{bp}
+	}
+
+	res := make([]client.BatchPoints, len(precisions))
+	for i, precision := range precisions {
+		res[i] = bp
+		res[i].Points = groups[precision]
+		res[i].Precision = precision
+	}
+	return res
+}
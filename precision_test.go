@@ -0,0 +1,39 @@
+package influxdb
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAutoPrecision(t *testing.T) {
+	tests := []struct {
+		interval time.Duration
+		want     string
+	}{
+		{100 * time.Millisecond, "n"},
+		{999 * time.Millisecond, "n"},
+		{time.Second, "s"},
+		{1500 * time.Millisecond, "s"},
+		{10 * time.Second, "s"},
+		{59 * time.Second, "s"},
+		{time.Minute, "m"},
+		{90 * time.Second, "m"},
+		{time.Hour, "h"},
+		{36 * time.Hour, "h"},
+	}
+	for _, tt := range tests {
+		w := &testWriter{}
+		r, err := New(newRegistryWithCounter(), tt.interval, "", "db", "", "", WithWriter(w), WithPrecision(PrecisionAuto))
+		if err != nil {
+			t.Fatal(err)
+		}
+		flush(t, r)
+		if got := w.written()[0].Precision; got != tt.want {
+			t.Errorf("got precision %q for an interval of %s, want %q", got, tt.interval, tt.want)
+		}
+	}
+
+	if _, err := New(newRegistryWithCounter(), time.Second, "", "db", "", "", WithWriter(&testWriter{}), WithPrecision("auto-ish")); err == nil {
+		t.Error("got no error for an unknown precision")
+	}
+}
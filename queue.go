@@ -0,0 +1,180 @@
+package influxdb
+
+import (
+	"context"
+	"sync"
+
+	"github.com/influxdata/influxdb/client"
+)
+
+// QueuePolicy selects which batch is dropped when a batch is added to a full queue, see WithQueue.
+type QueuePolicy string
+
+const (
+	// DropOldest drops the oldest queued batch to make room for the new one. This is the default.
+	DropOldest QueuePolicy = "drop-oldest"
+	// DropNewest drops the new batch, keeping the queued ones.
+	DropNewest QueuePolicy = "drop-newest"
+)
+
+// defaultQueueDepth keeps only the batch of the last flush, so that a failed batch is dropped
+// by the next flush.
+const defaultQueueDepth = 1
+
+// batchQueue holds the batches waiting to be written, oldest first. The batches of a flush, one per
+// database and retention policy, are queued together. It is safe for concurrent use, so that batches
+// can be added while the queued ones are written in async mode.
+type batchQueue struct {
+	depth  int
+	policy QueuePolicy
+
+	mu      sync.Mutex
+	batches []queuedBatch
+	next    uint64
+}
+
+type queuedBatch struct {
+	id  uint64
+	bps []client.BatchPoints
+}
+
+// push adds the batches of a flush to the queue, returning false if a batch had to be dropped.
+func (q *batchQueue) push(bps []client.BatchPoints) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.next++
+	if len(q.batches) < q.depth {
+		q.batches = append(q.batches, queuedBatch{q.next, bps})
+		return true
+	}
+	if q.policy == DropNewest {
+		return false
+	}
+
+	q.batches[0] = queuedBatch{}
+	q.batches = append(q.batches[1:], queuedBatch{q.next, bps})
+	return true
+}
+
+// peek returns the oldest batch, if any.
+func (q *batchQueue) peek() (queuedBatch, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.batches) == 0 {
+		return queuedBatch{}, false
+	}
+	return q.batches[0], true
+}
+
+// remove removes the batch with the given id if it is still the oldest one,
+// it may have been dropped while it was written.
+func (q *batchQueue) remove(id uint64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.batches) > 0 && q.batches[0].id == id {
+		q.batches[0] = queuedBatch{}
+		q.batches = q.batches[1:]
+	}
+}
+
+// enqueue adds the batches of a flush to the queue of the reporter, counting a dropped batch.
+// It returns false if a batch was dropped.
+func (r *Reporter) enqueue(bps []client.BatchPoints) bool {
+	if r.queue.push(bps) {
+		return true
+	}
+
+	r.countDrop()
+	r.logf("write queue of %d batches full, dropping a batch (policy %s)", r.queue.depth, r.queue.policy)
+	return false
+}
+
+// drain writes the queued batches in order. A batch that fails to be written stays queued, unless
+// a write-ahead log took it over, and the batches after it are left for the next flush.
+func (r *Reporter) drain(ctx context.Context) error {
+	for {
+		qb, ok := r.queue.peek()
+		if !ok {
+			return nil
+		}
+
+		if r.writesPaused() {
+			return ErrRateLimited
+		}
+		if !r.allowWrite() {
+			for _, bp := range qb.bps {
+				if !r.writeFallback(ctx, bp, ErrCircuitOpen) {
+					return ErrCircuitOpen
+				}
+			}
+			r.queue.remove(qb.id)
+			continue
+		}
+		start := r.now()
+		err := r.write(ctx, qb.bps)
+		r.timeWrite(start)
+		if err != nil && r.wal == nil {
+			return err
+		}
+
+		r.queue.remove(qb.id)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// writeLoop writes the queued batches each time a flush adds one, until the reporter stops.
+// It is used in async mode, so that a slow write never delays the next flush. It is the only goroutine
+// writing the queue while it runs, the drains of Flush are handed to it, see drainOnWriteLoop.
+func (r *Reporter) writeLoop() {
+	defer close(r.writeDone)
+
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		case req := <-r.drains:
+			req.err <- r.drain(req.ctx)
+		case <-r.wake:
+			ctx, cancel := r.flushContext()
+			err := r.drain(ctx)
+			cancel()
+
+			r.setWriteResult(err)
+			if err != nil {
+				r.logf("unable to send metrics to InfluxDB. err=%v", err)
+			}
+		}
+	}
+}
+
+// drainRequest asks the write loop to drain the queue with ctx and to send the result to err.
+type drainRequest struct {
+	ctx context.Context
+	err chan error
+}
+
+// drainOnWriteLoop has the write loop drain the queue with ctx and waits for the result, so that
+// two drains never write the same batch, or the write-ahead log, concurrently. The queue is drained
+// directly once the write loop has returned.
+func (r *Reporter) drainOnWriteLoop(ctx context.Context) error {
+	req := drainRequest{ctx: ctx, err: make(chan error, 1)}
+	select {
+	case r.drains <- req:
+	case <-r.writeDone:
+		return r.drain(ctx)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case err := <-req.err:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,54 @@
+package influxdb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestAsyncFlushWritesBatchesOnce(t *testing.T) {
+	reg := metrics.NewRegistry()
+	metrics.GetOrRegisterCounter("requests", reg).Inc(1)
+	w := &testWriter{delay: 2 * time.Millisecond}
+	r, err := New(reg, time.Millisecond, "", "db", "", "", WithWriter(w), WithAsync(), WithQueue(4, DropOldest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go r.Run()
+	waitRunning(r)
+
+	// the periodic flushes keep the write loop busy while Flush writes
+	for i := 0; i < 20; i++ {
+		if err := r.Flush(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r.Stop()
+
+	seen := make(map[int64]bool)
+	for _, p := range w.find("requests.count") {
+		ts := p.Time.UnixNano()
+		if seen[ts] {
+			t.Fatalf("flush of %v written twice", p.Time)
+		}
+		seen[ts] = true
+	}
+	if len(seen) < 20 {
+		t.Errorf("got %d flushes written, want at least 20", len(seen))
+	}
+}
+
+// waitRunning waits for Run to start, so that Flush hands its flush to it.
+func waitRunning(r *Reporter) {
+	for {
+		r.mu.Lock()
+		running := r.running
+		r.mu.Unlock()
+		if running {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,113 @@
+package influxdb
+
+import (
+	"time"
+
+	"github.com/influxdata/influxdb/client"
+)
+
+// LimitPolicy selects what happens to a flush exceeding the rate limit set by WithRateLimit.
+type LimitPolicy string
+
+const (
+	// LimitDrop drops the points of the flush. This is the default.
+	LimitDrop LimitPolicy = "drop"
+	// LimitCoalesce keeps the points of the flush to write them with the next allowed one,
+	// which replaces those of the same series with its own. At most one point per series is kept.
+	LimitCoalesce LimitPolicy = "coalesce"
+)
+
+// tokenBucket allows a mean rate of tokens per second with bursts of one second worth of tokens.
+type tokenBucket struct {
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64) *tokenBucket {
+	if rate <= 0 {
+		return nil
+	}
+	return &tokenBucket{rate: rate, tokens: rate}
+}
+
+// burst returns the number of tokens the bucket holds when full.
+func (b *tokenBucket) burst() float64 {
+	if b.rate < 1 {
+		return 1
+	}
+	return b.rate
+}
+
+// refill adds the tokens earned since the last call.
+func (b *tokenBucket) refill(now time.Time) {
+	if !b.last.IsZero() {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+	}
+	if b.tokens > b.burst() {
+		b.tokens = b.burst()
+	}
+	b.last = now
+}
+
+// allows reports whether n tokens can be taken. More tokens than a burst can be taken from
+// a full bucket, they are then paid back before the next take.
+func (b *tokenBucket) allows(n float64) bool {
+	if n > b.burst() {
+		n = b.burst()
+	}
+	return b.tokens >= n
+}
+
+// rateLimiter limits the writes per second and points per second of the flushes.
+type rateLimiter struct {
+	writes  *tokenBucket
+	points  *tokenBucket
+	policy  LimitPolicy
+	pending []client.Point
+}
+
+// limit returns the points to write for a flush of pts at now, or false when the flush exceeds the limit.
+func (l *rateLimiter) limit(pts []client.Point, now time.Time) ([]client.Point, bool) {
+	if len(l.pending) > 0 {
+		pts = coalesce(l.pending, pts)
+		l.pending = nil
+	}
+
+	n := float64(len(pts))
+	for _, b := range []*tokenBucket{l.writes, l.points} {
+		if b != nil {
+			b.refill(now)
+		}
+	}
+	if l.writes != nil && !l.writes.allows(1) || l.points != nil && !l.points.allows(n) {
+		if l.policy == LimitCoalesce {
+			l.pending = pts
+		}
+		return nil, false
+	}
+	if l.writes != nil {
+		l.writes.tokens--
+	}
+	if l.points != nil {
+		l.points.tokens -= n
+	}
+
+	return pts, true
+}
+
+// coalesce returns the points of older not replaced by a point of the same series in newer, followed by newer.
+func coalesce(older, newer []client.Point) []client.Point {
+	replaced := make(map[string]bool, len(newer))
+	for _, p := range newer {
+		replaced[seriesKey(p)] = true
+	}
+
+	res := make([]client.Point, 0, len(older)+len(newer))
+	for _, p := range older {
+		if !replaced[seriesKey(p)] {
+			res = append(res, p)
+		}
+	}
+	return append(res, newer...)
+}
@@ -0,0 +1,118 @@
+package influxdb
+
+import (
+	"testing"
+	"time"
+
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+func TestTokenBucket(t *testing.T) {
+	if b := newTokenBucket(0); b != nil {
+		t.Errorf("got bucket %v without rate, want none", b)
+	}
+
+	now := time.Unix(1000, 0)
+	b := newTokenBucket(2)
+	b.refill(now)
+	if b.tokens != 2 || !b.allows(2) {
+		t.Fatalf("got %v tokens in a new bucket, want a burst of 2", b.tokens)
+	}
+	b.tokens -= 2
+	if b.allows(1) {
+		t.Error("got a token from an empty bucket")
+	}
+
+	b.refill(now.Add(500 * time.Millisecond))
+	if !b.allows(1) || b.allows(2) {
+		t.Errorf("got %v tokens after 500ms at 2/s, want 1", b.tokens)
+	}
+	b.refill(now.Add(time.Minute))
+	if b.tokens != 2 {
+		t.Errorf("got %v tokens after a minute, want them capped to the burst of 2", b.tokens)
+	}
+
+	// below 1/s the burst is a single token, and a full bucket allows more
+	b = newTokenBucket(0.5)
+	b.refill(now)
+	b.refill(now.Add(2 * time.Second))
+	if b.burst() != 1 || !b.allows(10) {
+		t.Errorf("got burst %v, want a full bucket of 1 to allow 10 tokens", b.burst())
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	tests := []struct {
+		name    string
+		opt     Option
+		batches int
+	}{
+		// three flushes of 2 points at once, then one a second later
+		{"writes", WithRateLimit(1, 0, LimitDrop), 2},
+		{"burst", WithRateLimit(2, 0, LimitDrop), 3},
+		{"points", WithRateLimit(0, 3, LimitDrop), 2},
+		// the points over the burst are paid back before the next write
+		{"points over the burst", WithRateLimit(0, 1, LimitDrop), 1},
+	}
+	for _, tt := range tests {
+		reg := metrics.NewRegistry()
+		metrics.GetOrRegisterCounter("a", reg).Inc(1)
+		metrics.GetOrRegisterCounter("b", reg).Inc(1)
+		w := &testWriter{}
+		r := newTestReporter(t, reg, w, tt.opt)
+		now := fixedClock(r)
+		for i := 0; i < 4; i++ {
+			if i == 3 {
+				*now = now.Add(time.Second)
+			}
+			flush(t, r)
+		}
+
+		if got := len(w.written()); got != tt.batches {
+			t.Errorf("%s: got %d batches, want %d", tt.name, got, tt.batches)
+		}
+		for _, bp := range w.written() {
+			if len(bp.Points) != 2 {
+				t.Errorf("%s: got points %v, want those of a flush", tt.name, bp.Points)
+			}
+		}
+	}
+}
+
+func TestRateLimitCoalesce(t *testing.T) {
+	reg := metrics.NewRegistry()
+	c := metrics.GetOrRegisterCounter("a", reg)
+	c.Inc(1)
+	w := &testWriter{}
+	r := newTestReporter(t, reg, w, WithRateLimit(1, 0, LimitCoalesce))
+	now := fixedClock(r)
+
+	flush(t, r)
+	c.Inc(1)
+	flush(t, r)
+	c.Inc(1)
+	metrics.GetOrRegisterCounter("b", reg).Inc(1)
+	flush(t, r)
+	if got := len(w.written()); got != 1 {
+		t.Fatalf("got %d batches, want the flushes over the limit held back", got)
+	}
+
+	// the held back points are written with the next allowed flush, at most one per series
+	*now = now.Add(time.Second)
+	c.Inc(1)
+	flush(t, r)
+	written := w.written()
+	if len(written) != 2 {
+		t.Fatalf("got %d batches, want 2", len(written))
+	}
+	got := map[string]interface{}{}
+	for _, p := range written[1].Points {
+		if _, ok := got[p.Measurement]; ok {
+			t.Errorf("got several points of %s", p.Measurement)
+		}
+		got[p.Measurement] = p.Fields["value"]
+	}
+	if len(got) != 2 || got["a.count"] != int64(4) || got["b.count"] != int64(1) {
+		t.Errorf("got values %v, want the last ones of a and b", got)
+	}
+}
@@ -0,0 +1,110 @@
+package influxdb
+
+import "fmt"
+
+// RateWindow identifies one of the rates, in events per second, computed by meters and timers.
+type RateWindow string
+
+const (
+	// Rate1 is the one-minute moving average rate.
+	Rate1 RateWindow = "m1"
+	// Rate5 is the five-minute moving average rate.
+	Rate5 RateWindow = "m5"
+	// Rate15 is the fifteen-minute moving average rate.
+	Rate15 RateWindow = "m15"
+	// RateMean is the mean rate since the metric was created.
+	RateMean RateWindow = "mean"
+)
+
+var (
+	defaultMeterRates = map[RateWindow]string{
+		Rate1:    "m1",
+		Rate5:    "m5",
+		Rate15:   "m15",
+		RateMean: "mean",
+	}
+	defaultTimerRates = map[RateWindow]string{
+		Rate1:    "m1",
+		Rate5:    "m5",
+		Rate15:   "m15",
+		RateMean: "meanrate",
+	}
+)
+
+// consistentMeterRates returns names with the mean rate field named "meanrate" rather than "mean",
+// as for timers, see WithConsistentMeanRate.
+func consistentMeterRates(names map[RateWindow]string) map[RateWindow]string {
+	if names[RateMean] != "mean" {
+		return names
+	}
+	renamed := make(map[RateWindow]string, len(names))
+	for w, name := range names {
+		renamed[w] = name
+	}
+	renamed[RateMean] = "meanrate"
+	return renamed
+}
+
+// rater is implemented by the snapshots of meters and timers.
+type rater interface {
+	Rate1() float64
+	Rate5() float64
+	Rate15() float64
+	RateMean() float64
+}
+
+// addRates adds to fields the rates of ms selected by names, which maps a window to its field name.
+func (r *Reporter) addRates(fields map[string]interface{}, ms rater, names map[RateWindow]string) {
+	for w, name := range names {
+		var v float64
+		switch w {
+		case Rate1:
+			v = ms.Rate1()
+		case Rate5:
+			v = ms.Rate5()
+		case Rate15:
+			v = ms.Rate15()
+		case RateMean:
+			v = ms.RateMean()
+		}
+		fields[name] = r.format.rate(v)
+	}
+}
+
+// meterFields and timerFields are the fields of the points of meters and timers besides their rates
+// and percentiles, which the field names of the rates must not overwrite.
+var (
+	meterFields = []string{"count", "delta", LastUpdatedField}
+	timerFields = []string{"count", "max", "mean", "min", "stddev", "variance", "sum", "delta", LastUpdatedField}
+)
+
+// validateRates checks the field names of the rate windows, which must differ from each other
+// and from the other fields of the metric.
+func validateRates(names map[RateWindow]string, fields []string) error {
+	for w := range names {
+		switch w {
+		case Rate1, Rate5, Rate15, RateMean:
+		default:
+			return fmt.Errorf("unknown rate window %q", w)
+		}
+	}
+
+	taken := make(map[string]string, len(fields)+len(names))
+	for _, f := range fields {
+		taken[f] = "another field"
+	}
+	for _, w := range []RateWindow{Rate1, Rate5, Rate15, RateMean} {
+		name, ok := names[w]
+		if !ok {
+			continue
+		}
+		if name == "" {
+			return fmt.Errorf("empty field name for rate window %q", w)
+		}
+		if other, ok := taken[name]; ok {
+			return fmt.Errorf("field name %q of rate window %q is already used by %s", name, w, other)
+		}
+		taken[name] = fmt.Sprintf("rate window %q", w)
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package influxdb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestRateFieldNames(t *testing.T) {
+	reg := metrics.NewRegistry()
+	metrics.GetOrRegisterMeter("events", reg).Mark(1)
+	w := &testWriter{}
+	r := newTestReporter(t, reg, w, WithMeterRates(map[RateWindow]string{Rate1: "rate_1m", RateMean: "rate_mean"}))
+	flush(t, r)
+
+	pts := w.find("events.meter")
+	if len(pts) != 1 {
+		t.Fatalf("got points %v", w.points())
+	}
+	for _, f := range []string{"count", "rate_1m", "rate_mean"} {
+		if _, ok := pts[0].Fields[f]; !ok {
+			t.Errorf("no field %s in %v", f, pts[0].Fields)
+		}
+	}
+	if len(pts[0].Fields) != 3 {
+		t.Errorf("got fields %v, want only the selected rates", pts[0].Fields)
+	}
+}
+
+func TestRateFieldNamesCollisions(t *testing.T) {
+	for _, opt := range []Option{
+		WithMeterRates(map[RateWindow]string{Rate1: "count"}),
+		WithMeterRates(map[RateWindow]string{Rate1: "rate", Rate5: "rate"}),
+		WithMeterRates(map[RateWindow]string{Rate1: ""}),
+		WithMeterRates(map[RateWindow]string{"m2": "m2"}),
+		WithTimerRates(map[RateWindow]string{RateMean: "mean"}),
+		WithTimerRates(map[RateWindow]string{Rate1: "p50"}),
+		WithTimerRates(map[RateWindow]string{Rate1: "max"}),
+	} {
+		if _, err := New(metrics.NewRegistry(), time.Minute, "", "", "", "", WithWriter(&testWriter{}), opt); err == nil {
+			t.Errorf("got no error for colliding rate field names")
+		}
+	}
+
+	// the mean rate of meters is named like the mean duration of timers
+	if _, err := New(metrics.NewRegistry(), time.Minute, "", "", "", "", WithWriter(&testWriter{}), WithMeterRates(map[RateWindow]string{RateMean: "mean"})); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestConsistentMeanRate(t *testing.T) {
+	reg := metrics.NewRegistry()
+	metrics.GetOrRegisterMeter("events", reg).Mark(1)
+	metrics.GetOrRegisterTimer("latency", reg).Update(time.Millisecond)
+
+	for _, tt := range []struct {
+		name string
+		opts []Option
+		want string
+	}{
+		{"historical", nil, "mean"},
+		{"consistent", []Option{WithConsistentMeanRate()}, "meanrate"},
+		// a custom name is kept
+		{"custom", []Option{WithConsistentMeanRate(), WithMeterRates(map[RateWindow]string{RateMean: "rate"})}, "rate"},
+	} {
+		pts, err := BuildPoints(reg, nil, time.Now(), tt.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range pts {
+			switch p.Measurement {
+			case "events.meter":
+				if _, ok := p.Fields[tt.want]; !ok {
+					t.Errorf("%s: got meter fields %v, want the mean rate in %s", tt.name, p.Fields, tt.want)
+				}
+				if tt.want != "mean" {
+					if _, ok := p.Fields["mean"]; ok {
+						t.Errorf("%s: got meter fields %v holding mean", tt.name, p.Fields)
+					}
+				}
+			case "latency.timer":
+				if _, ok := p.Fields["meanrate"]; !ok {
+					t.Errorf("%s: got timer fields %v, want the mean rate in meanrate", tt.name, p.Fields)
+				}
+			}
+		}
+	}
+}
+
+// fixedRatesMeter and fixedRatesTimer have fixed rates of 1, 5, 15 and 2 events per second.
+type fixedRatesMeter struct{ metrics.Meter }
+
+func (m fixedRatesMeter) Snapshot() metrics.Meter { return m }
+func (fixedRatesMeter) Rate1() float64            { return 1 }
+func (fixedRatesMeter) Rate5() float64            { return 5 }
+func (fixedRatesMeter) Rate15() float64           { return 15 }
+func (fixedRatesMeter) RateMean() float64         { return 2 }
+
+type fixedRatesTimer struct{ metrics.Timer }
+
+func (t fixedRatesTimer) Snapshot() metrics.Timer { return fixedRatesTimer{t.Timer.Snapshot()} }
+func (fixedRatesTimer) Rate1() float64            { return 1 }
+func (fixedRatesTimer) Rate5() float64            { return 5 }
+func (fixedRatesTimer) Rate15() float64           { return 15 }
+func (fixedRatesTimer) RateMean() float64         { return 2 }
+
+func TestRateUnit(t *testing.T) {
+	reg := metrics.NewRegistry()
+	m := metrics.NewMeter()
+	m.Mark(3)
+	reg.Register("events", fixedRatesMeter{m})
+	timer := metrics.NewTimer()
+	timer.Update(2 * time.Second)
+	reg.Register("latency", fixedRatesTimer{timer})
+
+	for _, tt := range []struct {
+		name  string
+		opts  []Option
+		scale float64
+		max   interface{}
+	}{
+		{"per second", nil, 1, int64(2 * time.Second)},
+		{"per minute", []Option{WithRateUnit(time.Minute)}, 60, int64(2 * time.Second)},
+		{"per minute in seconds", []Option{WithRateUnit(time.Minute), WithDurationUnit(time.Second, -1)}, 60, 2.0},
+	} {
+		pts, err := BuildPoints(reg, nil, time.Now(), tt.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pts) != 2 {
+			t.Fatalf("%s: got %d points, want 2", tt.name, len(pts))
+		}
+		for _, p := range pts {
+			mean := "mean"
+			if p.Measurement == "latency.timer" {
+				mean = "meanrate"
+				if p.Fields["max"] != tt.max {
+					t.Errorf("%s: got max %#v, want %#v", tt.name, p.Fields["max"], tt.max)
+				}
+			}
+			want := map[string]float64{"m1": 1, "m5": 5, "m15": 15, mean: 2}
+			for k, v := range want {
+				if got := p.Fields[k]; got != v*tt.scale {
+					t.Errorf("%s: got %s %v of %s, want %v", tt.name, k, got, p.Measurement, v*tt.scale)
+				}
+			}
+			// counts are never scaled
+			if want := map[string]int64{"events.meter": 3, "latency.timer": 1}[p.Measurement]; p.Fields["count"] != want {
+				t.Errorf("%s: got count %v of %s, want %d", tt.name, p.Fields["count"], p.Measurement, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package influxdb
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+// defaultRegistryName is the name of the registry given to the constructor, as used by WithRegistryTag.
+const defaultRegistryName = "default"
+
+// errNilRegistry is returned for a nil registry, which would make the flushes panic.
+var errNilRegistry = errors.New("metrics registry must not be nil")
+
+type namedRegistry struct {
+	name string
+	reg  metrics.Registry
+}
+
+// AddRegistry makes the reporter also report the metrics of reg, with the same tags and options.
+// It can be called while the reporter runs. The name identifies the registry in the tag set by
+// WithRegistryTag and in log messages, it must be unique and differ from "default", the name
+// of the registry given to the constructor.
+func (r *Reporter) AddRegistry(name string, reg metrics.Registry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if name == "" || name == defaultRegistryName {
+		return fmt.Errorf("invalid registry name %q", name)
+	}
+	if reg == nil {
+		return errNilRegistry
+	}
+	for _, nr := range r.extraRegs {
+		if nr.name == name {
+			return fmt.Errorf("registry %s already added", name)
+		}
+	}
+	if err := acquireRegistry(reg, r.resetAfterRead); err != nil {
+		return err
+	}
+
+	r.extraRegs = append(r.extraRegs, namedRegistry{name: name, reg: reg})
+
+	return nil
+}
+
+// registries returns the registries to report, the default one first.
+func (r *Reporter) registries() []namedRegistry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	regs := make([]namedRegistry, 0, len(r.extraRegs)+1)
+	regs = append(regs, namedRegistry{name: defaultRegistryName, reg: r.reg})
+	return append(regs, r.extraRegs...)
+}
@@ -0,0 +1,26 @@
+package influxdb
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNilRegistry(t *testing.T) {
+	_, err := New(nil, time.Minute, "", "db", "", "", WithWriter(&testWriter{}))
+	var ce *ConfigError
+	if !errors.As(err, &ce) || !errors.Is(err, errNilRegistry) {
+		t.Errorf("got error %v from New, want a *ConfigError for the nil registry", err)
+	}
+
+	if _, err := BuildPoints(nil, nil, time.Now()); err != errNilRegistry {
+		t.Errorf("got error %v from BuildPoints, want %v", err, errNilRegistry)
+	}
+
+	r := newTestReporter(t, newRegistryWithCounter(), &testWriter{})
+	if err := r.AddRegistry("jobs", nil); err != errNilRegistry {
+		t.Errorf("got error %v from AddRegistry, want %v", err, errNilRegistry)
+	}
+	// the reporter still flushes its own registry
+	flush(t, r)
+}
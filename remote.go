@@ -0,0 +1,82 @@
+package influxdb
+
+import "time"
+
+// RemoteConfig holds the reporting parameters fetched by the function set with WithConfigFetcher.
+type RemoteConfig struct {
+	// Interval replaces the interval of the reporter, unless zero.
+	Interval time.Duration
+	// Tags replace the global tags of the reporter, unless nil.
+	Tags map[string]string
+}
+
+// SetInterval changes the interval between two flushes. It can be called while the reporter runs,
+// the next flush then happens d after the change. The precision selected by PrecisionAuto is kept.
+func (r *Reporter) SetInterval(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+
+	r.mu.Lock()
+	r.interval = d
+	r.mu.Unlock()
+
+	select {
+	case r.reconfigured <- struct{}{}:
+	default:
+	}
+}
+
+// SetTags replaces the tags added to every point with a copy of tags. It can be called while the reporter runs.
+func (r *Reporter) SetTags(tags map[string]string) {
+	copied := copyTags(tags)
+
+	r.mu.Lock()
+	r.tags = copied
+	r.mu.Unlock()
+}
+
+func (r *Reporter) getInterval() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.interval
+}
+
+func (r *Reporter) getTags() map[string]string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.tags
+}
+
+// fetchConfig applies the configuration returned by the fetcher, keeping the current one on failure.
+func (r *Reporter) fetchConfig() {
+	ctx, cancel := r.flushContext()
+	defer cancel()
+
+	c, err := r.fetcher(ctx)
+	if err != nil {
+		r.logf("unable to fetch reporter configuration, keeping the current one. err=%v", err)
+		return
+	}
+	if c.Interval != 0 && c.Interval != r.getInterval() {
+		r.SetInterval(c.Interval)
+	}
+	if c.Tags != nil {
+		r.SetTags(c.Tags)
+	}
+}
+
+// fetchLoop fetches the configuration at each fetchEvery until the reporter stops.
+func (r *Reporter) fetchLoop() {
+	ticker := time.NewTicker(r.fetchEvery)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-ticker.C:
+			r.fetchConfig()
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package influxdb
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestConfigFetcher(t *testing.T) {
+	var (
+		c   RemoteConfig
+		err error
+	)
+	fetch := func(ctx context.Context) (RemoteConfig, error) {
+		return c, err
+	}
+	w := &testWriter{}
+	r := newTestReporter(t, newRegistryWithCounter(), w, WithConfigFetcher(fetch, time.Minute), WithTags(map[string]string{"host": "web1"}))
+
+	c = RemoteConfig{Interval: 10 * time.Second, Tags: map[string]string{"host": "web2"}}
+	r.fetchConfig()
+	check := func(what string) {
+		t.Helper()
+
+		flush(t, r)
+		pts := w.points()
+		if got := r.getInterval(); got != 10*time.Second {
+			t.Errorf("%s: got interval %s, want the fetched one", what, got)
+		}
+		if got := pts[len(pts)-1].Tags["host"]; got != "web2" {
+			t.Errorf("%s: got host %q, want the fetched one", what, got)
+		}
+	}
+	check("fetched")
+
+	// a failed fetch keeps the last known configuration
+	c, err = RemoteConfig{Interval: time.Hour, Tags: map[string]string{"host": "web3"}}, errTest
+	r.fetchConfig()
+	check("failed fetch")
+
+	// and so do the unset parameters
+	c, err = RemoteConfig{}, nil
+	r.fetchConfig()
+	check("unset parameters")
+
+	if _, err := New(newRegistryWithCounter(), time.Minute, "", "db", "", "", WithWriter(w), WithConfigFetcher(fetch, 0)); err == nil {
+		t.Error("got no error for a fetch interval of 0")
+	}
+}
@@ -0,0 +1,51 @@
+package influxdb
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+var errSharedResetRegistry = errors.New("reset after read requires the registry not to be shared with another reporter")
+
+// registryUsers tracks the registries used by reporters, so that a reporter resetting
+// the metrics it reads never shares its registry with another one.
+var registryUsers = struct {
+	sync.Mutex
+	count map[metrics.Registry]int
+	reset map[metrics.Registry]bool
+}{
+	count: make(map[metrics.Registry]int),
+	reset: make(map[metrics.Registry]bool),
+}
+
+// acquireRegistry records that a reporter uses reg, failing if this would share
+// a registry with a reporter resetting its metrics.
+func acquireRegistry(reg metrics.Registry, reset bool) error {
+	registryUsers.Lock()
+	defer registryUsers.Unlock()
+
+	if registryUsers.reset[reg] || (reset && registryUsers.count[reg] > 0) {
+		return errSharedResetRegistry
+	}
+	registryUsers.count[reg]++
+	if reset {
+		registryUsers.reset[reg] = true
+	}
+
+	return nil
+}
+
+// releaseRegistry records that a reporter stopped using reg.
+func releaseRegistry(reg metrics.Registry, reset bool) {
+	registryUsers.Lock()
+	defer registryUsers.Unlock()
+
+	if registryUsers.count[reg]--; registryUsers.count[reg] <= 0 {
+		delete(registryUsers.count, reg)
+	}
+	if reset {
+		delete(registryUsers.reset, reg)
+	}
+}
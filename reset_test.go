@@ -0,0 +1,46 @@
+package influxdb
+
+import (
+	"testing"
+
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+func TestReset(t *testing.T) {
+	reg := metrics.NewRegistry()
+	c := metrics.GetOrRegisterCounter("requests", reg)
+	c.Inc(5)
+	metrics.GetOrRegisterGauge("connections", reg).Update(3)
+	w := &testWriter{}
+	r := newTestReporter(t, reg, w, WithDeltas(TypeCounter), WithSkipUnchanged())
+
+	flush(t, r)
+	c.Inc(2)
+	r.Reset()
+	flush(t, r)
+
+	// the second flush behaves like the first one
+	pts := w.find("requests.count")
+	if len(pts) != 2 || pts[1].Fields["delta"] != int64(7) {
+		t.Errorf("got points %v, want a delta of the whole count after Reset", pts)
+	}
+	if pts := w.find("connections.gauge"); len(pts) != 2 {
+		t.Errorf("got points %v, want the unchanged gauge written again after Reset", pts)
+	}
+
+	// Reset applies to the next flush only
+	c.Inc(1)
+	flush(t, r)
+	if pts := w.find("requests.count"); len(pts) != 3 || pts[2].Fields["delta"] != int64(1) {
+		t.Errorf("got points %v, want a delta from the previous flush", pts)
+	}
+
+	// it is a no-op without state
+	w = &testWriter{}
+	r = newTestReporter(t, reg, w)
+	r.Reset()
+	flush(t, r)
+	if got := len(w.points()); got != 2 {
+		t.Errorf("got %d points, want 2", got)
+	}
+}
@@ -0,0 +1,46 @@
+package influxdb
+
+import "strings"
+
+// Resource describes the entity producing the metrics, with the attributes of the
+// OpenTelemetry resource semantic conventions. Its Tags method builds the tags given to a reporter.
+type Resource struct {
+	ServiceName           string // service.name
+	ServiceVersion        string // service.version
+	DeploymentEnvironment string // deployment.environment
+	HostName              string // host.name
+
+	// Attributes holds any other resource attribute, keyed by its OpenTelemetry name.
+	Attributes map[string]string
+}
+
+// Tags returns the non-empty attributes of the resource as tags, with their names translated by key.
+// A nil key keeps the OpenTelemetry names, UnderscoreKeys turns "service.name" into "service_name".
+// The well-known fields take precedence over Attributes.
+func (res Resource) Tags(key func(string) string) map[string]string {
+	if key == nil {
+		key = func(name string) string { return name }
+	}
+
+	tags := make(map[string]string, len(res.Attributes)+4)
+	add := func(name, value string) {
+		if value != "" {
+			tags[key(name)] = value
+		}
+	}
+
+	for name, value := range res.Attributes {
+		add(name, value)
+	}
+	add("service.name", res.ServiceName)
+	add("service.version", res.ServiceVersion)
+	add("deployment.environment", res.DeploymentEnvironment)
+	add("host.name", res.HostName)
+
+	return tags
+}
+
+// UnderscoreKeys replaces the dots of an attribute name with underscores.
+func UnderscoreKeys(name string) string {
+	return strings.Replace(name, ".", "_", -1)
+}
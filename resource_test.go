@@ -0,0 +1,47 @@
+package influxdb
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestResourceTags(t *testing.T) {
+	res := Resource{
+		ServiceName:           "api",
+		ServiceVersion:        "1.2.0",
+		DeploymentEnvironment: "prod",
+		Attributes: map[string]string{
+			"cloud.region": "eu-west-1",
+			"service.name": "overridden",
+			"empty":        "",
+		},
+	}
+
+	tests := []struct {
+		name string
+		key  func(string) string
+		want map[string]string
+	}{
+		{"OpenTelemetry names", nil, map[string]string{
+			"service.name":           "api",
+			"service.version":        "1.2.0",
+			"deployment.environment": "prod",
+			"cloud.region":           "eu-west-1",
+		}},
+		{"underscores", UnderscoreKeys, map[string]string{
+			"service_name":           "api",
+			"service_version":        "1.2.0",
+			"deployment_environment": "prod",
+			"cloud_region":           "eu-west-1",
+		}},
+	}
+	for _, tt := range tests {
+		if got := res.Tags(tt.key); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got tags %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	if got := (Resource{}).Tags(nil); len(got) != 0 {
+		t.Errorf("got tags %v of an empty resource, want none", got)
+	}
+}
@@ -0,0 +1,99 @@
+package influxdb
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/influxdata/influxdb/client"
+)
+
+// writeBatch writes bp with the writer, retrying up to r.retries times after a failure, see WithRetry.
+// Every attempt writes the same points with the timestamps of their flush, so that an attempt
+// reaching InfluxDB after the previous one timed out overwrites the points rather than duplicating them.
+// No retry starts once the time spent on the batch would exceed the budget of WithRetryBudget.
+func (r *Reporter) writeBatch(ctx context.Context, bp client.BatchPoints) error {
+	backoff := r.retryBackoff
+	start := r.now()
+	for attempt := 0; ; attempt++ {
+		err := r.writer.WriteBatch(ctx, bp)
+		if err == nil || attempt >= r.retries {
+			return err
+		}
+		if errors.Is(err, ErrRateLimited) {
+			// retrying before the delay requested by the server would make things worse
+			return err
+		}
+		if _, ok := partialWrite(err); ok {
+			// the points accepted by the server are stored, the others would be rejected again
+			return err
+		}
+		if !r.retryable(err) {
+			return err
+		}
+		if r.retryBudget > 0 && r.now().Sub(start)+backoff > r.retryBudget {
+			r.logf("retry budget of %v exhausted after %d attempts, giving up writing the batch. err=%v", r.retryBudget, attempt+1, err)
+			return err
+		}
+		r.selfCounter("retries").Inc(1)
+
+		if r.sleep(ctx, backoff) != nil {
+			return err
+		}
+		backoff *= 2
+	}
+}
+
+// sleep waits for d, or until ctx is done in which case it returns its error. It is the default
+// of the sleep function of the reporter, which tests replace along with its clock.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// rejections are the messages of the InfluxDB errors caused by the batch or the settings of the reporter,
+// for the custom writers reporting them without their status.
+var rejections = []string{
+	"field type conflict",
+	"unable to parse",
+	"database not found",
+	"retention policy not found",
+	"authorization failed",
+}
+
+// DefaultRetryable is the default of WithRetryable. It retries the network errors, the timeouts and the
+// writes rejected with a 5xx status, but not those rejected with a 4xx status such as field type conflicts
+// or the partial writes, which would fail the same way. The errors of custom writers are retried.
+func DefaultRetryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, ErrRateLimited) {
+		return false
+	}
+	if _, ok := partialWrite(err); ok {
+		return false
+	}
+	var we *WriteError
+	if errors.As(err, &we) {
+		return we.StatusCode >= 500
+	}
+	var ne net.Error
+	if errors.As(err, &ne) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	msg := err.Error()
+	for _, rejection := range rejections {
+		if strings.Contains(msg, rejection) {
+			return false
+		}
+	}
+	return true
+}
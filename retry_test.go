@@ -0,0 +1,183 @@
+package influxdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb/client"
+)
+
+// flakyWriter fails its writes with the errors of errs in turn, then succeeds.
+type flakyWriter struct {
+	errs     []error
+	attempts int
+}
+
+func (w *flakyWriter) WriteBatch(ctx context.Context, bp client.BatchPoints) error {
+	w.attempts++
+	if len(w.errs) == 0 {
+		return nil
+	}
+	err := w.errs[0]
+	w.errs = w.errs[1:]
+	return err
+}
+
+func TestDefaultRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&WriteError{StatusCode: 500, Body: "internal error"}, true},
+		{&WriteError{StatusCode: 503, Body: "unavailable"}, true},
+		{&WriteError{StatusCode: 400, Body: `{"error":"field type conflict"}`}, false},
+		{&WriteError{StatusCode: 404, Body: `{"error":"database not found: db"}`}, false},
+		{&PartialWriteError{Reason: "field type conflict", Dropped: 1}, false},
+		{errors.New(`{"error":"partial write: points beyond retention policy dropped=2"}`), false},
+		{fmt.Errorf("write: %w", ErrRateLimited), false},
+		{context.Canceled, false},
+		{context.DeadlineExceeded, true},
+		{&net.OpError{Op: "dial", Err: errors.New("connection refused")}, true},
+		{errors.New("field type conflict: input field value"), false},
+		{errors.New("custom writer failure"), true},
+	}
+	for _, tt := range tests {
+		if got := DefaultRetryable(tt.err); got != tt.want {
+			t.Errorf("DefaultRetryable(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name      string
+		errs      []error
+		retryable func(error) bool
+		attempts  int
+		fails     bool
+	}{
+		{"transient", []error{&WriteError{StatusCode: 503}, &WriteError{StatusCode: 502}}, nil, 3, false},
+		{"exhausted", []error{errTest, errTest, errTest, errTest, errTest}, nil, 4, true},
+		{"rejected", []error{&WriteError{StatusCode: 400, Body: "unable to parse"}}, nil, 1, true},
+		{"custom", []error{&WriteError{StatusCode: 400}}, func(error) bool { return true }, 2, false},
+		{"custom rejection", []error{&WriteError{StatusCode: 503}}, func(error) bool { return false }, 1, true},
+		// the accepted points are stored whatever the classification, the flush reports the others as dropped
+		{"partial write", []error{&PartialWriteError{Reason: "field type conflict", Dropped: 1}}, func(error) bool { return true }, 1, false},
+		{"rate limited", []error{ErrRateLimited}, func(error) bool { return true }, 1, true},
+	}
+	for _, tt := range tests {
+		w := &flakyWriter{errs: tt.errs}
+		opts := []Option{WithRetry(3, time.Microsecond)}
+		if tt.retryable != nil {
+			opts = append(opts, WithRetryable(tt.retryable))
+		}
+		r := newTestReporter(t, newRegistryWithCounter(), w, opts...)
+
+		err := r.Flush(context.Background())
+		if fails := err != nil; fails != tt.fails {
+			t.Errorf("%s: got error %v, want failure %v", tt.name, err, tt.fails)
+		}
+		if w.attempts != tt.attempts {
+			t.Errorf("%s: got %d attempts, want %d", tt.name, w.attempts, tt.attempts)
+		}
+	}
+}
+
+func TestRetryBudget(t *testing.T) {
+	w := &flakyWriter{errs: []error{errTest, errTest, errTest, errTest, errTest}}
+	r := newTestReporter(t, newRegistryWithCounter(), w, WithRetry(10, time.Second), WithRetryBudget(5*time.Second))
+	now := fixedClock(r)
+	var waits []time.Duration
+	r.sleep = func(ctx context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		*now = now.Add(d)
+		return nil
+	}
+
+	if err := r.Flush(context.Background()); err == nil {
+		t.Fatal("got no error once the budget is exhausted")
+	}
+	// the third retry would end 7s after the first attempt
+	if w.attempts != 3 {
+		t.Errorf("got %d attempts, want 3", w.attempts)
+	}
+	if len(waits) != 2 || waits[0] != time.Second || waits[1] != 2*time.Second {
+		t.Errorf("got waits %v, want 1s and 2s", waits)
+	}
+
+	// the budget applies to every batch
+	w.errs = []error{errTest, errTest}
+	waits = nil
+	if err := r.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if w.attempts != 6 || len(waits) != 2 {
+		t.Errorf("got %d attempts and waits %v, want 3 more attempts", w.attempts, waits)
+	}
+}
+
+func TestRetryCanceled(t *testing.T) {
+	w := &flakyWriter{errs: []error{errTest, errTest}}
+	r := newTestReporter(t, newRegistryWithCounter(), w, WithRetry(3, time.Hour))
+	ctx, cancel := context.WithCancel(context.Background())
+	r.sleep = func(ctx context.Context, d time.Duration) error {
+		cancel()
+		return ctx.Err()
+	}
+
+	if err := r.Flush(ctx); err == nil {
+		t.Fatal("got no error for a canceled retry")
+	}
+	if w.attempts != 1 {
+		t.Errorf("got %d attempts, want 1", w.attempts)
+	}
+}
+
+// timeoutWriter stores the batches written to it, but reports the first write as timed out,
+// as when the response of a write stored by InfluxDB is lost.
+type timeoutWriter struct {
+	testWriter
+	attempts int
+}
+
+func (w *timeoutWriter) WriteBatch(ctx context.Context, bp client.BatchPoints) error {
+	w.attempts++
+	if err := w.testWriter.WriteBatch(ctx, bp); err != nil {
+		return err
+	}
+	if w.attempts == 1 {
+		return context.DeadlineExceeded
+	}
+	return nil
+}
+
+func TestRetryAfterSpuriousTimeout(t *testing.T) {
+	w := &timeoutWriter{}
+	r := newTestReporter(t, newRegistryWithCounter(), w, WithRetry(2, time.Second), WithTags(map[string]string{"host": "web1"}))
+	now := fixedClock(r)
+	r.sleep = func(ctx context.Context, d time.Duration) error {
+		*now = now.Add(d)
+		return nil
+	}
+
+	flush(t, r)
+	batches := w.written()
+	if w.attempts != 2 || len(batches) != 2 {
+		t.Fatalf("got %d attempts writing %d batches, want 2", w.attempts, len(batches))
+	}
+	// the retry overwrites the points stored by the first attempt rather than duplicating them
+	first, retried := sortedLines(t, batches[0].Points), sortedLines(t, batches[1].Points)
+	if !reflect.DeepEqual(first, retried) {
+		t.Errorf("got retried points\n%q\nwant those of the first attempt\n%q", retried, first)
+	}
+	for _, p := range batches[1].Points {
+		if !p.Time.Equal(time.Unix(1000, 0)) {
+			t.Errorf("got time %v of retried point %s, want the time of the flush", p.Time, p.Measurement)
+		}
+	}
+}
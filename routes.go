@@ -0,0 +1,97 @@
+package influxdb
+
+import (
+	"path"
+
+	"github.com/influxdata/influxdb/client"
+)
+
+// route sends the metrics whose name matches pattern to a database and retention policy.
+type route struct {
+	pattern         string
+	database        string
+	retentionPolicy string
+}
+
+// flushDatabase returns the database of the batch of a flush: the one returned by the function
+// of WithDatabaseFunc, or the database of the reporter when it returns an empty name.
+func (r *Reporter) flushDatabase() string {
+	if r.databaseFunc == nil {
+		return r.database
+	}
+	if db := r.databaseFunc(); db != "" {
+		return db
+	}
+	return r.database
+}
+
+// routeOf returns the index in r.routes of the first route matching the metric registered under name,
+// or -1 when its points go to the database of the reporter.
+func (r *Reporter) routeOf(name string) int {
+	for i, rt := range r.routes {
+		// the patterns are checked by validate
+		if ok, _ := path.Match(rt.pattern, name); ok {
+			return i
+		}
+	}
+	return -1
+}
+
+// recordRoutes remembers the route of every point of a flush by its series, so that the points
+// kept by the filters applied by send can be sent to their database. routes holds the route
+// of every point of pts.
+func (r *Reporter) recordRoutes(pts []client.Point, routes []int) {
+	if len(r.routes) == 0 || r.peeking {
+		return
+	}
+
+	r.routed = make(map[string]int)
+	for i := range pts {
+		if routes[i] >= 0 {
+			r.routed[seriesKey(pts[i])] = routes[i]
+		}
+	}
+}
+
+// partition splits bp, the batch of a flush, into one batch per database and retention policy, by the routes
+// recorded while its points were built. The batch of the database of the reporter comes first, followed
+// by those of the routes in the order they were added. The points of the reporter itself, such as
+// the heartbeat, aren't routed.
+func (r *Reporter) partition(bp client.BatchPoints) []client.BatchPoints {
+	if len(r.routed) == 0 {
+		return []client.BatchPoints{bp}
+	}
+
+	// routes of the same database and retention policy share a batch
+	batches := []client.BatchPoints{{Database: bp.Database, RetentionPolicy: bp.RetentionPolicy, Precision: bp.Precision}}
+	index := make([]int, len(r.routes))
+	for i, rt := range r.routes {
+		index[i] = -1
+		for j := range batches {
+			if batches[j].Database == rt.database && batches[j].RetentionPolicy == rt.retentionPolicy {
+				index[i] = j
+				break
+			}
+		}
+		if index[i] < 0 {
+			index[i] = len(batches)
+			batches = append(batches, client.BatchPoints{Database: rt.database, RetentionPolicy: rt.retentionPolicy, Precision: bp.Precision})
+		}
+	}
+
+	for _, p := range bp.Points {
+		j := 0
+		if i, ok := r.routed[seriesKey(p)]; ok {
+			j = index[i]
+		}
+		batches[j].Points = append(batches[j].Points, p)
+	}
+
+	res := batches[:0]
+	for _, b := range batches {
+		if len(b.Points) > 0 {
+			res = append(res, b)
+		}
+	}
+	return res
+}
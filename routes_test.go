@@ -0,0 +1,162 @@
+package influxdb
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb/client"
+	"github.com/rcrowley/go-metrics"
+)
+
+// databases returns the database and retention policy of every measurement written to w.
+func databases(w *testWriter) map[string]string {
+	dbs := make(map[string]string)
+	for _, bp := range w.written() {
+		for _, p := range bp.Points {
+			dbs[p.Measurement] = bp.Database + "/" + bp.RetentionPolicy
+		}
+	}
+	return dbs
+}
+
+func TestRoutes(t *testing.T) {
+	reg := metrics.NewRegistry()
+	metrics.GetOrRegisterCounter("billing.invoices", reg).Inc(1)
+	metrics.GetOrRegisterCounter("http.requests", reg).Inc(1)
+	metrics.GetOrRegisterGauge("cpu.user", reg).Update(1)
+	w := &testWriter{}
+	r := newTestReporter(t, reg, w,
+		WithRoute("billing.*", "business", "long"),
+		WithRoute("cpu", "infra", ""),
+		WithGroups("cpu.*"),
+		WithDotReplacement("_"),
+		WithHeartbeat("heartbeat"),
+	)
+	flush(t, r)
+
+	want := map[string]string{
+		"billing_invoices_count": "business/long",
+		"http_requests_count":    "db/",
+		// routed by the name of the group, not its measurement
+		"cpu":       "infra/",
+		"heartbeat": "db/",
+	}
+	got := databases(w)
+	for m, db := range want {
+		if got[m] != db {
+			t.Errorf("measurement %s written to %q, want %q", m, got[m], db)
+		}
+	}
+	if n := len(w.written()); n != 3 {
+		t.Errorf("got %d batches, want 3", n)
+	}
+}
+
+func TestQueuedBatchesKeepTheirDatabase(t *testing.T) {
+	reg := metrics.NewRegistry()
+	metrics.GetOrRegisterCounter("billing.invoices", reg).Inc(1)
+	w := &testWriter{}
+	w.failWith(errors.New("down"))
+	db := "metrics_1"
+	r := newTestReporter(t, reg, w,
+		WithRoute("billing.*", "business", ""),
+		WithDatabaseFunc(func() string { return db }),
+		WithQueue(2, DropOldest),
+	)
+	if err := r.Flush(context.Background()); err == nil {
+		t.Fatal("got no error from a failed write")
+	}
+
+	// the next flush has neither the same database nor the routed metric
+	db = "metrics_2"
+	reg.Unregister("billing.invoices")
+	metrics.GetOrRegisterCounter("http.requests", reg).Inc(1)
+	w.failWith(nil)
+	flush(t, r)
+
+	got := make(map[string][]string)
+	for _, bp := range w.written() {
+		for _, p := range bp.Points {
+			got[p.Measurement] = append(got[p.Measurement], bp.Database)
+		}
+	}
+	if dbs := got["billing.invoices.count"]; len(dbs) != 1 || dbs[0] != "business" {
+		t.Errorf("queued routed point written to %v, want [business]", dbs)
+	}
+	if dbs := got["http.requests.count"]; len(dbs) != 1 || dbs[0] != "metrics_2" {
+		t.Errorf("point written to %v, want [metrics_2]", dbs)
+	}
+}
+
+func TestDatabaseFunc(t *testing.T) {
+	reg := newRegistryWithCounter()
+	w := &testWriter{}
+	now := time.Date(2024, 1, 31, 23, 59, 0, 0, time.UTC)
+	partitioned := true
+	r := newTestReporter(t, reg, w, WithDatabaseFunc(func() string {
+		if !partitioned {
+			return ""
+		}
+		return now.Format("metrics_2006_01")
+	}))
+
+	var got []string
+	for _, step := range []struct {
+		d           time.Duration
+		partitioned bool
+	}{{0, true}, {time.Minute, true}, {0, false}} {
+		now = now.Add(step.d)
+		partitioned = step.partitioned
+		flush(t, r)
+		bp := w.written()
+		got = append(got, bp[len(bp)-1].Database)
+	}
+	// the database of the constructor is used when the function returns an empty name
+	want := []string{"metrics_2024_01", "metrics_2024_02", "db"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got databases %v, want %v", got, want)
+	}
+}
+
+func TestRoutesAsync(t *testing.T) {
+	reg := metrics.NewRegistry()
+	w := &testWriter{delay: time.Millisecond}
+	r, err := New(reg, time.Millisecond, "", "db", "", "", WithWriter(w), WithAsync(), WithQueue(8, DropOldest),
+		WithRoute("billing.*", "business", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go r.Run()
+	waitRunning(r)
+
+	// the routed metrics come and go while the write loop writes the queued flushes
+	for i := 0; i < 20; i++ {
+		c := metrics.GetOrRegisterCounter("billing.invoices", reg)
+		c.Inc(1)
+		if err := r.Flush(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		reg.Unregister("billing.invoices")
+		metrics.GetOrRegisterCounter("http.requests", reg).Inc(1)
+		time.Sleep(time.Millisecond)
+	}
+	r.Stop()
+
+	for _, bp := range w.written() {
+		for _, p := range bp.Points {
+			if want := routedTo(p); bp.Database != want {
+				t.Fatalf("point %s written to %s, want %s", p.Measurement, bp.Database, want)
+			}
+		}
+	}
+}
+
+func routedTo(p client.Point) string {
+	if p.Measurement == "billing.invoices.count" {
+		return "business"
+	}
+	return "db"
+}
@@ -0,0 +1,49 @@
+package influxdb
+
+import (
+	"runtime"
+	"time"
+
+	"github.com/influxdata/influxdb/client"
+	"github.com/rcrowley/go-metrics"
+)
+
+// captureRuntimeStats registers the Go runtime metrics of go-metrics in the registry of the reporter,
+// then captures them at each runtimeInterval until the reporter stops.
+func (r *Reporter) captureRuntimeStats() {
+	metrics.RegisterRuntimeMemStats(r.reg)
+	metrics.CaptureRuntimeMemStatsOnce(r.reg)
+
+	go func() {
+		ticker := time.NewTicker(r.runtimeInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-r.ctx.Done():
+				return
+			case <-ticker.C:
+				metrics.CaptureRuntimeMemStatsOnce(r.reg)
+			}
+		}
+	}()
+}
+
+// DefaultRuntimeInfoMeasurement is the measurement of the runtime point when WithRuntimeInfo is given no name.
+const DefaultRuntimeInfoMeasurement = "go_runtime"
+
+// runtimeInfoPoint returns the runtime point of a flush at time now, see WithRuntimeInfo.
+func (r *Reporter) runtimeInfoPoint(now time.Time) client.Point {
+	return client.Point{
+		Measurement: r.replaceDots(r.runtimeInfo),
+		Tags:        r.flushTags(),
+		Fields: map[string]interface{}{
+			"goroutines": runtime.NumGoroutine(),
+			"num_cpu":    runtime.NumCPU(),
+			"gomaxprocs": runtime.GOMAXPROCS(0),
+			"go_version": runtime.Version(),
+		},
+		Time:      now,
+		Precision: r.precision,
+	}
+}
@@ -0,0 +1,43 @@
+package influxdb
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestRuntimeInfo(t *testing.T) {
+	w := &testWriter{}
+	r := newTestReporter(t, metrics.NewRegistry(), w, WithRuntimeInfo(""))
+	flush(t, r)
+
+	pts := w.find(DefaultRuntimeInfoMeasurement)
+	if len(pts) != 1 {
+		t.Fatalf("got %d runtime points, want 1", len(pts))
+	}
+	fields := pts[0].Fields
+	for _, k := range []string{"goroutines", "num_cpu", "gomaxprocs"} {
+		if n, ok := fields[k].(int); !ok || n < 1 {
+			t.Errorf("got %s %v, want a positive integer", k, fields[k])
+		}
+	}
+	if fields["gomaxprocs"] != runtime.GOMAXPROCS(0) || fields["num_cpu"] != runtime.NumCPU() {
+		t.Errorf("got fields %v, want those of the runtime", fields)
+	}
+	if fields["go_version"] != runtime.Version() {
+		t.Errorf("got go version %v, want %s", fields["go_version"], runtime.Version())
+	}
+
+	// configurations enable the default measurement
+	for name, want := range map[string]string{"": DefaultRuntimeInfoMeasurement, "go": "go"} {
+		r, err := NewFromConfig(Config{Registry: metrics.NewRegistry(), Database: "db", RuntimeInfo: true, RuntimeInfoName: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Stop()
+		if r.runtimeInfo != want {
+			t.Errorf("got runtime measurement %q of name %q, want %q", r.runtimeInfo, name, want)
+		}
+	}
+}
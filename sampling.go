@@ -0,0 +1,37 @@
+package influxdb
+
+import (
+	"hash/fnv"
+	"math"
+)
+
+// sampleRound returns the round of the flush among the rounds of WithSampling, and moves on to the next
+// round unless peeking.
+func (r *Reporter) sampleRound() uint32 {
+	if r.sampleRounds == 0 {
+		return 0
+	}
+	round := r.sampleNext
+	if !r.peeking {
+		r.sampleNext = (r.sampleNext + 1) % r.sampleRounds
+	}
+	return round
+}
+
+// sampled reports whether the metric identified by id is reported by the flush of the given round.
+// Every metric belongs to the round given by the hash of its id, so that each is reported once
+// every r.sampleRounds flushes.
+func (r *Reporter) sampled(id string, round uint32) bool {
+	if r.sampleRounds <= 1 {
+		return true
+	}
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	return h.Sum32()%r.sampleRounds == round
+}
+
+// sampleRounds returns the number of flushes needed to report every metric with a sample of fraction
+// of the metrics per flush.
+func sampleRounds(fraction float64) uint32 {
+	return uint32(math.Ceil(1 / fraction))
+}
@@ -0,0 +1,71 @@
+package influxdb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestSampling(t *testing.T) {
+	reg := newMixedRegistry(40)
+	w := &testWriter{}
+	r := newTestReporter(t, reg, w, WithSampling(0.3))
+	if r.sampleRounds != 4 {
+		t.Fatalf("got %d rounds, want 4", r.sampleRounds)
+	}
+
+	// a snapshot doesn't move on to the next round
+	r.Snapshot()
+
+	seen := make(map[string]int)
+	for i := 0; i < 8; i++ {
+		w.batches = nil
+		flush(t, r)
+		pts := w.points()
+		if len(pts) == 0 || len(pts) == 40 {
+			t.Errorf("flush %d: got %d points, want a part of the metrics", i, len(pts))
+		}
+		for _, p := range pts {
+			seen[p.Measurement]++
+		}
+	}
+	// every metric is reported once every 4 flushes
+	if len(seen) != 40 {
+		t.Errorf("got %d metrics reported, want 40", len(seen))
+	}
+	for m, n := range seen {
+		if n != 2 {
+			t.Errorf("metric %s reported %d times in 8 flushes, want 2", m, n)
+		}
+	}
+}
+
+func TestSamplingDeltas(t *testing.T) {
+	reg := metrics.NewRegistry()
+	c := metrics.GetOrRegisterCounter("requests", reg)
+	w := &testWriter{}
+	r := newTestReporter(t, reg, w, WithSampling(0.5), WithDeltas(TypeCounter))
+
+	// the delta is the one since the previous report of the counter, two flushes earlier
+	var deltas []interface{}
+	for i := 0; i < 4; i++ {
+		c.Inc(1)
+		w.batches = nil
+		flush(t, r)
+		if pts := w.find("requests.count"); len(pts) == 1 {
+			deltas = append(deltas, pts[0].Fields["delta"])
+		}
+	}
+	if len(deltas) != 2 || deltas[1] != int64(2) {
+		t.Errorf("got deltas %v, want 2 reports with a delta of 2 for the second one", deltas)
+	}
+}
+
+func TestSamplingFraction(t *testing.T) {
+	for _, f := range []float64{-0.5, 1.5} {
+		if _, err := BuildPoints(metrics.NewRegistry(), nil, time.Now(), WithSampling(f)); err == nil {
+			t.Errorf("got no error for fraction %v", f)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package influxdb
+
+import (
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+// selfMetricsPrefix starts the names of the metrics the reporter keeps about itself.
+const selfMetricsPrefix = "influxdb.reporter."
+
+// selfMetricName returns the name of a metric about the reporter, including its name if it has one.
+func (r *Reporter) selfMetricName(name string) string {
+	if r.name != "" {
+		return selfMetricsPrefix + r.name + "." + name
+	}
+	return selfMetricsPrefix + name
+}
+
+// selfCounter returns the counter about the reporter with the given name, registered in the
+// registry given to the constructor when self metrics are enabled.
+func (r *Reporter) selfCounter(name string) metrics.Counter {
+	if !r.selfMetrics {
+		return metrics.NilCounter{}
+	}
+	return metrics.GetOrRegisterCounter(r.selfMetricName(name), r.reg)
+}
+
+// registerWriteTimer registers the timer of WithWriteTimer in the registry given to the constructor.
+func (r *Reporter) registerWriteTimer() {
+	name := r.writeTimerName
+	if name == "" {
+		name = r.selfMetricName("write_duration")
+	}
+	r.writeTimer = metrics.GetOrRegisterTimer(name, r.reg)
+}
+
+// timeWrite records in the timer of WithWriteTimer the duration of a write started at start.
+// The timer is reported by the next flush like any other metric, updating it doesn't trigger a write.
+func (r *Reporter) timeWrite(start time.Time) {
+	if r.writeTimer != nil {
+		r.writeTimer.Update(r.now().Sub(start))
+	}
+}
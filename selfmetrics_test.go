@@ -0,0 +1,47 @@
+package influxdb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestWriteTimer(t *testing.T) {
+	reg := newRegistryWithCounter()
+	w := &testWriter{}
+	r := newTestReporter(t, reg, w, WithWriteTimer(""))
+	now := fixedClock(r)
+
+	for i := 0; i < 3; i++ {
+		*now = now.Add(time.Second)
+		flush(t, r)
+	}
+
+	timer, ok := reg.Get("influxdb.reporter.write_duration").(metrics.Timer)
+	if !ok {
+		t.Fatal("write timer not registered")
+	}
+	if n := timer.Count(); n != 3 {
+		t.Errorf("got %d writes timed, want 3", n)
+	}
+	// the timer is written by the flushes following its registration
+	pts := w.find("influxdb.reporter.write_duration.timer")
+	if len(pts) != 3 || pts[2].Fields["count"] != int64(2) {
+		t.Errorf("got write timer points %v, want counts up to 2", pts)
+	}
+}
+
+func TestSelfMetrics(t *testing.T) {
+	reg := metrics.NewRegistry()
+	w := &testWriter{}
+	r := newTestReporter(t, reg, w, WithName("api"), WithSelfMetrics(), WithQueue(1, DropNewest))
+	w.failWith(errTest)
+	r.send(nil)
+	r.send(nil)
+
+	c, ok := reg.Get("influxdb.reporter.api.dropped_batches").(metrics.Counter)
+	if !ok || c.Count() != 1 {
+		t.Errorf("got dropped batches counter %v, want a count of 1", c)
+	}
+}
@@ -0,0 +1,99 @@
+package influxdb
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/influxdata/influxdb/client"
+)
+
+// DuplicatePolicy selects what happens to the points of a batch sharing their measurement and tags.
+// InfluxDB keeps only the last of several points with the same series and timestamp.
+type DuplicatePolicy string
+
+const (
+	// DuplicateIgnore writes duplicate points unchanged. This is the default.
+	DuplicateIgnore DuplicatePolicy = "ignore"
+	// DuplicateWarn logs every duplicate point.
+	DuplicateWarn DuplicatePolicy = "warn"
+	// DuplicateOffset logs every duplicate point and moves it forward by one nanosecond per
+	// preceding duplicate so that none is overwritten. This only helps with nanosecond precision.
+	DuplicateOffset DuplicatePolicy = "offset"
+	// DuplicateMerge merges the fields of duplicate points with the same timestamp into the first
+	// of them. A field present in several points with different values is logged as a conflict
+	// and keeps the value of the last point, like InfluxDB would.
+	DuplicateMerge DuplicatePolicy = "merge"
+)
+
+// seriesKey identifies the series of a point: its measurement and tag set.
+func seriesKey(p client.Point) string {
+	keys := make([]string, 0, len(p.Tags))
+	for k := range p.Tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(p.Measurement)
+	for _, k := range keys {
+		b.WriteByte(0)
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(p.Tags[k])
+	}
+	return b.String()
+}
+
+// checkDuplicates applies the duplicate policy to the points of a batch and returns them.
+func (r *Reporter) checkDuplicates(pts []client.Point) []client.Point {
+	switch r.duplicates {
+	case "", DuplicateIgnore:
+		return pts
+	case DuplicateMerge:
+		return r.mergeDuplicates(pts)
+	}
+
+	seen := make(map[string]int, len(pts))
+	for i := range pts {
+		key := seriesKey(pts[i])
+		n := seen[key]
+		seen[key] = n + 1
+		if n == 0 {
+			continue
+		}
+
+		r.warnf("point for measurement %s duplicates the series of a previous point in the batch, use distinct tags to keep both", pts[i].Measurement)
+		if r.duplicates == DuplicateOffset {
+			pts[i].Time = pts[i].Time.Add(time.Duration(n))
+		}
+	}
+
+	return pts
+}
+
+// mergeDuplicates merges the fields of the points sharing their series and timestamp into the first of them.
+func (r *Reporter) mergeDuplicates(pts []client.Point) []client.Point {
+	first := make(map[string]int, len(pts))
+	merged := pts[:0]
+	for _, p := range pts {
+		key := seriesKey(p) + "\x00" + strconv.FormatInt(p.Time.UnixNano(), 10)
+		i, ok := first[key]
+		if !ok {
+			first[key] = len(merged)
+			merged = append(merged, p)
+			continue
+		}
+
+		fields := merged[i].Fields
+		for k, v := range p.Fields {
+			if prev, ok := fields[k]; ok && prev != v {
+				r.warnf("conflicting values %v and %v for field %s of merged points of measurement %s, keeping the last one", prev, v, k, p.Measurement)
+			}
+			fields[k] = v
+		}
+	}
+
+	return merged
+}
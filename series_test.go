@@ -0,0 +1,89 @@
+package influxdb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb/client"
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestMergeDuplicates(t *testing.T) {
+	now := time.Unix(1, 0)
+	l := &testLogger{}
+	r := newTestReporter(t, metrics.NewRegistry(), &testWriter{}, WithDuplicatePolicy(DuplicateMerge), WithLogger(l))
+	pts := []client.Point{
+		{Measurement: "cpu", Tags: map[string]string{"host": "a"}, Fields: map[string]interface{}{"user": 1.0}, Time: now},
+		{Measurement: "cpu", Tags: map[string]string{"host": "b"}, Fields: map[string]interface{}{"user": 2.0}, Time: now},
+		{Measurement: "cpu", Tags: map[string]string{"host": "a"}, Fields: map[string]interface{}{"system": 3.0}, Time: now},
+		{Measurement: "cpu", Tags: map[string]string{"host": "a"}, Fields: map[string]interface{}{"user": 1.0}, Time: now.Add(time.Second)},
+		{Measurement: "mem", Tags: map[string]string{"host": "a"}, Fields: map[string]interface{}{"used": 4.0}, Time: now},
+	}
+
+	got := sortedLines(t, r.checkDuplicates(pts))
+	want := []string{
+		"cpu,host=a system=3,user=1 1000000000",
+		"cpu,host=a user=1 2000000000",
+		"cpu,host=b user=2 1000000000",
+		"mem,host=a used=4 1000000000",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got lines %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("got lines %q, want %q", got, want)
+			break
+		}
+	}
+	if msgs := l.messages(); len(msgs) != 0 {
+		t.Errorf("got messages %q merging distinct fields", msgs)
+	}
+
+	// the last value of a conflicting field is kept and the conflict logged
+	pts = []client.Point{
+		{Measurement: "cpu", Fields: map[string]interface{}{"user": 1.0}, Time: now},
+		{Measurement: "cpu", Fields: map[string]interface{}{"user": 2.0, "system": 3.0}, Time: now},
+		{Measurement: "cpu", Fields: map[string]interface{}{"user": 2.0}, Time: now},
+	}
+	merged := r.checkDuplicates(pts)
+	if len(merged) != 1 || merged[0].Fields["user"] != 2.0 || merged[0].Fields["system"] != 3.0 {
+		t.Errorf("got points %v, want a single point with the last values", merged)
+	}
+	if msgs := l.messages(); len(msgs) != 1 {
+		t.Errorf("got messages %q, want the conflict logged once", msgs)
+	}
+}
+
+func TestDuplicatePolicies(t *testing.T) {
+	reg := metrics.NewRegistry()
+	metrics.GetOrRegisterCounter("a", reg).Inc(1)
+	metrics.GetOrRegisterCounter("b", reg).Inc(2)
+	template := WithMeasurementTemplate(TypeCounter, "requests")
+
+	for _, tt := range []struct {
+		policy DuplicatePolicy
+		points int
+	}{
+		{DuplicateIgnore, 2},
+		{DuplicateWarn, 2},
+		{DuplicateOffset, 2},
+		{DuplicateMerge, 1},
+	} {
+		l := &testLogger{}
+		w := &testWriter{}
+		r := newTestReporter(t, reg, w, template, WithDuplicatePolicy(tt.policy), WithLogger(l))
+		flush(t, r)
+		pts := w.points()
+		if len(pts) != tt.points {
+			t.Errorf("%s: got points %v, want %d", tt.policy, pts, tt.points)
+			continue
+		}
+		if logged := len(l.messages()) > 0; logged != (tt.policy != DuplicateIgnore) {
+			t.Errorf("%s: got messages %q", tt.policy, l.messages())
+		}
+		if tt.policy == DuplicateOffset && pts[0].Time.Equal(pts[1].Time) {
+			t.Errorf("%s: got points at the same time", tt.policy)
+		}
+	}
+}
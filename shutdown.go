@@ -0,0 +1,87 @@
+package influxdb
+
+import (
+	"context"
+	"time"
+)
+
+// Flush writes the current metrics, along with the batches still queued, and waits for the write
+// to complete or ctx to be done. Unlike the periodic flushes, the write happens before Flush returns
+// even in async mode, where the write loop writes the batches while Flush waits for it. It is safe to call
+// while the reporter runs: the flush then happens on the goroutine of Run, between two periodic flushes.
+func (r *Reporter) Flush(ctx context.Context) error {
+	var err error
+	if derr := r.do(ctx, func() {
+		err = r.send(ctx)
+		r.setWriteResult(err)
+	}); derr != nil {
+		return derr
+	}
+	return err
+}
+
+// do calls fn on the goroutine of Run, which owns the state kept between flushes, and waits for it
+// to return or ctx to be done. fn is called directly when the reporter isn't running.
+func (r *Reporter) do(ctx context.Context, fn func()) error {
+	r.mu.Lock()
+	running := r.running
+	r.mu.Unlock()
+	if !running {
+		fn()
+		return nil
+	}
+
+	done := make(chan struct{})
+	select {
+	case r.tasks <- func() { fn(); close(done) }:
+	case <-r.done:
+		// Run returned, the state of the reporter is no longer used by another goroutine
+		fn()
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Shutdown flushes the metrics, waiting at most timeout for the write, then stops the reporter.
+// It returns the error of the flush. A timeout of 0 or less doesn't bound the flush.
+func (r *Reporter) Shutdown(timeout time.Duration) error {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	err := r.Flush(ctx)
+	r.Stop()
+	return err
+}
+
+// RegisterShutdownFlush calls Shutdown with timeout when ctx is done, so that the last metrics are
+// written before the process exits. The reporter doesn't install any signal handler itself, ctx is
+// typically returned by signal.NotifyContext for SIGTERM and SIGINT. SIGKILL can't be caught,
+// the metrics since the last flush are lost then.
+//
+// The returned channel receives the error of the final flush and is then closed, so that the process
+// can wait for it before exiting. It is closed without a flush if the reporter is stopped first.
+func (r *Reporter) RegisterShutdownFlush(ctx context.Context, timeout time.Duration) <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		defer close(done)
+
+		select {
+		case <-ctx.Done():
+			done <- r.Shutdown(timeout)
+		case <-r.ctx.Done():
+		}
+	}()
+	return done
+}
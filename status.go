@@ -0,0 +1,54 @@
+package influxdb
+
+import "time"
+
+// LastWriteTime returns the time of the last successful write, or the zero time if there was none yet.
+func (r *Reporter) LastWriteTime() time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.lastWrite
+}
+
+// LastWriteError returns the error of the last write, or nil if it succeeded.
+func (r *Reporter) LastWriteError() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.lastErr
+}
+
+// ServerVersion returns the InfluxDB version reported by the last successful ping.
+// It is empty until then, and when the reporter uses a custom Writer.
+func (r *Reporter) ServerVersion() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.serverVersion
+}
+
+// DroppedBatches returns the number of batches dropped because the write queue was full, see WithQueue.
+func (r *Reporter) DroppedBatches() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.dropped
+}
+
+func (r *Reporter) countDrop() {
+	r.mu.Lock()
+	r.dropped++
+	r.mu.Unlock()
+	r.selfCounter("dropped_batches").Inc(1)
+}
+
+func (r *Reporter) setWriteResult(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lastErr = err
+	if err == nil {
+		r.lastWrite = r.now()
+	}
+}
+
+func (r *Reporter) setServerVersion(version string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.serverVersion = version
+}
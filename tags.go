@@ -0,0 +1,147 @@
+package influxdb
+
+import (
+	"path"
+
+	"github.com/influxdata/influxdb/client"
+)
+
+// metricTags are the tags added to the points of the metrics whose name matches pattern.
+type metricTags struct {
+	pattern string
+	tags    map[string]string
+}
+
+// copyTags returns a copy of tags, so that the reporter never shares a map with its caller.
+func copyTags(tags map[string]string) map[string]string {
+	if tags == nil {
+		return nil
+	}
+	copied := make(map[string]string, len(tags))
+	for k, v := range tags {
+		copied[k] = v
+	}
+	return copied
+}
+
+// flushTags returns the tags of every point of a flush: the tags of the reporter
+// and the interval tag of WithIntervalTag.
+func (r *Reporter) flushTags() map[string]string {
+	tags := r.getTags()
+	if r.intervalTag == "" && r.sourceTag == "" {
+		return tags
+	}
+
+	merged := make(map[string]string, len(tags)+2)
+	for k, v := range tags {
+		merged[k] = v
+	}
+	if r.intervalTag != "" {
+		merged[r.intervalTag] = r.getInterval().String()
+	}
+	if r.sourceTag != "" {
+		merged[r.sourceTag] = r.sourceValue
+	}
+	return merged
+}
+
+// typeTagsFor returns tags merged with the tags of metric type t, see WithTypeTags,
+// and with the interval of the type when set by WithTypeInterval.
+// tags is returned as is when t has no tags.
+func (r *Reporter) typeTagsFor(t MetricType, tags map[string]string) map[string]string {
+	d := r.typeIntervals[t]
+	if len(r.typeTags[t]) == 0 && (r.intervalTag == "" || d <= 0) {
+		return tags
+	}
+
+	merged := make(map[string]string, len(tags)+len(r.typeTags[t]))
+	for k, v := range tags {
+		merged[k] = v
+	}
+	for k, v := range r.typeTags[t] {
+		merged[k] = v
+	}
+	if r.intervalTag != "" && d > 0 {
+		// the metrics of the type are reported at their own interval
+		merged[r.intervalTag] = d.String()
+	}
+	if r.registryTag != "" {
+		merged[r.registryTag] = tags[r.registryTag]
+	}
+	return merged
+}
+
+// tagsFor returns tags merged with the tags of the patterns matching the metric name,
+// in the order the patterns were added. tags is returned as is when no pattern matches.
+func (r *Reporter) tagsFor(name string, tags map[string]string) map[string]string {
+	merged := tags
+	copied := false
+	for _, mt := range r.metricTags {
+		// the patterns are checked by validate
+		if ok, _ := path.Match(mt.pattern, name); !ok {
+			continue
+		}
+		if !copied {
+			merged = make(map[string]string, len(tags)+len(mt.tags))
+			for k, v := range tags {
+				merged[k] = v
+			}
+			copied = true
+		}
+		for k, v := range mt.tags {
+			merged[k] = v
+		}
+	}
+	if copied && r.registryTag != "" {
+		merged[r.registryTag] = tags[r.registryTag]
+	}
+	return merged
+}
+
+// filterTags removes from the points the tags whose key isn't allowed by WithAllowedTagKeys.
+// The tag maps are shared between points, so they are copied rather than modified.
+// Every removed key is logged once.
+func (r *Reporter) filterTags(pts []client.Point) {
+	if r.allowedTags == nil {
+		return
+	}
+
+	for i := range pts {
+		var filtered map[string]string
+		for k := range pts[i].Tags {
+			if r.tagAllowed(k) {
+				continue
+			}
+			if filtered == nil {
+				filtered = make(map[string]string, len(pts[i].Tags))
+				for k, v := range pts[i].Tags {
+					if r.tagAllowed(k) {
+						filtered[k] = v
+					}
+				}
+			}
+			if r.peeking {
+				continue
+			}
+			r.selfCounter("dropped_tags").Inc(1)
+			if !r.loggedTags[k] {
+				r.loggedTags[k] = true
+				r.logf("dropping tag %s of measurement %s, its key isn't allowed", k, pts[i].Measurement)
+			}
+		}
+		if filtered != nil {
+			pts[i].Tags = filtered
+		}
+	}
+}
+
+// tagAllowed reports whether the tag key k is allowed. The tags added by the reporter itself,
+// such as the one of WithRegistryTag, WithIntervalTag, WithSourceTag, the metric name of the narrow layout or
+// the quantile of WithQuantileSeries, are always allowed. The metric name of the annotation points is added
+// once their tags are filtered, so that a tag of the metrics named like it stays subject to the allowlist.
+func (r *Reporter) tagAllowed(k string) bool {
+	if r.allowedTags[k] || k != "" && (k == r.registryTag || k == r.intervalTag || k == r.sourceTag) {
+		return true
+	}
+	return r.layout == LayoutNarrow && k == MetricTag || r.quantileSeries && k == QuantileTag
+}
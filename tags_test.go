@@ -0,0 +1,187 @@
+package influxdb
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+// TestTagsAreCopied mutates the maps given to the reporter and the registry while it flushes,
+// for the race detector to catch the unsynchronized accesses.
+func TestTagsAreCopied(t *testing.T) {
+	reg := newRegistryWithCounter()
+	tags := map[string]string{"host": "web1"}
+	w := &testWriter{}
+	r := newTestReporter(t, reg, w, WithTags(tags))
+
+	set := map[string]string{"host": "web2"}
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			tags["host"] = "changed" + strconv.Itoa(i)
+			tags["extra"+strconv.Itoa(i)] = "x"
+			if i == 50 {
+				r.SetTags(set)
+			}
+			if i > 50 {
+				set["host"] = "changed"
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			name := "metric." + strconv.Itoa(i)
+			metrics.GetOrRegisterCounter(name, reg).Inc(1)
+			if i%2 == 0 {
+				reg.Unregister(name)
+			}
+		}
+	}()
+	for i := 0; i < 20; i++ {
+		if err := r.Flush(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+	flush(t, r)
+
+	for _, p := range w.points() {
+		if host := p.Tags["host"]; host != "web1" && host != "web2" {
+			t.Fatalf("got tags %v of %s, want those given to the reporter", p.Tags, p.Measurement)
+		}
+		if len(p.Tags) != 1 {
+			t.Fatalf("got tags %v of %s, want the host only", p.Tags, p.Measurement)
+		}
+	}
+	pts := w.find("requests.count")
+	if last := pts[len(pts)-1]; last.Tags["host"] != "web2" {
+		t.Errorf("got tags %v after SetTags, want host web2", last.Tags)
+	}
+}
+
+func TestTypeTags(t *testing.T) {
+	reg := metrics.NewRegistry()
+	metrics.GetOrRegisterTimer("api.latency", reg).Update(1)
+	metrics.GetOrRegisterTimer("db.latency", reg).Update(1)
+	metrics.GetOrRegisterCounter("api.requests", reg).Inc(1)
+
+	pts, err := BuildPoints(reg, map[string]string{"host": "web1", "unit": "none"}, time.Now(),
+		WithTypeTags(TypeTimer, map[string]string{"unit": "ns"}),
+		WithTypeTags(TypeTimer, map[string]string{"kind": "latency"}),
+		WithMetricTags("db.*", map[string]string{"unit": "us"}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]map[string]string{
+		"api.latency.timer": {"host": "web1", "unit": "ns", "kind": "latency"},
+		// the tags of the metric override those of its type
+		"db.latency.timer":   {"host": "web1", "unit": "us", "kind": "latency"},
+		"api.requests.count": {"host": "web1", "unit": "none"},
+	}
+	if len(pts) != len(want) {
+		t.Fatalf("got points %v, want %d", pts, len(want))
+	}
+	for _, p := range pts {
+		if !reflect.DeepEqual(p.Tags, want[p.Measurement]) {
+			t.Errorf("got tags %v of %s, want %v", p.Tags, p.Measurement, want[p.Measurement])
+		}
+	}
+
+	// the registry tag overrides them all
+	pts, err = BuildPoints(reg, nil, time.Now(), WithTypeTags(TypeCounter, map[string]string{"registry": "x"}), WithRegistryTag("registry"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range pts {
+		if p.Tags["registry"] != defaultRegistryName {
+			t.Errorf("got tags %v of %s, want the registry tag", p.Tags, p.Measurement)
+		}
+	}
+}
+
+func TestIntervalTag(t *testing.T) {
+	reg := newRegistryWithCounter()
+	metrics.GetOrRegisterTimer("latency", reg).Update(1)
+
+	for _, tt := range []struct {
+		interval time.Duration
+		key      string
+		opts     []Option
+		want     map[string]string
+	}{
+		{10 * time.Second, "", nil, map[string]string{"requests.count": "10s", "latency.timer": "10s"}},
+		{time.Minute, "period", nil, map[string]string{"requests.count": "1m0s", "latency.timer": "1m0s"}},
+		{10 * time.Second, "", []Option{WithTypeInterval(TypeTimer, time.Minute)}, map[string]string{"requests.count": "10s", "latency.timer": "1m0s"}},
+	} {
+		w := &testWriter{}
+		opts := append([]Option{WithWriter(w), WithLogger(&testLogger{}), WithIntervalTag(tt.key)}, tt.opts...)
+		r, err := New(reg, tt.interval, "", "db", "", "", opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		flush(t, r)
+		r.Stop()
+
+		key := tt.key
+		if key == "" {
+			key = "interval"
+		}
+		for m, want := range tt.want {
+			pts := w.find(m)
+			if len(pts) != 1 || pts[0].Tags[key] != want {
+				t.Errorf("interval %v: got points %v of %s, want tag %s=%s", tt.interval, pts, m, key, want)
+			}
+		}
+	}
+}
+
+func TestSourceTag(t *testing.T) {
+	reg := newRegistryWithCounter()
+	metrics.GetOrRegisterTimer("latency", reg).Update(1)
+
+	for _, tt := range []struct {
+		key  string
+		want string
+		opts []Option
+	}{
+		{"", "exporter", nil},
+		{"source", "source", nil},
+		{"", "exporter", []Option{WithAllowedTagKeys("host")}},
+	} {
+		w := &testWriter{}
+		r := newTestReporter(t, reg, w, append([]Option{WithSourceTag(tt.key)}, tt.opts...)...)
+		flush(t, r)
+		r.Stop()
+
+		pts := w.points()
+		if len(pts) != 2 {
+			t.Fatalf("got %d points, want 2", len(pts))
+		}
+		for _, p := range pts {
+			v := p.Tags[tt.want]
+			version := strings.TrimPrefix(v, libraryName+"/")
+			if version == v || version == "" || v != sourceTagValue() {
+				t.Errorf("got tags %v of %s, want %s=%s/<version>", p.Tags, p.Measurement, tt.want, libraryName)
+			}
+		}
+	}
+
+	// the tag is opt-in
+	w := &testWriter{}
+	flush(t, newTestReporter(t, reg, w))
+	for _, p := range w.points() {
+		if _, ok := p.Tags["exporter"]; ok {
+			t.Errorf("got tags %v of %s without WithSourceTag", p.Tags, p.Measurement)
+		}
+	}
+}
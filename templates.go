@@ -0,0 +1,46 @@
+package influxdb
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// templatePlaceholders are the placeholders of the measurement templates, see WithMeasurementTemplate.
+var templatePlaceholders = map[string]bool{
+	"{name}":   true,
+	"{type}":   true,
+	"{suffix}": true,
+}
+
+// validateTemplate checks that tmpl isn't empty and only holds known placeholders.
+func validateTemplate(tmpl string) error {
+	if tmpl == "" {
+		return errors.New("empty measurement template")
+	}
+
+	rest := tmpl
+	for {
+		i := strings.IndexAny(rest, "{}")
+		if i < 0 {
+			return nil
+		}
+		j := strings.IndexByte(rest[i:], '}')
+		if rest[i] == '}' || j < 0 {
+			return fmt.Errorf("unbalanced braces in measurement template %q", tmpl)
+		}
+		if p := rest[i : i+j+1]; !templatePlaceholders[p] {
+			return fmt.Errorf("unknown placeholder %s in measurement template %q", p, tmpl)
+		}
+		rest = rest[i+j+1:]
+	}
+}
+
+// expandTemplate returns the measurement of the metric name of type t formed by tmpl.
+func expandTemplate(tmpl, name string, t MetricType) string {
+	return strings.NewReplacer(
+		"{name}", name,
+		"{type}", string(t),
+		"{suffix}", suffixes[t],
+	).Replace(tmpl)
+}
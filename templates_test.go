@@ -0,0 +1,48 @@
+package influxdb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestMeasurementTemplates(t *testing.T) {
+	reg := metrics.NewRegistry()
+	metrics.GetOrRegisterCounter("api.requests", reg).Inc(1)
+	metrics.GetOrRegisterTimer("api.latency", reg).Update(1)
+
+	for _, tt := range []struct {
+		name string
+		opts []Option
+		want map[string]bool
+	}{
+		{"default", nil, map[string]bool{"api.requests.count": true, "api.latency.timer": true}},
+		{"prefix", []Option{WithMeasurementTemplate(TypeCounter, "app_{type}_{name}")}, map[string]bool{"app_counter_api.requests": true, "api.latency.timer": true}},
+		{"default shape", []Option{WithMeasurementTemplate(TypeTimer, "{name}.{suffix}")}, map[string]bool{"api.requests.count": true, "api.latency.timer": true}},
+		{"name only", []Option{WithMeasurementTemplate(TypeCounter, "{name}"), WithMeasurementTemplate(TypeTimer, "{name}")}, map[string]bool{"api.requests": true, "api.latency": true}},
+		{"constant", []Option{WithMeasurementTemplate(TypeTimer, "timers")}, map[string]bool{"api.requests.count": true, "timers": true}},
+	} {
+		pts, err := BuildPoints(reg, nil, time.Now(), tt.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pts) != len(tt.want) {
+			t.Errorf("%s: got points %v, want %d", tt.name, pts, len(tt.want))
+		}
+		for _, p := range pts {
+			if !tt.want[p.Measurement] {
+				t.Errorf("%s: got measurement %s, want one of %v", tt.name, p.Measurement, tt.want)
+			}
+		}
+	}
+
+	for _, tmpl := range []string{"", "{name", "name}", "{metric}", "{name}.{}", "}{"} {
+		if _, err := BuildPoints(reg, nil, time.Now(), WithMeasurementTemplate(TypeCounter, tmpl)); err == nil {
+			t.Errorf("got no error for template %q", tmpl)
+		}
+	}
+	if _, err := BuildPoints(reg, nil, time.Now(), WithMeasurementTemplate("sample", "{name}")); err == nil {
+		t.Error("got no error for the template of an unknown type")
+	}
+}
@@ -0,0 +1,40 @@
+package influxdb
+
+import (
+	"time"
+
+	"github.com/influxdata/influxdb/client"
+)
+
+// totalSuffix is appended to the metric name to form the measurement of the total series of
+// WithTotalSeries, after the Prometheus convention for counters.
+const totalSuffix = "_total"
+
+// totalPoint returns the point of the total series of the metric registered under name with type t
+// and fields, see WithTotalSeries: its cumulative count in a "value" field, read from the "value" field
+// of counters and the "count" field of the other types. ok is false when there is none.
+func (r *Reporter) totalPoint(name string, t MetricType, fields map[string]interface{}, tags map[string]string, now time.Time) (p client.Point, ok bool) {
+	if !r.totalSeries {
+		return p, false
+	}
+	field := "count"
+	switch t {
+	case TypeCounter:
+		field = "value"
+	case TypeMeter, TypeTimer, TypeHistogram:
+	default:
+		return p, false
+	}
+	count, ok := fields[field]
+	if !ok {
+		return p, false
+	}
+
+	return client.Point{
+		Measurement: r.replaceDots(r.metricName(name) + totalSuffix),
+		Tags:        tags,
+		Fields:      map[string]interface{}{"value": count},
+		Time:        now,
+		Precision:   r.precisionFor(t),
+	}, true
+}
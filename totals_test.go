@@ -0,0 +1,91 @@
+package influxdb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb/client"
+	"github.com/rcrowley/go-metrics"
+)
+
+// totalOf returns the value of the total series of measurement m in pts, and whether there is one.
+func totalOf(pts []client.Point, m string) (interface{}, bool) {
+	for _, p := range pts {
+		if p.Measurement == m {
+			return p.Fields["value"], true
+		}
+	}
+	return nil, false
+}
+
+func TestTotalSeries(t *testing.T) {
+	reg := metrics.NewRegistry()
+	metrics.GetOrRegisterCounter("requests", reg).Inc(3)
+	metrics.GetOrRegisterMeter("events", reg).Mark(4)
+	metrics.GetOrRegisterTimer("latency", reg).Update(time.Millisecond)
+	metrics.GetOrRegisterGauge("goroutines", reg).Update(10)
+
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{"wide", nil},
+		{"narrow", []Option{WithLayout(LayoutNarrow)}},
+		{"renamed", []Option{WithFieldName(TypeCounter, "value", "n"), WithFieldName(TypeMeter, "count", "n")}},
+		{"quantile series", []Option{WithQuantileSeries()}},
+	}
+	for _, tt := range tests {
+		pts, err := BuildPoints(reg, nil, time.Now(), append([]Option{WithTotalSeries()}, tt.opts...)...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for m, want := range map[string]int64{"requests_total": 3, "events_total": 4, "latency_total": 1} {
+			if got, ok := totalOf(pts, m); !ok || got != want {
+				t.Errorf("%s: got total %v of %s, want %d", tt.name, got, m, want)
+			}
+		}
+		if _, ok := totalOf(pts, "goroutines_total"); ok {
+			t.Errorf("%s: got a total series of a gauge", tt.name)
+		}
+	}
+
+	pts, err := BuildPoints(reg, nil, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := totalOf(pts, "requests_total"); ok {
+		t.Error("got a total series by default")
+	}
+}
+
+func TestTotalSeriesWithGroups(t *testing.T) {
+	reg := metrics.NewRegistry()
+	metrics.GetOrRegisterCounter("http.get", reg).Inc(1)
+	metrics.GetOrRegisterCounter("http.post", reg).Inc(2)
+
+	pts, err := BuildPoints(reg, nil, time.Now(), WithTotalSeries(), WithGroups("http.*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pts) != 3 {
+		t.Fatalf("got points %v, want the group and two total series", pts)
+	}
+	grouped := false
+	for _, p := range pts {
+		if p.Measurement != "http" {
+			continue
+		}
+		grouped = true
+		if len(p.Fields) != 2 || p.Fields["get"] != int64(1) || p.Fields["post"] != int64(2) {
+			t.Errorf("got fields %v of the group, want get and post", p.Fields)
+		}
+	}
+	if !grouped {
+		t.Errorf("got points %v, want the group http", pts)
+	}
+	for m, want := range map[string]int64{"http.get_total": 1, "http.post_total": 2} {
+		if got, ok := totalOf(pts, m); !ok || got != want {
+			t.Errorf("got total %v of %s, want %d", got, m, want)
+		}
+	}
+}
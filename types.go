@@ -0,0 +1,24 @@
+package influxdb
+
+// MetricType identifies a kind of go-metrics metric.
+type MetricType string
+
+// The metric types handled by the reporter.
+const (
+	TypeCounter      MetricType = "counter"
+	TypeGauge        MetricType = "gauge"
+	TypeGaugeFloat64 MetricType = "gaugefloat64"
+	TypeHistogram    MetricType = "histogram"
+	TypeMeter        MetricType = "meter"
+	TypeTimer        MetricType = "timer"
+)
+
+// suffixes are appended to the metric names to form the measurement names.
+var suffixes = map[MetricType]string{
+	TypeCounter:      "count",
+	TypeGauge:        "gauge",
+	TypeGaugeFloat64: "gauge",
+	TypeHistogram:    "histogram",
+	TypeMeter:        "meter",
+	TypeTimer:        "timer",
+}
@@ -0,0 +1,152 @@
+package influxdb
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/influxdata/influxdb/client"
+)
+
+var errWALFull = errors.New("write-ahead log is full")
+
+// wal is an append-only file keeping the batches that failed to be written, so that they survive
+// a restart. The batches are replayed at startup and after the next successful write.
+type wal struct {
+	path    string
+	maxSize int64
+	logf    func(format string, v ...interface{})
+	floats  FloatFormat
+
+	// pending is set when the log may hold batches.
+	pending bool
+}
+
+// walRecord is a batch as stored in the log, one JSON document per line.
+type walRecord struct {
+	Database        string   `json:"db"`
+	RetentionPolicy string   `json:"rp,omitempty"`
+	Precision       string   `json:"precision,omitempty"`
+	Lines           []string `json:"lines"`
+}
+
+func newWAL(path string, maxSize int64) *wal {
+	w := &wal{
+		path:    path,
+		maxSize: maxSize,
+		logf:    log.Printf,
+	}
+	if fi, err := os.Stat(path); err == nil && fi.Size() > 0 {
+		w.pending = true
+	}
+	return w
+}
+
+// append adds a batch at the end of the log, unless that would make it exceed its maximum size.
+func (w *wal) append(bp client.BatchPoints) error {
+	rec := walRecord{
+		Database:        bp.Database,
+		RetentionPolicy: bp.RetentionPolicy,
+		Precision:       bp.Precision,
+	}
+	for _, line := range lines(bp, w.floats) {
+		rec.Lines = append(rec.Lines, strings.TrimSuffix(string(line), "\n"))
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	f, err := os.OpenFile(w.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if w.maxSize > 0 {
+		fi, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		if fi.Size()+int64(len(data)) > w.maxSize {
+			return errWALFull
+		}
+	}
+
+	if _, err := f.Write(data); err != nil {
+		return err
+	}
+	w.pending = true
+
+	return nil
+}
+
+// replay writes the logged batches in order with wr. The log is truncated once every batch is written,
+// or compacted down to the batches left after the first failure. It returns the number of written batches.
+func (w *wal) replay(ctx context.Context, wr Writer) (int, error) {
+	data, err := ioutil.ReadFile(w.path)
+	if os.IsNotExist(err) {
+		w.pending = false
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var records [][]byte
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(nil, len(data)+1)
+	for sc.Scan() {
+		if len(sc.Bytes()) > 0 {
+			records = append(records, append([]byte(nil), sc.Bytes()...))
+		}
+	}
+
+	for i, data := range records {
+		var rec walRecord
+		if err := json.Unmarshal(data, &rec); err != nil {
+			w.logf("skipping corrupted record of write-ahead log %s. err=%v", w.path, err)
+			continue
+		}
+
+		bp := client.BatchPoints{
+			Database:        rec.Database,
+			RetentionPolicy: rec.RetentionPolicy,
+			Precision:       rec.Precision,
+		}
+		for _, line := range rec.Lines {
+			bp.Points = append(bp.Points, client.Point{Raw: line})
+		}
+
+		if err := wr.WriteBatch(ctx, bp); err != nil {
+			if cerr := w.rewrite(records[i:]); cerr != nil {
+				w.logf("unable to compact write-ahead log %s. err=%v", w.path, cerr)
+			}
+			return i, err
+		}
+	}
+
+	w.pending = false
+	return len(records), w.rewrite(nil)
+}
+
+// rewrite atomically replaces the content of the log with the given records.
+func (w *wal) rewrite(records [][]byte) error {
+	tmp := w.path + ".tmp"
+	var buf bytes.Buffer
+	for _, rec := range records {
+		buf.Write(rec)
+		buf.WriteByte('\n')
+	}
+	if err := ioutil.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, w.path)
+}
@@ -0,0 +1,226 @@
+package influxdb
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb/client"
+)
+
+// walBatch returns a batch holding a point of a counter with the given value.
+func walBatch(value int64) client.BatchPoints {
+	return client.BatchPoints{
+		Database:  "db",
+		Precision: "s",
+		Points: []client.Point{{
+			Measurement: "requests.count",
+			Fields:      map[string]interface{}{"value": value},
+			Time:        time.Unix(value, 0),
+		}},
+	}
+}
+
+// walLines returns the raw lines of the replayed batches written to w.
+func walLines(t *testing.T, w *testWriter) []string {
+	t.Helper()
+
+	var got []string
+	for _, bp := range w.written() {
+		if bp.Database != "db" || bp.Precision != "s" {
+			t.Errorf("got batch of database %q and precision %q, want those of the logged batch", bp.Database, bp.Precision)
+		}
+		for _, p := range bp.Points {
+			got = append(got, p.Raw)
+		}
+	}
+	return got
+}
+
+func TestWAL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wal")
+	wl := newWAL(path, 0)
+	if wl.pending {
+		t.Error("got a pending log without file")
+	}
+	for i := int64(1); i <= 3; i++ {
+		if err := wl.append(walBatch(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a log left by a previous process is replayed at startup
+	wl = newWAL(path, 0)
+	if !wl.pending {
+		t.Fatal("got no pending batches in an existing log")
+	}
+	if n, err := wl.replay(context.Background(), &testWriter{err: errTest}); n != 0 || err != errTest {
+		t.Fatalf("got %d batches and error %v replaying to a failing writer, want none and its error", n, err)
+	}
+	w := &testWriter{}
+	n, err := wl.replay(context.Background(), w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"requests.count value=1i 1", "requests.count value=2i 2", "requests.count value=3i 3"}
+	if got := walLines(t, w); n != 3 || !reflect.DeepEqual(got, want) {
+		t.Errorf("got %d batches of lines %q, want %q", n, got, want)
+	}
+
+	// the replayed batches are removed from the log
+	if fi, err := os.Stat(path); err != nil || fi.Size() != 0 {
+		t.Errorf("got log %v after the replay, want an empty one (err=%v)", fi, err)
+	}
+	if wl.pending {
+		t.Error("got pending batches after the replay")
+	}
+	if n, err := wl.replay(context.Background(), w); n != 0 || err != nil {
+		t.Errorf("got %d batches and error %v replaying an empty log", n, err)
+	}
+}
+
+// failingAfterWriter fails the writes once n batches were written, unless n is negative.
+type failingAfterWriter struct {
+	testWriter
+	n int
+}
+
+func (w *failingAfterWriter) WriteBatch(ctx context.Context, bp client.BatchPoints) error {
+	if w.n >= 0 && len(w.written()) >= w.n {
+		return errTest
+	}
+	return w.testWriter.WriteBatch(ctx, bp)
+}
+
+func TestWALCompaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wal")
+	wl := newWAL(path, 0)
+	for i := int64(1); i <= 3; i++ {
+		if err := wl.append(walBatch(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the writer fails on the second batch, which is kept with the following ones
+	w := &failingAfterWriter{n: 1}
+	if n, err := wl.replay(context.Background(), w); n != 1 || err != errTest {
+		t.Fatalf("got %d batches and error %v, want 1 and the error of the writer", n, err)
+	}
+	if !wl.pending {
+		t.Error("got no pending batches after a failed replay")
+	}
+	w.n = -1
+	if n, err := wl.replay(context.Background(), w); n != 2 || err != nil {
+		t.Fatalf("got %d batches and error %v, want the 2 batches left", n, err)
+	}
+	want := []string{"requests.count value=1i 1", "requests.count value=2i 2", "requests.count value=3i 3"}
+	if got := walLines(t, &w.testWriter); !reflect.DeepEqual(got, want) {
+		t.Errorf("got lines %q, want each batch written once %q", got, want)
+	}
+}
+
+func TestWALMaxSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wal")
+	wl := newWAL(path, 0)
+	if err := wl.append(walBatch(1)); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a second record wouldn't fit
+	wl.maxSize = fi.Size() * 3 / 2
+	if err := wl.append(walBatch(2)); err != errWALFull {
+		t.Fatalf("got error %v appending past the maximum size, want %v", err, errWALFull)
+	}
+	if fi2, err := os.Stat(path); err != nil || fi2.Size() != fi.Size() {
+		t.Fatalf("got log %v after a rejected append, want it unchanged (err=%v)", fi2, err)
+	}
+
+	// the size is available again once the log is replayed
+	w := &testWriter{}
+	if _, err := wl.replay(context.Background(), w); err != nil {
+		t.Fatal(err)
+	}
+	if err := wl.append(walBatch(2)); err != nil {
+		t.Errorf("got error %v appending to a replayed log", err)
+	}
+}
+
+func TestWALCorruptRecords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wal")
+	wl := newWAL(path, 0)
+	for i := int64(1); i <= 2; i++ {
+		if err := wl.append(walBatch(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a corrupt record in the middle and a record truncated by a crash at the end
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := data[:bytes.IndexByte(data, '\n')]
+	data = append([]byte("{\"db\":\n"), data...)
+	data = append(data, first[:len(first)/2]...)
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	w := &testWriter{}
+	wl = newWAL(path, 0)
+	if _, err := wl.replay(context.Background(), w); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"requests.count value=1i 1", "requests.count value=2i 2"}
+	if got := walLines(t, w); !reflect.DeepEqual(got, want) {
+		t.Errorf("got lines %q, want those of the valid records %q", got, want)
+	}
+	if fi, err := os.Stat(path); err != nil || fi.Size() != 0 {
+		t.Errorf("got log %v after the replay, want the corrupt records dropped (err=%v)", fi, err)
+	}
+}
+
+func TestReporterWAL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wal")
+	w := &testWriter{err: errTest}
+	r := newTestReporter(t, newRegistryWithCounter(), w, WithWAL(path, 0))
+	for i := 0; i < 2; i++ {
+		if err := r.Flush(context.Background()); err == nil {
+			t.Fatal("got no error from a failing writer")
+		}
+	}
+
+	// a new reporter replays the batches at startup, as Run does
+	w = &testWriter{}
+	r = newTestReporter(t, newRegistryWithCounter(), w, WithWAL(path, 0))
+	r.replayWAL(context.Background())
+	if got := len(w.written()); got != 2 {
+		t.Fatalf("got %d batches replayed at startup, want 2", got)
+	}
+
+	// and the batches logged later after the next successful write
+	w.failWith(errTest)
+	if err := r.Flush(context.Background()); err == nil {
+		t.Fatal("got no error from a failing writer")
+	}
+	w.failWith(nil)
+	flush(t, r)
+	written := w.written()
+	if len(written) != 4 {
+		t.Fatalf("got %d batches, want the flush followed by the logged one", len(written))
+	}
+	for i, bp := range written[2:] {
+		if raw := bp.Points[0].Raw != ""; raw != (i == 1) {
+			t.Errorf("got batch %d %v, want the flush then the replayed batch", i, bp.Points)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package influxdb
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influxdata/influxdb/client"
+)
+
+// Writer delivers a batch of points to a backend.
+// WriteBatch must return once ctx is done, which happens when the flush times out or the reporter stops.
+type Writer interface {
+	WriteBatch(ctx context.Context, bp client.BatchPoints) error
+}
+
+// socketWriter writes batches as line protocol to a socket.
+type socketWriter struct {
+	network string
+	address string
+
+	conn   net.Conn
+	floats FloatFormat
+}
+
+// NewSocketWriter returns a Writer which sends the points as line protocol to the given address,
+// for example a Telegraf socket_listener on "unix", "/tmp/telegraf.sock" or "tcp", "localhost:8094".
+// The connection is dialed on the first write and redialed after a failed one.
+// On packet oriented networks such as "udp" or "unixgram" every point is sent as its own packet.
+func NewSocketWriter(network, address string) Writer {
+	return &socketWriter{
+		network: network,
+		address: address,
+	}
+}
+
+func (w *socketWriter) WriteBatch(ctx context.Context, bp client.BatchPoints) error {
+	if w.conn == nil {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, w.network, w.address)
+		if err != nil {
+			return err
+		}
+		w.conn = conn
+	}
+
+	// unblock the writes once ctx is done, the deadline of ctx being set first so that it can't
+	// override the one in the past set on cancelation
+	conn := w.conn
+	deadline, _ := ctx.Deadline()
+	conn.SetWriteDeadline(deadline)
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.SetWriteDeadline(time.Unix(1, 0))
+		case <-stop:
+		}
+	}()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var err error
+	if w.packets() {
+		for _, line := range lines(bp, w.floats) {
+			if _, err = w.conn.Write(line); err != nil {
+				break
+			}
+		}
+	} else {
+		_, err = w.conn.Write(bytes.Join(lines(bp, w.floats), nil))
+	}
+	if err != nil {
+		w.conn.Close()
+		w.conn = nil
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+
+	return err
+}
+
+func (w *socketWriter) setFloatFormat(f FloatFormat) {
+	w.floats = f
+}
+
+func (w *socketWriter) packets() bool {
+	return strings.HasPrefix(w.network, "udp") || w.network == "unixgram"
+}
+
+// streamWriter writes batches as line protocol to an io.Writer.
+type streamWriter struct {
+	mu     sync.Mutex
+	w      io.Writer
+	floats FloatFormat
+	// set when a write stopped within a line, which the next write terminates
+	partial bool
+}
+
+// flusher is implemented by the buffered writers, such as bufio.Writer.
+type flusher interface {
+	Flush() error
+}
+
+// NewStreamWriter returns a Writer which writes the points as line protocol to w, for example
+// os.Stderr, a file, a pipe or a connection managed by the caller, or a wrapper handing the lines
+// to another transport such as Kafka. Each batch is written in a single Write call, and w is flushed
+// after each batch when it has a Flush() error method, such as a bufio.Writer. Writes are serialized,
+// so w may be shared with other writers. When a write fails within a line, the next batch starts
+// with a newline so that only the truncated line is lost.
+func NewStreamWriter(w io.Writer) Writer {
+	return &streamWriter{w: w}
+}
+
+func (w *streamWriter) WriteBatch(ctx context.Context, bp client.BatchPoints) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	b := bytes.Join(lines(bp, w.floats), nil)
+	if w.partial {
+		b = append([]byte("\n"), b...)
+	}
+	n, err := w.w.Write(b)
+	if err == nil && n < len(b) {
+		err = io.ErrShortWrite
+	}
+	if err != nil {
+		if n > 0 {
+			w.partial = b[n-1] != '\n'
+		}
+		return err
+	}
+	w.partial = false
+
+	if f, ok := w.w.(flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+func (w *streamWriter) setFloatFormat(f FloatFormat) {
+	w.floats = f
+}
+
+// floatFormatter is implemented by the writers serializing line protocol themselves, see WithFloatFormat.
+type floatFormatter interface {
+	setFloatFormat(f FloatFormat)
+}
+
+// lines serializes the points of a batch to line protocol, one newline terminated line per point.
+// Like the InfluxDB client, the batch tags and precision apply to every point that doesn't set its own.
+// Points that can't be serialized, such as those with a NaN field, are skipped: the reporter logs
+// and removes them from the batch before the write.
+func lines(bp client.BatchPoints, floats FloatFormat) [][]byte {
+	res := make([][]byte, 0, len(bp.Points))
+	for _, p := range bp.Points {
+		if p.Raw != "" {
+			res = append(res, []byte(p.Raw+"\n"))
+			continue
+		}
+
+		if len(bp.Tags) > 0 {
+			tags := make(map[string]string, len(p.Tags)+len(bp.Tags))
+			for k, v := range bp.Tags {
+				tags[k] = v
+			}
+			for k, v := range p.Tags {
+				tags[k] = v
+			}
+			p.Tags = tags
+		}
+		precision := p.Precision
+		if precision == "" {
+			precision = bp.Precision
+		}
+
+		line, err := appendLine(nil, p, precision, floats)
+		if err != nil {
+			continue
+		}
+		res = append(res, append(line, '\n'))
+	}
+
+	return res
+}
@@ -0,0 +1,124 @@
+package influxdb
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb/client"
+)
+
+// newStreamBatch returns a batch of the points of the given measurements, with a value of 1 at 1000s.
+func newStreamBatch(measurements ...string) client.BatchPoints {
+	bp := client.BatchPoints{Precision: "s"}
+	for _, m := range measurements {
+		bp.Points = append(bp.Points, client.Point{Measurement: m, Fields: map[string]interface{}{"value": int64(1)}, Time: time.Unix(1000, 0)})
+	}
+	return bp
+}
+
+func TestStreamWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewStreamWriter(&buf)
+	for _, bp := range []client.BatchPoints{newStreamBatch("a", "b"), newStreamBatch("c")} {
+		if err := w.WriteBatch(context.Background(), bp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := "a value=1i 1000\nb value=1i 1000\nc value=1i 1000\n"; buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+
+	// buffered writers are flushed after each batch
+	buf.Reset()
+	w = NewStreamWriter(bufio.NewWriter(&buf))
+	if err := w.WriteBatch(context.Background(), newStreamBatch("a")); err != nil {
+		t.Fatal(err)
+	}
+	if want := "a value=1i 1000\n"; buf.String() != want {
+		t.Errorf("got %q from a buffered writer, want %q", buf.String(), want)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := w.WriteBatch(ctx, newStreamBatch("a")); err != context.Canceled {
+		t.Errorf("got error %v for a canceled context, want %v", err, context.Canceled)
+	}
+}
+
+// shortWriter writes at most n bytes of the first write, and everything afterwards.
+type shortWriter struct {
+	bytes.Buffer
+	n int
+}
+
+func (w *shortWriter) Write(b []byte) (int, error) {
+	if w.n < 0 || len(b) <= w.n {
+		return w.Buffer.Write(b)
+	}
+	n, _ := w.Buffer.Write(b[:w.n])
+	w.n = -1
+	return n, nil
+}
+
+func TestStreamWriterShortWrite(t *testing.T) {
+	sw := &shortWriter{n: 20}
+	w := NewStreamWriter(sw)
+	if err := w.WriteBatch(context.Background(), newStreamBatch("a", "b")); err != io.ErrShortWrite {
+		t.Fatalf("got error %v, want %v", err, io.ErrShortWrite)
+	}
+	// the next batch terminates the truncated line, so that only that line is lost
+	if err := w.WriteBatch(context.Background(), newStreamBatch("c")); err != nil {
+		t.Fatal(err)
+	}
+	if want := "a value=1i 1000\nb va\nc value=1i 1000\n"; sw.String() != want {
+		t.Errorf("got %q, want %q", sw.String(), want)
+	}
+}
+
+func TestSocketWriter(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	received := make(chan string)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		sc := bufio.NewScanner(conn)
+		for sc.Scan() {
+			received <- sc.Text()
+		}
+	}()
+
+	w := NewSocketWriter("tcp", l.Addr().String())
+	if err := w.WriteBatch(context.Background(), newStreamBatch("a")); err != nil {
+		t.Fatal(err)
+	}
+	if got := <-received; got != "a value=1i 1000" {
+		t.Errorf("got line %q, want the one of the batch", got)
+	}
+
+	// a canceled write leaves the connection usable
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := w.WriteBatch(ctx, newStreamBatch("b")); err != context.Canceled {
+		t.Errorf("got error %v for a canceled context, want %v", err, context.Canceled)
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err := w.WriteBatch(ctx, newStreamBatch("c")); err != nil {
+		t.Fatal(err)
+	}
+	if got := <-received; got != "c value=1i 1000" {
+		t.Errorf("got line %q, want the one of the batch written after the canceled one", got)
+	}
+}